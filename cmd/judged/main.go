@@ -0,0 +1,58 @@
+// judged 是独立的判题节点，向 API 注册后拉取待判任务并上报结果，不需要连接数据库和 Redis
+// 与 API 使用同一个配置文件（config/config.ini），命令行参数优先
+package main
+
+import (
+	"context"
+	"flag"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/judge/node"
+	"gin_gorm_oj/judge/sandbox"
+	"gin_gorm_oj/utils"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	server := flag.String("server", utils.JudgeServer, "API 地址")
+	token := flag.String("token", utils.JudgeNodeToken, "与 API 约定的共享密钥")
+	name := flag.String("name", utils.JudgeNode, "判题节点名称，各节点不能重复")
+	workers := flag.Int("workers", utils.JudgeWorkers, "判题协程数")
+	cache := flag.String("cache", "testcase-cache", "测试数据缓存目录")
+	flag.Parse()
+	if *name == "" {
+		// 独立判题节点按心跳超时回收任务，名称变化不会丢失任务，未配置时使用主机名
+		*name, _ = os.Hostname()
+	}
+	if *token == "" {
+		log.Fatal("judged: 未配置共享密钥（-token 或 [judge] JudgeNodeToken）")
+	}
+
+	limits := sandbox.DefaultLimits
+	limits.FileSize = uint64(utils.JudgeFileSize) << 20
+	limits.Processes = uint64(utils.JudgeNproc)
+	limits.OpenFiles = uint64(utils.JudgeNofile)
+	if err := judge.SetupSandbox(utils.JudgeSandbox, limits, utils.JudgeSandboxPaths); err != nil {
+		log.Fatal(err)
+	}
+	// 用户代码保存在 code 目录下
+	if err := os.MkdirAll("code", 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	n := &node.Node{
+		Client:      &node.Client{Server: *server, Token: *token},
+		Name:        *name,
+		Workers:     *workers,
+		Cache:       &node.Cache{Dir: *cache},
+		OutputLimit: utils.JudgeOutputLimit << 20,
+	}
+	if err := n.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+	log.Printf("judged: %s stopped", *name)
+}
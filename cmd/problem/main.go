@@ -0,0 +1,101 @@
+// problem 是导入导出问题包的命令行工具，用于在不同实例之间迁移问题
+// 与 API 使用同一个配置文件（config/config.ini），直接连接其中的数据库和测试数据存储
+//
+//	problem export -identity <问题标识> [-o problem.zip]
+//	problem import [-format auto|native|fps|polygon] [-dry-run] <问题包>
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gin_gorm_oj/problempkg"
+	"gin_gorm_oj/service"
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "export":
+		export(os.Args[2:])
+	case "import":
+		importPackage(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法：")
+	fmt.Fprintln(os.Stderr, "  problem export -identity <问题标识> [-o problem.zip]")
+	fmt.Fprintln(os.Stderr, "  problem import [-format auto|native|fps|polygon] [-dry-run] <问题包>")
+	os.Exit(2)
+}
+
+// export 导出问题包，-o 为空时写入 problem-<问题标识>.zip
+func export(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	identity := fs.String("identity", "", "问题唯一标识")
+	out := fs.String("o", "", "输出文件，默认为 problem-<问题标识>.zip")
+	fs.Parse(args)
+	if *identity == "" {
+		usage()
+	}
+	if *out == "" {
+		*out = "problem-" + *identity + ".zip"
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = service.ExportProblem(context.Background(), *identity, f); err != nil {
+		f.Close()
+		os.Remove(*out)
+		log.Fatalf("导出失败：%v", err)
+	}
+	if err = f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("已导出到 %s", *out)
+}
+
+// importPackage 导入问题包并输出每个问题的修改和警告
+func importPackage(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", problempkg.FormatAuto, "问题包格式：auto、native、fps、polygon")
+	dryRun := fs.Bool("dry-run", false, "只检查问题包并输出将会做出的修改，不写入数据")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+	reports, err := service.ImportProblems(context.Background(), f, fi.Size(), *format, *dryRun)
+	for _, r := range reports {
+		fmt.Printf("[%s] %s %s（%s）\n", r.Action, r.Identity, r.Title, r.Source)
+		for _, change := range r.Changes {
+			fmt.Printf("  - %s\n", change)
+		}
+		for _, warning := range r.Warnings {
+			fmt.Printf("  ! %s\n", warning)
+		}
+	}
+	if err != nil {
+		log.Fatalf("导入失败：%v", err)
+	}
+	if *dryRun {
+		log.Printf("预览完成，没有写入数据")
+	}
+}
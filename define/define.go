@@ -20,8 +20,28 @@ type ProblemBasic struct {
 	MaxRuntime int `json:"max_runtime"`
 	// MaxMem 是最大运行内存
 	MaxMem int `json:"max_mem"`
+	// Languages 是允许提交的语言标识列表，为空表示允许所有语言
+	Languages []string `json:"languages"`
+	// CompareMode 是输出比较方式：exact、trailing、token、token_ci、float，为空表示 exact
+	CompareMode string `json:"compare_mode"`
+	// FloatEpsilon 是 float 比较方式下允许的绝对或相对误差，0 表示使用默认误差
+	FloatEpsilon float64 `json:"float_epsilon"`
+	// JudgeMode 是判题模式：first_fail、run_all，为空表示有子任务时 run_all，否则 first_fail
+	JudgeMode string `json:"judge_mode"`
+	// ProblemType 是问题类型：standard、interactive，为空表示 standard
+	ProblemType string `json:"problem_type"`
+	// AllowImports 是 Go 代码允许导入的包，为空表示使用默认的白名单
+	AllowImports []string `json:"allow_imports"`
+	// DenyImports 是 Go 代码禁止导入的包，优先于 AllowImports
+	DenyImports []string `json:"deny_imports"`
 	// TestCases 是关联测试用例表的列表
 	TestCases []*TestCase `json:"test_cases"`
+	// Subtasks 是子任务列表，为空表示不划分子任务，得分按测试用例平均计算
+	Subtasks []*Subtask `json:"subtasks"`
+	// FunctionSignature 是函数式问题要求实现的函数签名，仅用于展示
+	FunctionSignature string `json:"function_signature"`
+	// Templates 是函数式问题各语言的代码模板，为空表示普通问题；设置了模板时只能使用有模板的语言提交
+	Templates []*ProblemTemplate `json:"templates"`
 }
 
 // TestCase 表示测试用例的结构体
@@ -30,6 +50,98 @@ type TestCase struct {
 	Input string `json:"input"`
 	// Output 是测试用例的输出
 	Output string `json:"output"`
+	// Subtask 是测试用例所属子任务的编号，0 表示不属于任何子任务
+	Subtask int `json:"subtask"`
+	// IsSample 表示测试用例是样例，样例会展示给用户
+	IsSample bool `json:"is_sample"`
+	// Explanation 是样例的说明
+	Explanation string `json:"explanation"`
+}
+
+// ProblemSample 表示问题详情中展示的样例
+type ProblemSample struct {
+	// Input 是样例输入
+	Input string `json:"input"`
+	// Output 是样例输出
+	Output string `json:"output"`
+	// Explanation 是样例的说明，可以为空
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// TestCaseCreate 表示添加单个测试用例的结构体，新的测试用例排在最后
+type TestCaseCreate struct {
+	// ProblemIdentity 是测试用例所属问题的唯一标识
+	ProblemIdentity string `json:"problem_identity"`
+	TestCase
+}
+
+// TestCaseModify 表示修改单个测试用例的结构体，为空的字段保持不变
+type TestCaseModify struct {
+	// Identity 是测试用例的唯一标识
+	Identity string `json:"identity"`
+	// Input 是新的输入
+	Input *string `json:"input"`
+	// Output 是新的预期输出
+	Output *string `json:"output"`
+	// Subtask 是新的所属子任务编号
+	Subtask *int `json:"subtask"`
+	// IsSample 表示是否为样例
+	IsSample *bool `json:"is_sample"`
+	// Explanation 是新的样例说明
+	Explanation *string `json:"explanation"`
+}
+
+// TestCaseSort 表示调整测试用例顺序的结构体
+type TestCaseSort struct {
+	// ProblemIdentity 是问题的唯一标识
+	ProblemIdentity string `json:"problem_identity"`
+	// Identities 是按新顺序排列的全部测试用例唯一标识
+	Identities []string `json:"identities"`
+}
+
+// ProblemTemplate 表示函数式问题在一种语言下的代码模板
+type ProblemTemplate struct {
+	// Language 是模板的语言标识
+	Language string `json:"language"`
+	// Starter 是展示给用户的初始代码
+	Starter string `json:"starter"`
+	// Harness 是驱动模板，必须包含用户代码的占位符 {{USER_CODE}}
+	Harness string `json:"harness"`
+}
+
+// Subtask 表示子任务的结构体
+type Subtask struct {
+	// Number 是子任务编号，从 1 开始连续编号
+	Number int `json:"number"`
+	// Score 是子任务的分值
+	Score int `json:"score"`
+	// Depends 是依赖的子任务编号，只能依赖编号更小的子任务
+	Depends []int `json:"depends"`
+}
+
+// RunCode 表示自定义输入运行的结构体
+type RunCode struct {
+	// ProblemIdentity 是问题的唯一标识，运行时使用该问题的时间和内存限制
+	ProblemIdentity string `json:"problem_identity"`
+	// Language 是代码的语言标识，为空时使用默认语言
+	Language string `json:"language"`
+	// Code 是源代码
+	Code string `json:"code"`
+	// Input 是程序的标准输入，为空时使用问题的样例输入
+	Input string `json:"input"`
+	// Sample 是使用的样例编号，从 1 开始，仅在 Input 为空时生效，为 0 表示第一个样例
+	Sample int `json:"sample"`
+}
+
+// ProblemChecker 表示上传特判程序的结构体
+type ProblemChecker struct {
+	// ProblemIdentity 是特判程序所属问题的唯一标识
+	ProblemIdentity string `json:"problem_identity"`
+	// Language 是特判程序的语言标识
+	Language string `json:"language"`
+	// Code 是特判程序的源代码，命令行参数依次为输入文件、选手输出文件和标准答案文件；
+	// 交互题上传的是交互程序，命令行参数相同，标准输入输出与用户程序交叉连接
+	Code string `json:"code"`
 }
 
 // ContestBasic 表示竞赛基础信息的结构体
@@ -48,6 +160,12 @@ type ContestBasic struct {
 	EndAt int64 `json:"end_at"`
 }
 
+// 问题类型
+const (
+	ProblemTypeStandard    = "standard"    // 标准题，按输出比较方式或特判程序判定
+	ProblemTypeInteractive = "interactive" // 交互题，用户程序与交互程序通过标准输入输出交互，由交互程序的退出码判定
+)
+
 // DateLayout 是日期时间的格式化布局
 var DateLayout = "2006-01-02 15:04:05"
 
@@ -61,12 +179,16 @@ var ValidGolangPackageMap = map[string]struct{}{
 }
 
 const (
-	SubmitStatusPending             = 0 // 待判
-	SubmitStatusAccepted            = 1 // 答案正确
-	SubmitStatusWrongAnswer         = 2 // 答案错误
-	SubmitStatusTimeLimitExceeded   = 3 // 运行超时
-	SubmitStatusMemoryLimitExceeded = 4 // 运行超内存
-	SubmitStatusCompileError        = 5 // 编译错误
-	SubmitStatusInvalidCode         = 6 // 无效代码
+	SubmitStatusPending             = 0  // 待判
+	SubmitStatusAccepted            = 1  // 答案正确
+	SubmitStatusWrongAnswer         = 2  // 答案错误
+	SubmitStatusTimeLimitExceeded   = 3  // 运行超时
+	SubmitStatusMemoryLimitExceeded = 4  // 运行超内存
+	SubmitStatusCompileError        = 5  // 编译错误
+	SubmitStatusInvalidCode         = 6  // 无效代码
+	SubmitStatusSystemError         = 7  // 判题系统错误（多次重试后仍失败）
+	SubmitStatusPresentationError   = 8  // 格式错误（仅空白字符不同）
+	SubmitStatusRuntimeError        = 9  // 运行错误（非零退出、被信号杀死或 panic）
+	SubmitStatusOutputLimitExceeded = 10 // 输出超限
 	// ... 其他状态
 )
@@ -0,0 +1,157 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gin_gorm_oj/define"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checker 描述题目的特判程序
+// 特判程序采用 testlib 的约定：命令行参数依次为输入文件、选手输出文件和标准答案文件，通过退出码给出判定
+type Checker struct {
+	// Path 是特判程序代码文件的路径
+	Path string
+	// Language 是特判程序的语言标识，为空时使用 DefaultLanguage
+	Language string
+}
+
+// testlib 约定的特判程序退出码
+const (
+	checkerExitOK            = 0  // 答案正确
+	checkerExitWA            = 1  // 答案错误
+	checkerExitPE            = 2  // 格式错误
+	checkerExitFail          = 3  // 特判程序自身出错
+	checkerExitDirt          = 4  // 输出中有多余内容
+	checkerExitPoints        = 7  // 按分数评分，分数写在标准错误输出的开头
+	checkerExitUnexpectedEOF = 8  // 选手输出提前结束
+	checkerExitPartialBase   = 50 // 部分正确，退出码减去该值为得分百分比
+)
+
+// checkerTimeout 是特判程序运行一次的最长时间
+const checkerTimeout = 10 * time.Second
+
+// preparedChecker 是编译好的特判程序
+type preparedChecker struct {
+	dir  string
+	argv []string
+}
+
+// checkerVerdict 是特判程序对一个测试用例的判定
+type checkerVerdict struct {
+	status int
+	score  float64 // 得分比例，取值 0 到 1
+	msg    string
+}
+
+// prepareChecker 编译特判程序，特判程序由管理员提供，不做代码合法性检查
+// 特判程序编译失败时 msg 为编译器输出，err 仅在编译环境异常时返回
+func prepareChecker(c *Checker) (pc *preparedChecker, msg string, err error) {
+	lang, ok := GetLanguage(c.Language)
+	if !ok {
+		return nil, "", errors.New("不支持的特判程序语言：" + c.Language)
+	}
+	dir, err := filepath.Abs(filepath.Dir(c.Path))
+	if err != nil {
+		return nil, "", err
+	}
+	compiled, msg, err := compile(lang, dir)
+	if err != nil || !compiled {
+		return nil, msg, err
+	}
+	return &preparedChecker{dir: dir, argv: lang.RunCommand(dir)}, "", nil
+}
+
+// CompileChecker 编译特判程序或交互程序，用于在保存之前确认代码可以编译
+// 编译失败时 msg 为编译器输出，err 仅在编译环境异常时返回，编译产物留在代码所在目录中，由调用方删除
+func CompileChecker(c *Checker) (msg string, err error) {
+	pc, msg, err := prepareChecker(c)
+	if err != nil || pc != nil {
+		return "", err
+	}
+	return msg, nil
+}
+
+// check 运行特判程序判定一个测试用例
+// 输入、选手输出和标准答案的文件路径作为参数传给特判程序，保存在文件中的测试数据直接传递原文件，其余写入临时文件
+func (pc *preparedChecker) check(tc *Case, output string) (*checkerVerdict, error) {
+	tmp, err := os.MkdirTemp("", "oj-checker-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	input, err := caseFile(tmp, "input.txt", tc.InputFile, tc.Input)
+	if err != nil {
+		return nil, err
+	}
+	outputPath, err := caseFile(tmp, "output.txt", "", output)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := caseFile(tmp, "answer.txt", tc.OutputFile, tc.Output)
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]string{}, pc.argv...), input, outputPath, answer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkerTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = pc.dir
+	cmd.Stdout = &stderr // testlib 把判定信息写到标准错误输出，这里一并收集标准输出
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	msg := strings.TrimSpace(stderr.String())
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, errors.New("特判程序运行超时")
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	return parseCheckerExit("特判程序", cmd.ProcessState.ExitCode(), msg)
+}
+
+// parseCheckerExit 根据 testlib 的退出码约定解析特判程序或交互程序的判定，name 用于错误信息
+func parseCheckerExit(name string, code int, msg string) (*checkerVerdict, error) {
+	switch {
+	case code == checkerExitOK:
+		return &checkerVerdict{status: define.SubmitStatusAccepted, score: 1, msg: msg}, nil
+	case code == checkerExitPE:
+		return &checkerVerdict{status: define.SubmitStatusPresentationError, msg: msg}, nil
+	case code == checkerExitWA, code == checkerExitDirt, code == checkerExitUnexpectedEOF:
+		return &checkerVerdict{status: define.SubmitStatusWrongAnswer, msg: msg}, nil
+	case code == checkerExitPoints:
+		// 标准错误输出形如 "points 0.5 说明"，分数按 0 到 1 的比例处理
+		fields := strings.Fields(strings.TrimPrefix(msg, "points"))
+		if len(fields) == 0 {
+			return nil, errors.New(name + "未给出分数：" + msg)
+		}
+		score, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, errors.New(name + "给出的分数无效：" + msg)
+		}
+		return partialVerdict(score, msg), nil
+	case code >= checkerExitPartialBase && code <= checkerExitPartialBase+100:
+		return partialVerdict(float64(code-checkerExitPartialBase)/100, msg), nil
+	default:
+		// checkerExitFail 以及其他未知退出码都说明特判程序或交互程序自身有问题
+		return nil, errors.New(name + "出错（退出码 " + strconv.Itoa(code) + "）：" + msg)
+	}
+}
+
+// partialVerdict 根据得分比例生成判定，满分视为答案正确
+func partialVerdict(score float64, msg string) *checkerVerdict {
+	score = min(max(score, 0), 1)
+	if score == 1 {
+		return &checkerVerdict{status: define.SubmitStatusAccepted, score: 1, msg: msg}
+	}
+	return &checkerVerdict{status: define.SubmitStatusWrongAnswer, score: score, msg: msg}
+}
@@ -0,0 +1,104 @@
+package judge
+
+import (
+	"gin_gorm_oj/define"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 输出比较方式，保存在问题的 CompareMode 中
+const (
+	CompareExact    = "exact"    // 完全一致
+	CompareTrailing = "trailing" // 忽略每行行末空白和末尾空行
+	CompareToken    = "token"    // 按空白分隔的单词逐个比较
+	CompareTokenCI  = "token_ci" // 按单词比较，忽略大小写
+	CompareFloat    = "float"    // 按单词比较，数字在误差范围内视为相等
+)
+
+// DefaultFloatEpsilon 是浮点数比较未指定误差时使用的默认误差
+const DefaultFloatEpsilon = 1e-6
+
+// ValidCompareMode 判断输出比较方式是否受支持，空字符串表示 CompareExact
+func ValidCompareMode(mode string) bool {
+	switch mode {
+	case "", CompareExact, CompareTrailing, CompareToken, CompareTokenCI, CompareFloat:
+		return true
+	}
+	return false
+}
+
+// compareOutput 按比较方式比较预期输出和实际输出
+// 返回 define.SubmitStatusAccepted、define.SubmitStatusWrongAnswer 或 define.SubmitStatusPresentationError，
+// 只有空白字符不同时返回格式错误
+func compareOutput(mode string, epsilon float64, expected, actual string) int {
+	var equal bool
+	switch mode {
+	case CompareTrailing:
+		equal = trimTrailing(expected) == trimTrailing(actual)
+	case CompareToken:
+		equal = compareTokens(expected, actual, func(e, a string) bool { return e == a })
+	case CompareTokenCI:
+		equal = compareTokens(expected, actual, strings.EqualFold)
+	case CompareFloat:
+		if epsilon <= 0 {
+			epsilon = DefaultFloatEpsilon
+		}
+		equal = compareTokens(expected, actual, func(e, a string) bool { return floatEqual(e, a, epsilon) })
+	default:
+		equal = expected == actual
+	}
+	if equal {
+		return define.SubmitStatusAccepted
+	}
+	// 按单词比较时空白字符本来就被忽略，只有更严格的比较方式才需要区分格式错误
+	if (mode == "" || mode == CompareExact || mode == CompareTrailing) &&
+		compareTokens(expected, actual, func(e, a string) bool { return e == a }) {
+		return define.SubmitStatusPresentationError
+	}
+	return define.SubmitStatusWrongAnswer
+}
+
+// trimTrailing 去掉每行行末的空白字符和末尾的空行
+func trimTrailing(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// compareTokens 将两个字符串按空白分隔为单词，逐个使用 equal 比较
+func compareTokens(expected, actual string, equal func(e, a string) bool) bool {
+	expectedTokens := strings.Fields(expected)
+	actualTokens := strings.Fields(actual)
+	if len(expectedTokens) != len(actualTokens) {
+		return false
+	}
+	for i := range expectedTokens {
+		if !equal(expectedTokens[i], actualTokens[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// floatEqual 比较两个单词，都是数字时绝对误差或相对误差不超过 epsilon 即视为相等，否则要求完全一致
+func floatEqual(expected, actual string, epsilon float64) bool {
+	if expected == actual {
+		return true
+	}
+	e, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return false
+	}
+	a, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false
+	}
+	if math.IsNaN(e) || math.IsNaN(a) || math.IsInf(e, 0) || math.IsInf(a, 0) {
+		return math.IsNaN(e) && math.IsNaN(a) || e == a
+	}
+	diff := math.Abs(e - a)
+	return diff <= epsilon || diff <= epsilon*math.Abs(e)
+}
@@ -0,0 +1,113 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"gin_gorm_oj/judge/sandbox"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Executor 是运行用户程序的执行后端
+type Executor interface {
+	// Command 构造在 dir 目录下运行 argv 的命令，cpuLimit 为 CPU 时间上限（毫秒），memLimit 为内存上限（KB）
+	// 命令应当由 sandbox 包构造，判题引擎通过 sandbox.ReadUsage 读取用户程序的 CPU 时间和峰值内存
+	Command(ctx context.Context, dir string, cpuLimit, memLimit int, argv ...string) *exec.Cmd
+	// Compile 构造在 dir 目录下执行编译命令 argv 的命令，编译器可以写入 dir 和 writable 中的目录，env 是附加的环境变量
+	Compile(ctx context.Context, dir string, writable, env []string, argv ...string) *exec.Cmd
+	// Interactor 构造在 dir 目录下运行交互程序 argv 的命令，交互程序可以写入 tmp 目录，资源限制与 Command 相同
+	Interactor(ctx context.Context, dir, tmp string, cpuLimit, memLimit int, argv ...string) *exec.Cmd
+}
+
+// PlainExecutor 直接以判题进程的身份运行用户程序，不做任何隔离，只限制 CPU 时间和内存，仅用于开发环境
+type PlainExecutor struct{}
+
+func (PlainExecutor) Command(ctx context.Context, dir string, cpuLimit, memLimit int, argv ...string) *exec.Cmd {
+	return sandbox.Monitor(ctx, dir, runLimits(sandbox.Limits{}, cpuLimit, memLimit), argv...)
+}
+
+func (PlainExecutor) Compile(ctx context.Context, dir string, writable, env []string, argv ...string) *exec.Cmd {
+	cmd := sandbox.Monitor(ctx, dir, compileLimits(sandbox.Limits{}), argv...)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+func (PlainExecutor) Interactor(ctx context.Context, dir, tmp string, cpuLimit, memLimit int, argv ...string) *exec.Cmd {
+	return sandbox.Monitor(ctx, dir, runLimits(sandbox.Limits{}, cpuLimit, memLimit), argv...)
+}
+
+// SandboxExecutor 在 Linux 命名空间沙箱中运行用户程序
+type SandboxExecutor struct {
+	// Limits 是沙箱的资源限制，CPUTime 会根据每次运行的时间限制重新计算
+	Limits sandbox.Limits
+}
+
+func (e SandboxExecutor) Command(ctx context.Context, dir string, cpuLimit, memLimit int, argv ...string) *exec.Cmd {
+	return sandbox.Command(ctx, dir, runLimits(e.Limits, cpuLimit, memLimit), argv...)
+}
+
+func (e SandboxExecutor) Compile(ctx context.Context, dir string, writable, env []string, argv ...string) *exec.Cmd {
+	return sandbox.Compile(ctx, dir, compileLimits(e.Limits), writable, env, argv...)
+}
+
+func (e SandboxExecutor) Interactor(ctx context.Context, dir, tmp string, cpuLimit, memLimit int, argv ...string) *exec.Cmd {
+	return sandbox.CommandWritable(ctx, dir, runLimits(e.Limits, cpuLimit, memLimit), []string{tmp}, argv...)
+}
+
+// dataSlack 是 RLIMIT_DATA 在内存限制之外额外放宽的字节数
+// RLIMIT_DATA 限制的是每个进程可写的私有映射，Go、Java 等运行时启动时就会提交一部分实际用不到的堆空间；
+// 是否超内存仍以峰值常驻内存判断，这里的限制只用于阻止程序耗尽判题机的内存
+const dataSlack = 128 << 20
+
+// runLimits 在 limits 的基础上按 CPU 时间上限（毫秒）和内存上限（KB）设置用户程序的资源限制
+func runLimits(limits sandbox.Limits, cpuLimit, memLimit int) sandbox.Limits {
+	// RLIMIT_CPU 以秒为单位，向上取整后再多给 1 秒，精确的时间判定仍以 rusage 为准
+	limits.CPUTime = uint64(cpuLimit+999)/1000 + 1
+	if memLimit > 0 {
+		limits.Memory = uint64(memLimit)<<10 + dataSlack
+	}
+	return limits
+}
+
+// compileMemory 是编译器每个进程可写私有内存的上限
+const compileMemory = 2 << 30
+
+// compileLimits 在 limits 的基础上设置编译器的资源限制，编译器会启动多个进程和线程，不限制进程数和文件数
+func compileLimits(limits sandbox.Limits) sandbox.Limits {
+	limits.CPUTime = uint64(compileTimeout/time.Second) + 1
+	limits.Processes, limits.OpenFiles = 0, 0
+	limits.Memory = compileMemory
+	return limits
+}
+
+// executor 是当前使用的执行后端，默认在沙箱中运行用户程序
+var executor Executor = SandboxExecutor{Limits: sandbox.DefaultLimits}
+
+// SetExecutor 设置运行用户程序的执行后端
+func SetExecutor(e Executor) {
+	executor = e
+}
+
+// SetupSandbox 根据沙箱模式（on、off）设置执行后端，paths 为沙箱中可见的宿主机路径，为空时使用 sandbox.Paths 的默认值
+// on 模式下沙箱不可用时返回错误，调用方应当拒绝启动；不使用沙箱必须显式配置为 off，仅用于开发环境
+func SetupSandbox(mode string, limits sandbox.Limits, paths []string) error {
+	switch mode {
+	case "on":
+	case "off":
+		log.Printf("判题沙箱已关闭，用户程序将直接在判题进程的权限下运行")
+		SetExecutor(PlainExecutor{})
+		return nil
+	default:
+		return fmt.Errorf("未知的判题沙箱模式: %q", mode)
+	}
+	if len(paths) > 0 {
+		sandbox.Paths = paths
+	}
+	if err := sandbox.Available(); err != nil {
+		return fmt.Errorf("判题沙箱不可用（开发环境可以配置 [judge] JudgeSandbox = off 关闭沙箱）: %w", err)
+	}
+	SetExecutor(SandboxExecutor{Limits: limits})
+	return nil
+}
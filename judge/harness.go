@@ -0,0 +1,28 @@
+package judge
+
+import (
+	"gin_gorm_oj/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HarnessPlaceholder 是驱动模板中用户代码的占位符
+// 函数式的问题只要求用户实现指定签名的函数，驱动模板负责读取输入、调用该函数并输出结果
+const HarnessPlaceholder = "{{USER_CODE}}"
+
+// mergeHarness 把代码文件 path 中的用户代码填入驱动模板 harness 的占位符，保存到新的代码目录并返回新代码文件的路径
+// 用户的代码文件保持不变，判题重试时可以重新合并；调用方负责通过 removeHarness 删除新代码目录
+func mergeHarness(lang Language, path, harness string) (string, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	merged := strings.Replace(harness, HarnessPlaceholder, string(code), 1)
+	return utils.CodeSaveAs([]byte(merged), lang.SourceFile())
+}
+
+// removeHarness 删除合并后的代码所在的目录
+func removeHarness(path string) {
+	os.RemoveAll(filepath.Dir(path))
+}
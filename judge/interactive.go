@@ -0,0 +1,157 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"gin_gorm_oj/define"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// interactorMargin 是交互程序在用户程序的墙上时间之外额外允许运行的时间，用于读取用户程序的最后输出并给出判定
+const interactorMargin = 5 * time.Second
+
+// interactorMemory 是交互程序的内存上限（KB）
+const interactorMemory = 512 << 10
+
+// interaction 是交互程序对一个测试用例的判定
+type interaction struct {
+	verdict *checkerVerdict // 交互程序的判定，err 不为空时为 nil
+	err     error           // 交互程序自身出错（退出码为 3 或未知、超时、输出超限）
+	first   bool            // 交互程序是否先于用户程序结束，用户程序因交互程序关闭管道被 SIGPIPE 杀死也视为交互程序先结束
+}
+
+// interact 运行交互题的一个测试用例：用户程序和交互程序同时启动，用户程序的标准输出连接到交互程序的标准输入，
+// 交互程序的标准输出连接到用户程序的标准输入；交互程序采用 testlib 的约定，命令行参数依次为输入文件、
+// 输出文件（交互程序可写入日志）和标准答案文件，通过退出码给出判定，判定信息写在标准错误输出中。
+// 用户程序与普通题一样通过执行后端运行，受相同的时间、内存和标准错误输出限制，标准输出不经过判题进程因而不做输出限制；
+// 交互程序虽然由管理员提供，但与用户程序同时运行且直接处理用户程序的输出，同样通过执行后端运行，
+// CPU 时间和墙上时间限制为用户程序的墙上时间加上 interactorMargin，内存限制为 interactorMemory，
+// 标准错误输出限制为 outputLimit；它只能看到自己的目录和存放测试数据的临时目录。交互程序先结束且判定未通过时立即杀掉用户程序。
+// 返回的 error 仅表示程序无法启动前的系统错误
+func interact(ctx context.Context, dir string, argv []string, maxRuntime, maxMem int, interactor *preparedChecker, tc *Case, outputLimit int) (*execution, *interaction, error) {
+	tmp, err := os.MkdirTemp("", "oj-interactor-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(tmp)
+	// 沙箱中看不到保存在文件中的测试数据，需要复制到临时目录；用户程序以 nobody 运行时交互程序也一样，
+	// 临时目录需要对其可读，输出文件需要对其可写
+	if err = os.Chmod(tmp, 0755); err != nil {
+		return nil, nil, err
+	}
+	input, err := copyCaseFile(tmp, "input.txt", tc.InputFile, tc.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := caseFile(tmp, "output.txt", "", "")
+	if err == nil {
+		err = os.Chmod(output, 0666)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	answer, err := copyCaseFile(tmp, "answer.txt", tc.OutputFile, tc.Output)
+	if err != nil {
+		return nil, nil, err
+	}
+	args := append(append([]string{}, interactor.argv...), input, output, answer)
+
+	// toUser 连接交互程序的标准输出和用户程序的标准输入，fromUser 连接用户程序的标准输出和交互程序的标准输入。
+	toUserR, toUserW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	fromUserR, fromUserW, err := os.Pipe()
+	if err != nil {
+		toUserR.Close()
+		toUserW.Close()
+		return nil, nil, err
+	}
+	// 子进程启动后各自持有需要的一端，判题进程必须关闭自己的副本，否则一方退出后另一方读不到 EOF。
+	pipes := []*os.File{toUserR, toUserW, fromUserR, fromUserW}
+	closePipes := func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+	}
+
+	userCtx, cancelUser := context.WithTimeout(ctx, wallTimeout(maxRuntime))
+	defer cancelUser()
+	user := executor.Command(userCtx, dir, maxRuntime, maxMem, argv...)
+	userStderr := &limitedBuffer{limit: outputLimit, exceed: cancelUser}
+	user.Stdin, user.Stdout, user.Stderr = toUserR, fromUserW, userStderr
+
+	interactorTimeout := wallTimeout(maxRuntime) + interactorMargin
+	interactorCtx, cancelInteractor := context.WithTimeout(ctx, interactorTimeout)
+	defer cancelInteractor()
+	cmd := executor.Interactor(interactorCtx, interactor.dir, tmp, int(interactorTimeout.Milliseconds()), interactorMemory, args...)
+	interactorStderr := &limitedBuffer{limit: outputLimit, exceed: cancelInteractor}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = fromUserR, toUserW, interactorStderr
+
+	if err = user.Start(); err != nil {
+		closePipes()
+		return nil, nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		closePipes()
+		cancelUser()
+		user.Wait()
+		return nil, nil, err
+	}
+	closePipes()
+
+	userDone := make(chan struct{})
+	var ex *execution
+	go func() {
+		defer close(userDone)
+		ex = newExecution(userCtx, user, user.Wait())
+	}()
+
+	it := &interaction{}
+	iex := newExecution(interactorCtx, cmd, cmd.Wait())
+	interactorEnd := time.Now()
+	select {
+	case <-userDone:
+	default:
+		it.first = true
+	}
+	msg := strings.TrimSpace(interactorStderr.buf.String())
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		// 测试用例被取消，结果由调用方丢弃。
+	case iex.timedOut:
+		it.err = errors.New("交互程序运行超时")
+	case interactorStderr.exceeded:
+		it.err = errors.New("交互程序输出超限")
+	case iex.err != nil && !errors.As(iex.err, &exitErr):
+		it.err = iex.err
+	case iex.noUsage:
+		// 沙箱初始化失败或监控进程被杀掉，交互程序没有正常运行。
+		it.err = errors.New("交互程序未能运行：" + msg)
+	case iex.memUsed > interactorMemory:
+		// 分配内存失败时交互程序的退出码不可信，按内存使用判断。
+		it.err = errors.New("交互程序超出内存限制")
+	default:
+		it.verdict, it.err = parseCheckerExit("交互程序", iex.exitCode, msg)
+	}
+	if it.first && (it.err != nil || it.verdict == nil || it.verdict.status != define.SubmitStatusAccepted) {
+		// 交互已经结束，不再等待用户程序。
+		cancelUser()
+	}
+	<-userDone
+	if it.first && !ex.exitTime.IsZero() && ex.exitTime.Before(interactorEnd) {
+		// 用户程序先结束，但监控进程和 pid 命名空间的退出晚于交互程序读到 EOF，以监控进程记录的结束时间为准。
+		it.first = false
+	}
+	if strings.HasPrefix(ex.signal, "SIGPIPE") {
+		// 交互程序退出后用户程序写入已关闭的管道，两者几乎同时结束，不能按观察到的先后判断。
+		it.first = true
+	}
+	ex.stderr = userStderr.buf.String()
+	ex.outputExceeded = userStderr.exceeded
+	return ex, it, nil
+}
@@ -0,0 +1,733 @@
+package judge
+
+import (
+	"bytes"
+	"context" // 引入 context 用于控制并发 goroutine 的超时和取消
+	"errors"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge/sandbox"
+	"gin_gorm_oj/utils"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync" // 引入 sync 包，用于 WaitGroup 和 Mutex
+	"time"
+	"unicode/utf8"
+)
+
+// Task 描述一次判题所需的全部信息
+// 判题引擎只依赖这里的数据，不直接访问数据库，便于在判题协程或独立进程中复用
+type Task struct {
+	// Path 是待判代码文件的路径
+	Path string
+	// Language 是代码的语言标识，为空时使用 DefaultLanguage
+	Language string
+	// MaxRuntime 是单个测试用例的最大运行时长（毫秒）
+	MaxRuntime int
+	// MaxMem 是单个测试用例的最大运行内存（KB）
+	MaxMem int
+	// TestCases 是需要执行的测试用例列表
+	TestCases []*Case
+	// Checker 是题目的特判程序，为空时按 CompareMode 比较输出
+	Checker *Checker
+	// Harness 是函数式问题在该语言下的驱动模板，Path 中的用户代码会被填入其中的 HarnessPlaceholder 后再检查和编译，为空时直接编译用户代码
+	Harness string
+	// Interactive 表示交互题，此时 Checker 是交互程序且不能为空，CompareMode 不生效，见 interact
+	Interactive bool
+	// CompareMode 是输出比较方式，取值见 Compare* 常量，为空时要求完全一致
+	CompareMode string
+	// FloatEpsilon 是 CompareFloat 方式下允许的绝对或相对误差，为 0 时使用 DefaultFloatEpsilon
+	FloatEpsilon float64
+	// Subtasks 是子任务列表，需按依赖顺序排列，为空时按测试用例平均计分
+	Subtasks []*Subtask
+	// Policy 是编译前代码检查的规则，为空时使用默认规则
+	Policy *utils.CodePolicy
+	// OutputLimit 是标准输出和标准错误输出各自的最大字节数，超出时杀掉程序，为 0 时使用 DefaultOutputLimit
+	OutputLimit int
+	// Mode 是判题模式，取值见 JudgeMode* 常量，为空时有子任务使用 JudgeModeRunAll，否则使用 JudgeModeFirstFail
+	Mode string
+	// Progress 在判题进度变化时被调用，stage 取值见 Progress* 常量，done 是已完成的测试用例个数；
+	// 回调在锁内串行调用，应当尽快返回，为空时不报告进度
+	Progress func(stage string, done, total int)
+}
+
+// 判题进度的阶段
+const (
+	ProgressCompiling = "compiling" // 正在编译
+	ProgressRunning   = "running"   // 正在运行测试用例
+)
+
+// 判题模式，保存在问题的 JudgeMode 中
+// 两种模式的最终结果都是按测试用例顺序第一个未通过的测试用例的判定
+const (
+	JudgeModeFirstFail = "first_fail" // 出现未通过的测试用例后取消排在它后面的测试用例，未执行的测试用例不得分
+	JudgeModeRunAll    = "run_all"    // 执行全部测试用例，用于部分分
+)
+
+// ValidJudgeMode 判断判题模式是否受支持，空字符串表示按是否有子任务自动选择
+func ValidJudgeMode(mode string) bool {
+	switch mode {
+	case "", JudgeModeFirstFail, JudgeModeRunAll:
+		return true
+	}
+	return false
+}
+
+// DefaultOutputLimit 是 Task.OutputLimit 为 0 时使用的输出上限
+const DefaultOutputLimit = 64 << 20
+
+// Case 表示一个测试用例
+type Case struct {
+	// Identity 是测试用例的唯一标识
+	Identity string
+	// Input 是测试用例的输入，写入标准输入时末尾追加一个换行符
+	Input string
+	// Output 是测试用例的预期输出
+	Output string
+	// InputFile 是保存测试用例输入的文件路径，不为空时代替 Input，文件直接作为程序的标准输入，不追加换行符
+	InputFile string
+	// OutputFile 是保存预期输出的文件路径，不为空时代替 Output
+	OutputFile string
+	// Subtask 是测试用例所属子任务的编号，0 表示不属于任何子任务
+	Subtask int
+}
+
+// stdin 打开测试用例的输入，调用方负责关闭
+func (c *Case) stdin() (io.ReadCloser, error) {
+	if c.InputFile != "" {
+		return os.Open(c.InputFile)
+	}
+	return io.NopCloser(strings.NewReader(c.Input + "\n")), nil
+}
+
+// answer 读取测试用例的预期输出
+func (c *Case) answer() (string, error) {
+	if c.OutputFile == "" {
+		return c.Output, nil
+	}
+	b, err := os.ReadFile(c.OutputFile)
+	return string(b), err
+}
+
+// caseFile 返回测试用例数据所在的文件路径，数据保存在文件中时直接使用该文件，否则把 content 写入 dir 下的 name 文件
+// 用于向特判程序和交互程序传递输入文件和标准答案文件，它们在自己的目录中运行，因此总是返回绝对路径
+func caseFile(dir, name, file, content string) (string, error) {
+	if file != "" {
+		return filepath.Abs(file)
+	}
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, []byte(content), 0644)
+}
+
+// copyCaseFile 与 caseFile 相同，但数据保存在文件中时也复制到 dir 下的 name 文件，用于在沙箱中运行的交互程序
+func copyCaseFile(dir, name, file, content string) (string, error) {
+	if file == "" {
+		return caseFile(dir, name, file, content)
+	}
+	src, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	path := filepath.Join(dir, name)
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", err
+	}
+	return path, dst.Close()
+}
+
+// Result 表示一次判题的最终结果
+type Result struct {
+	// Status 是判题状态，取值见 define.SubmitStatus*
+	Status int
+	// Msg 是判题结果的提示信息
+	Msg string
+	// PassCount 是通过的测试用例个数
+	PassCount int
+	// TimeUsed 是各测试用例中最大的 CPU 时间（毫秒）
+	TimeUsed int64
+	// MemUsed 是各测试用例中最大的峰值内存（KB）
+	MemUsed int64
+	// Score 是得分，没有子任务时取值 0 到 100，为各测试用例得分比例的平均值；有子任务时为各子任务得分之和
+	Score float64
+	// Subtasks 是各子任务的得分，没有子任务时为空
+	Subtasks []*SubtaskResult
+	// Cases 是各测试用例的判题结果，顺序与 Task.TestCases 一致，未执行或被取消的测试用例不包含在内
+	Cases []*CaseResult
+}
+
+// CaseResult 表示单个测试用例的判题结果
+type CaseResult struct {
+	// Identity 是测试用例的唯一标识
+	Identity string
+	// Subtask 是测试用例所属子任务的编号
+	Subtask int
+	// Status 是该测试用例的判题状态，取值见 define.SubmitStatus*
+	Status int
+	// TimeUsed 是 CPU 时间（毫秒）
+	TimeUsed int64
+	// MemUsed 是峰值内存（KB）
+	MemUsed int64
+	// ExitCode 是程序的退出码，被信号杀死时为 -1
+	ExitCode int
+	// Score 是该测试用例的得分比例，取值 0 到 1，特判程序可以给出部分分
+	Score float64
+	// Msg 是截断后的标准错误输出、输出差异或特判程序的信息，便于定位错误；运行错误时第一行为错误原因
+	Msg string
+}
+
+// Run 执行判题并返回最终结果
+// 最终结果取按测试用例顺序第一个未通过的测试用例的判定，与各测试用例完成的先后无关；
+// 该函数会阻塞直到判题结束，调用方应在判题协程中调用，而不是在 HTTP 请求中同步调用
+func Run(task *Task) *Result {
+	// 各测试用例的判题结果，按测试用例下标保存，未执行或被取消的测试用例为 nil。
+	cases := make([]*CaseResult, len(task.TestCases))
+	// 按测试用例顺序第一个未通过的测试用例下标，全部通过时为测试用例个数。
+	firstFail := len(task.TestCases)
+	// 已完成的测试用例个数，用于报告进度。
+	finished := 0
+	// 用于并发安全的锁，保护 cases、firstFail 和 finished。
+	var lock sync.Mutex
+	// report 报告判题进度。
+	report := func(stage string, done int) {
+		if task.Progress != nil {
+			task.Progress(stage, done, len(task.TestCases))
+		}
+	}
+
+	// 使用 WaitGroup 等待所有判题 goroutine 完成。
+	var wg sync.WaitGroup
+	// 用于限制并发判题的 goroutine 数量。
+	concurrencyLimit := make(chan struct{}, runtime.NumCPU()) // 根据 CPU 核心数限制并发
+
+	lang, ok := GetLanguage(task.Language)
+	if !ok {
+		return &Result{Status: define.SubmitStatusSystemError, Msg: "不支持的语言：" + task.Language}
+	}
+	mode := task.Mode
+	if mode == "" {
+		// 有子任务时需要全部测试用例的结果才能计分。
+		mode = JudgeModeFirstFail
+		if len(task.Subtasks) > 0 {
+			mode = JudgeModeRunAll
+		}
+	}
+	// 按语言的倍数放宽时间和内存限制。
+	timeFactor, memFactor := lang.LimitFactor()
+	maxRuntime := int(float64(task.MaxRuntime) * timeFactor)
+	maxMem := int(float64(task.MaxMem) * memFactor)
+	outputLimit := task.OutputLimit
+	if outputLimit <= 0 {
+		outputLimit = DefaultOutputLimit
+	}
+
+	// 函数式的问题先把用户代码填入驱动模板，合并后的代码同样需要通过检查。
+	path := task.Path
+	if task.Harness != "" {
+		merged, err := mergeHarness(lang, path, task.Harness)
+		if err != nil {
+			return &Result{Status: define.SubmitStatusSystemError, Msg: "判题系统错误：" + err.Error()}
+		}
+		defer removeHarness(merged)
+		path = merged
+	}
+
+	// 检查代码的合法性。
+	// 这是一个前置检查，如果代码本身非法，则无需执行测试用例。
+	violation, err := lang.Validate(path, task.Policy)
+	if err != nil {
+		log.Printf("Code Check Error: %v", err)
+		return &Result{Status: define.SubmitStatusInvalidCode, Msg: "代码合法性检查失败：" + err.Error()}
+	}
+	if violation != nil {
+		return &Result{Status: define.SubmitStatusInvalidCode, Msg: "无效代码：" + violation.Error()}
+	}
+	if len(task.TestCases) == 0 {
+		// 如果没有测试用例，默认视为正确。
+		res := &Result{Status: define.SubmitStatusAccepted, Msg: "无测试用例，默认正确", Score: 100}
+		if len(task.Subtasks) > 0 {
+			res.Subtasks, res.Score = scoreSubtasks(task.Subtasks, task.TestCases, nil)
+		}
+		return res
+	}
+
+	// 编译阶段：代码只编译一次，所有测试用例共用同一个可执行文件。
+	report(ProgressCompiling, 0)
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return &Result{Status: define.SubmitStatusSystemError, Msg: "判题系统错误：" + err.Error()}
+	}
+	compiled, compileMsg, err := compile(lang, dir)
+	if err != nil {
+		log.Printf("Compile System Error: %v", err)
+		return &Result{Status: define.SubmitStatusSystemError, Msg: "编译环境异常：" + err.Error()}
+	}
+	if !compiled {
+		return &Result{Status: define.SubmitStatusCompileError, Msg: compileMsg}
+	}
+	argv := lang.RunCommand(dir)
+
+	// 有特判程序或交互程序时同样只编译一次，供所有测试用例使用。
+	checkerName := "特判程序"
+	if task.Interactive {
+		checkerName = "交互程序"
+		if task.Checker == nil {
+			return &Result{Status: define.SubmitStatusSystemError, Msg: "交互题缺少交互程序"}
+		}
+	}
+	var checker *preparedChecker
+	if task.Checker != nil {
+		checker, compileMsg, err = prepareChecker(task.Checker)
+		if err != nil {
+			log.Printf("Checker Compile System Error: %v", err)
+			return &Result{Status: define.SubmitStatusSystemError, Msg: checkerName + "编译环境异常：" + err.Error()}
+		}
+		if checker == nil {
+			return &Result{Status: define.SubmitStatusSystemError, Msg: checkerName + "编译错误：" + compileMsg}
+		}
+	}
+
+	report(ProgressRunning, 0)
+
+	// 总超时按测试用例个数放宽，判题已在后台执行，不再受 HTTP 请求时长的限制。
+	// 总超时后所有测试用例都被取消，防止判题进程卡死。
+	totalTimeout := wallTimeout(maxRuntime) * time.Duration(len(task.TestCases)+1)
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout)
+	defer cancel()
+	// 每个测试用例使用独立的 context，first_fail 模式下某个测试用例未通过时取消排在它后面的测试用例。
+	caseCtx := make([]context.Context, len(task.TestCases))
+	caseCancel := make([]context.CancelFunc, len(task.TestCases))
+	for i := range task.TestCases {
+		caseCtx[i], caseCancel[i] = context.WithCancel(ctx)
+		defer caseCancel[i]()
+	}
+	// fail 记录第 i 个测试用例未通过。
+	fail := func(i int) {
+		lock.Lock()
+		defer lock.Unlock()
+		if i >= firstFail {
+			return
+		}
+		if mode == JudgeModeFirstFail {
+			// 排在 firstFail 之后的测试用例已经被取消过。
+			for k := i + 1; k < firstFail; k++ {
+				caseCancel[k]()
+			}
+		}
+		firstFail = i
+	}
+
+	// 按测试用例顺序为每个测试用例启动一个 goroutine。
+	for i, testCase := range task.TestCases {
+		// 避免闭包问题，将 testCase 拷贝一份。
+		i, tc := i, testCase
+		concurrencyLimit <- struct{}{} // 获取一个并发槽位
+		if caseCtx[i].Err() != nil {
+			// 结果已经确定或总超时，后面的测试用例都已被取消，不再启动。
+			<-concurrencyLimit
+			break
+		}
+		wg.Add(1) // 增加 WaitGroup 计数器
+		go func() {
+			defer wg.Done()                       // goroutine 完成时，减少 WaitGroup 计数器
+			defer func() { <-concurrencyLimit }() // 释放并发槽位
+
+			// 记录该测试用例的判题结果，goroutine 退出时保存。
+			cr := &CaseResult{Identity: tc.Identity, Subtask: tc.Subtask, ExitCode: -1}
+			defer func() {
+				lock.Lock()
+				defer lock.Unlock()
+				cases[i] = cr
+				if cr != nil {
+					finished++
+					report(ProgressRunning, finished)
+				}
+			}()
+
+			// 通过执行后端运行编译好的程序，交互题同时运行交互程序。
+			var ex *execution
+			var it *interaction
+			var execErr error
+			if task.Interactive {
+				ex, it, execErr = interact(caseCtx[i], dir, argv, maxRuntime, maxMem, checker, tc, outputLimit)
+			} else {
+				var stdin io.ReadCloser
+				if stdin, execErr = tc.stdin(); execErr == nil {
+					ex, execErr = execute(caseCtx[i], dir, argv, maxRuntime, maxMem, stdin, outputLimit)
+					stdin.Close()
+				}
+			}
+			if execErr != nil {
+				log.Printf("Failed to start test case %s: %v", tc.Identity, execErr)
+				cr.Status, cr.Msg = define.SubmitStatusSystemError, "判题系统错误"
+				fail(i)
+				return
+			}
+			if caseCtx[i].Err() != nil {
+				// 程序在运行中被取消，结果没有意义，不记录。
+				cr = nil
+				return
+			}
+			cr.TimeUsed, cr.MemUsed, cr.ExitCode = ex.timeUsed, ex.memUsed, ex.exitCode
+
+			if it != nil {
+				// 交互程序自身出错或超时。
+				if it.err != nil {
+					log.Printf("Interactor Error for test case %s: %v", tc.Identity, it.err)
+					cr.Status, cr.Msg = define.SubmitStatusSystemError, truncate(it.err.Error(), snippetSize)
+					fail(i)
+					return
+				}
+				// 交互程序先结束并判定未通过，用户程序随后被杀掉或因管道关闭出错，以交互程序的判定为准。
+				if it.first && it.verdict.status != define.SubmitStatusAccepted {
+					cr.Status, cr.Score, cr.Msg = it.verdict.status, it.verdict.score, truncate(it.verdict.msg, snippetSize)
+					fail(i)
+					return
+				}
+			}
+
+			// 运行超时：被墙上时间杀掉，或 CPU 时间超过限制。
+			if ex.timedOut || ex.timeUsed > int64(maxRuntime) {
+				log.Printf("Run Time Out for test case %s. Used: %dms, Max: %dms", tc.Identity, ex.timeUsed, maxRuntime)
+				cr.Status = define.SubmitStatusTimeLimitExceeded
+				fail(i)
+				return
+			}
+
+			// 运行超内存。
+			if ex.memUsed > int64(maxMem) {
+				log.Printf("Out Of Memory for test case %s. Used: %dKB, Max: %dKB", tc.Identity, ex.memUsed, maxMem)
+				cr.Status = define.SubmitStatusMemoryLimitExceeded
+				fail(i)
+				return
+			}
+
+			// 输出超限：输出达到上限时程序已被杀掉。
+			if ex.outputExceeded {
+				log.Printf("Output Limit Exceeded for test case %s. Max: %d bytes", tc.Identity, outputLimit)
+				cr.Status = define.SubmitStatusOutputLimitExceeded
+				fail(i)
+				return
+			}
+
+			// 检查命令执行结果。
+			if ex.err != nil {
+				// 沙箱初始化失败时用户程序没有运行，属于判题系统错误。
+				if ex.sandboxFailed() {
+					log.Printf("Sandbox Error for test case %s: %s", tc.Identity, ex.stderr)
+					cr.Status, cr.Msg = define.SubmitStatusSystemError, "判题系统错误"
+					fail(i)
+					return
+				}
+				// 编译已在前面完成，这里的非零退出和被信号杀死都是运行错误。
+				log.Printf("Command Run Error: %v, Stderr: %s", ex.err, ex.stderr)
+				cr.Status, cr.Msg = define.SubmitStatusRuntimeError, truncate(strings.TrimSpace(runtimeErrorReason(ex)+"\n"+ex.stderr), snippetSize)
+				fail(i)
+				return
+			}
+
+			// 交互题由交互程序判定。
+			if it != nil {
+				cr.Status, cr.Score, cr.Msg = it.verdict.status, it.verdict.score, truncate(it.verdict.msg, snippetSize)
+				if it.verdict.status != define.SubmitStatusAccepted {
+					fail(i)
+				}
+				return
+			}
+
+			// 有特判程序时由特判程序判定。
+			actualOutput := ex.stdout
+			if checker != nil {
+				verdict, checkErr := checker.check(tc, actualOutput)
+				if checkErr != nil {
+					log.Printf("Checker Error for test case %s: %v", tc.Identity, checkErr)
+					cr.Status, cr.Msg = define.SubmitStatusSystemError, truncate(checkErr.Error(), snippetSize)
+					fail(i)
+					return
+				}
+				cr.Status, cr.Score, cr.Msg = verdict.status, verdict.score, truncate(verdict.msg, snippetSize)
+				if verdict.status != define.SubmitStatusAccepted {
+					fail(i)
+				}
+				return
+			}
+
+			// 按题目的比较方式比较输出。
+			expectedOutput, answerErr := tc.answer()
+			if answerErr != nil {
+				log.Printf("Failed to read answer of test case %s: %v", tc.Identity, answerErr)
+				cr.Status, cr.Msg = define.SubmitStatusSystemError, "判题系统错误"
+				fail(i)
+				return
+			}
+			switch compareOutput(task.CompareMode, task.FloatEpsilon, expectedOutput, actualOutput) {
+			case define.SubmitStatusPresentationError: // 只有空白字符不同
+				log.Printf("Presentation Error for test case %s. Expected: '%s', Actual: '%s'", tc.Identity, expectedOutput, actualOutput)
+				cr.Status, cr.Msg = define.SubmitStatusPresentationError, diffSnippet(expectedOutput, actualOutput)
+				fail(i)
+				return
+			case define.SubmitStatusWrongAnswer: // 答案错误
+				log.Printf("Wrong Answer for test case %s. Expected: '%s', Actual: '%s'", tc.Identity, expectedOutput, actualOutput)
+				cr.Status, cr.Msg = define.SubmitStatusWrongAnswer, diffSnippet(expectedOutput, actualOutput)
+				fail(i)
+				return
+			}
+
+			// 所有检查通过，表示该测试用例通过。
+			cr.Status, cr.Score = define.SubmitStatusAccepted, 1
+		}()
+	}
+	// 等待所有测试用例执行完毕或被取消。
+	wg.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	res := &Result{}
+	var score float64
+	systemError := false
+	for _, cr := range cases {
+		if cr == nil {
+			continue
+		}
+		res.Cases = append(res.Cases, cr)
+		res.TimeUsed = max(res.TimeUsed, cr.TimeUsed)
+		res.MemUsed = max(res.MemUsed, cr.MemUsed)
+		score += cr.Score
+		switch cr.Status {
+		case define.SubmitStatusAccepted:
+			res.PassCount++
+		case define.SubmitStatusSystemError:
+			systemError = true
+		}
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Printf("Total Judging Process Timed Out after %v", totalTimeout)
+		res.Status, res.Msg = define.SubmitStatusTimeLimitExceeded, "判题系统总超时"
+	case systemError:
+		// 判题系统错误与用户代码无关，优先于其他判定，便于判题协程重试。
+		res.Status, res.Msg = define.SubmitStatusSystemError, "判题系统错误"
+	case firstFail < len(task.TestCases):
+		res.Status, res.Msg = cases[firstFail].Status, verdictMsg(cases[firstFail])
+	default:
+		res.Status, res.Msg = define.SubmitStatusAccepted, "答案正确"
+	}
+	if len(task.Subtasks) > 0 {
+		res.Subtasks, res.Score = scoreSubtasks(task.Subtasks, task.TestCases, cases)
+	} else {
+		res.Score = score * 100 / float64(len(task.TestCases))
+	}
+	return res
+}
+
+// verdictMsgs 是测试用例未通过时各判题状态对应的提示信息
+var verdictMsgs = map[int]string{
+	define.SubmitStatusWrongAnswer:         "答案错误",
+	define.SubmitStatusPresentationError:   "格式错误",
+	define.SubmitStatusTimeLimitExceeded:   "运行超时",
+	define.SubmitStatusMemoryLimitExceeded: "运行超内存",
+	define.SubmitStatusOutputLimitExceeded: "输出超限",
+	define.SubmitStatusRuntimeError:        "运行错误",
+}
+
+// verdictMsg 返回未通过的测试用例作为最终结果时的提示信息，运行错误时附上错误原因
+func verdictMsg(cr *CaseResult) string {
+	msg := verdictMsgs[cr.Status]
+	if cr.Status == define.SubmitStatusRuntimeError {
+		reason, _, _ := strings.Cut(cr.Msg, "\n")
+		msg += "：" + reason
+	}
+	return msg
+}
+
+// execution 是运行一次程序的结果
+type execution struct {
+	stdout   string
+	stderr   string
+	timeUsed int64  // CPU 时间（毫秒）
+	memUsed  int64  // 峰值内存（KB）
+	exitCode int    // 退出码，被信号杀死时为 -1
+	err      error  // cmd.Run 返回的错误，正常退出时为 nil
+	timedOut bool   // 是否因超过墙上时间被杀掉
+	signal   string // 杀死程序的信号，正常退出时为空
+
+	outputExceeded bool      // 是否因输出超限被杀掉
+	noUsage        bool      // 监控进程是否没有报告用户程序的资源使用
+	exitTime       time.Time // 用户程序结束的时间，没有资源使用报告时为零值
+}
+
+// sandboxFailed 判断程序是否因沙箱初始化失败而没有运行
+func (ex *execution) sandboxFailed() bool {
+	return ex.noUsage && ex.exitCode == sandbox.InitFailedCode && strings.HasPrefix(ex.stderr, "sandbox: ")
+}
+
+// errOutputLimit 表示程序的输出超过了上限
+var errOutputLimit = errors.New("输出超限")
+
+// limitedBuffer 是有上限的输出缓冲区，写满后丢弃多余内容并调用 exceed 杀掉程序
+// 每个缓冲区只由 os/exec 的一个复制协程写入，cmd.Run 返回后才读取，不需要加锁
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	exceed   func()
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errOutputLimit
+	}
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:room])
+		b.exceeded = true
+		b.exceed()
+		return room, errOutputLimit
+	}
+	return b.buf.Write(p)
+}
+
+// execute 通过执行后端在代码目录 dir 下运行 argv，stdin 作为标准输入，ctx 被取消时杀掉程序
+// 时间限制按 CPU 时间判断，墙上时间放宽到两倍，用于杀掉睡眠或阻塞的程序；maxMem 为内存上限（KB）；
+// 标准输出或标准错误输出超过 outputLimit 字节时杀掉程序，避免无限输出耗尽判题机内存；
+// 返回的 error 仅表示程序无法启动前的系统错误
+func execute(ctx context.Context, dir string, argv []string, maxRuntime, maxMem int, stdin io.Reader, outputLimit int) (*execution, error) {
+	ctx, cancel := context.WithTimeout(ctx, wallTimeout(maxRuntime))
+	defer cancel()
+
+	cmd := executor.Command(ctx, dir, maxRuntime, maxMem, argv...)
+	out := &limitedBuffer{limit: outputLimit, exceed: cancel}
+	stderr := &limitedBuffer{limit: outputLimit, exceed: cancel}
+	cmd.Stderr = stderr
+	cmd.Stdout = out
+	// stdin 是文件时直接作为子进程的标准输入，否则由 exec 在独立的 goroutine 中写入，程序没有读完输入就退出时忽略写入错误。
+	cmd.Stdin = stdin
+
+	ex := newExecution(ctx, cmd, cmd.Run()) // 阻塞直到命令完成或超时
+	ex.stdout, ex.stderr = out.buf.String(), stderr.buf.String()
+	ex.outputExceeded = out.exceeded || stderr.exceeded
+	return ex, nil
+}
+
+// newExecution 根据已结束的命令生成运行结果，err 是 cmd.Run 或 cmd.Wait 的返回值，不包含输出
+func newExecution(ctx context.Context, cmd *exec.Cmd, err error) *execution {
+	ex := &execution{exitCode: -1, err: err}
+	if usage, usageErr := sandbox.ReadUsage(cmd); usageErr == nil {
+		// 监控进程报告的是用户程序自己的 CPU 时间和峰值内存。
+		ex.timeUsed = usage.Time.Milliseconds()
+		ex.memUsed = usage.Memory
+		ex.exitCode = usage.ExitCode
+		ex.signal = signalName(usage.Signal)
+		ex.exitTime = usage.ExitTime
+	} else if cmd.ProcessState != nil {
+		// 沙箱初始化失败或监控进程被强制杀掉，只有监控进程自身的统计，其峰值内存包含判题进程的内存，不能使用。
+		ex.noUsage = true
+		ex.timeUsed = (cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()).Milliseconds()
+		ex.exitCode = cmd.ProcessState.ExitCode()
+	}
+	ex.timedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	return ex
+}
+
+// snippetSize 是测试用例结果中保存的标准错误输出或输出差异的最大字节数
+const snippetSize = 512
+
+// truncate 将 s 截断到不超过 n 个字节，不会截断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// diffSnippet 返回预期输出和实际输出中第一处不同的行
+func diffSnippet(expected, actual string) string {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a string
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if i >= len(expectedLines) || i >= len(actualLines) || e != a {
+			return truncate(fmt.Sprintf("第 %d 行不同，预期：%q，实际：%q", i+1, e, a), snippetSize)
+		}
+	}
+	return ""
+}
+
+// wallTimeout 根据 CPU 时间限制（毫秒）计算单个测试用例允许的墙上时间
+func wallTimeout(maxRuntime int) time.Duration {
+	return 2 * time.Duration(maxRuntime) * time.Millisecond
+}
+
+// compileTimeout 是编译阶段的最长时间
+const compileTimeout = 30 * time.Second
+
+// compileMsgSize 是编译错误信息的最大字节数，编译错误信息保存在提交记录的 msg（MySQL text，最多 64KB）中
+const compileMsgSize = 32 << 10
+
+// compile 在代码目录 dir 下通过执行后端执行语言的编译命令，不需要编译的语言直接返回成功
+// 代码本身编译失败时 compiled 为 false，compileMsg 为截断到 compileMsgSize 的编译器输出；
+// 编译器输出超过 DefaultOutputLimit 时杀掉编译器，按编译错误处理；
+// err 仅在编译环境异常（如找不到编译器、沙箱初始化失败）时返回
+func compile(lang Language, dir string) (compiled bool, compileMsg string, err error) {
+	argv := lang.CompileCommand(dir)
+	if len(argv) == 0 {
+		return true, "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), compileTimeout)
+	defer cancel()
+
+	stderr := &limitedBuffer{limit: DefaultOutputLimit, exceed: cancel}
+	cache := compileCacheDir()
+	cmd := executor.Compile(ctx, dir, []string{cache}, lang.CompileEnv(cache), argv...)
+	cmd.Stdout = stderr
+	cmd.Stderr = stderr
+	ex := newExecution(ctx, cmd, cmd.Run())
+	ex.stderr = strings.TrimSpace(truncate(stderr.buf.String(), compileMsgSize))
+	if ex.err != nil {
+		if ex.timedOut {
+			return false, "编译超时", nil
+		}
+		var exitErr *exec.ExitError
+		if ex.sandboxFailed() || !errors.As(ex.err, &exitErr) {
+			return false, "", fmt.Errorf("%w: %s", ex.err, ex.stderr)
+		}
+		// 编译器正常退出但返回非零状态码，或者输出过多被杀掉，说明代码本身有编译错误
+		compileMsg = ex.stderr
+		if compileMsg == "" {
+			compileMsg = "编译错误"
+		}
+		return false, compileMsg, nil
+	}
+	return true, "", nil
+}
+
+// compileCacheDir 返回在多次编译之间保留的编译缓存目录，编译时在沙箱中可写
+var compileCacheDir = sync.OnceValue(func() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "gin_gorm_oj")
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		log.Printf("Failed to create compile cache %s: %v", dir, err)
+	}
+	return dir
+})
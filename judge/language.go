@@ -0,0 +1,182 @@
+package judge
+
+import (
+	"errors"
+	"gin_gorm_oj/utils"
+	"go/scanner"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultLanguage 是未指定语言时使用的语言，兼容只支持 Go 时的提交记录
+const DefaultLanguage = "go"
+
+// Language 描述一种编程语言如何保存、检查、编译和运行
+type Language interface {
+	// Name 是语言的唯一标识，保存在提交记录中，如 go、cpp
+	Name() string
+	// SourceFile 是保存代码时使用的文件名
+	SourceFile() string
+	// CompileCommand 返回在代码目录 dir 下执行的编译命令，不需要编译的语言返回 nil
+	// 编译命令与用户程序一样在沙箱中执行，只能写入代码目录、私有 /tmp 和编译缓存目录
+	CompileCommand(dir string) []string
+	// CompileEnv 返回编译时附加的环境变量，cacheDir 是在多次编译之间保留的编译缓存目录
+	CompileEnv(cacheDir string) []string
+	// RunCommand 返回运行程序的命令，dir 为代码目录的绝对路径，同时也是程序的工作目录
+	// 沙箱会在工作目录上挂载私有 /tmp，解释器读取的代码文件应使用相对路径
+	RunCommand(dir string) []string
+	// LimitFactor 返回该语言相对于题目限制的默认时间和内存倍数
+	LimitFactor() (timeFactor, memFactor float64)
+	// Validate 是编译前的代码检查，代码违反 policy 时返回违反的规则，policy 为空时使用默认规则
+	// 代码的语法错误留给编译阶段报告，不在这里返回
+	Validate(path string, policy *utils.CodePolicy) (*utils.CodeViolation, error)
+}
+
+// builtinLanguage 是内置语言的通用实现
+type builtinLanguage struct {
+	name       string
+	sourceFile string
+	compile    func(dir string) []string
+	compileEnv func(cacheDir string) []string
+	run        func(dir string) []string
+	timeFactor float64
+	memFactor  float64
+	validate   func(path string, policy *utils.CodePolicy) (*utils.CodeViolation, error)
+}
+
+func (l *builtinLanguage) Name() string { return l.name }
+
+func (l *builtinLanguage) SourceFile() string { return l.sourceFile }
+
+func (l *builtinLanguage) CompileCommand(dir string) []string {
+	if l.compile == nil {
+		return nil
+	}
+	return l.compile(dir)
+}
+
+func (l *builtinLanguage) CompileEnv(cacheDir string) []string {
+	if l.compileEnv == nil {
+		return nil
+	}
+	return l.compileEnv(cacheDir)
+}
+
+func (l *builtinLanguage) RunCommand(dir string) []string { return l.run(dir) }
+
+func (l *builtinLanguage) LimitFactor() (float64, float64) { return l.timeFactor, l.memFactor }
+
+func (l *builtinLanguage) Validate(path string, policy *utils.CodePolicy) (*utils.CodeViolation, error) {
+	if l.validate == nil {
+		return nil, nil
+	}
+	return l.validate(path, policy)
+}
+
+// languages 是已注册的语言，键为语言标识
+var languages = make(map[string]Language)
+
+// RegisterLanguage 注册一种语言，已存在的同名语言会被覆盖
+func RegisterLanguage(l Language) {
+	languages[l.Name()] = l
+}
+
+// GetLanguage 根据语言标识获取语言，name 为空时返回默认语言
+func GetLanguage(name string) (Language, bool) {
+	if name == "" {
+		name = DefaultLanguage
+	}
+	l, ok := languages[name]
+	return l, ok
+}
+
+// LanguageNames 返回所有已注册语言的标识，按字母顺序排列
+func LanguageNames() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateGo 按规则检查 Go 代码，语法错误交给编译器报告
+func validateGo(path string, policy *utils.CodePolicy) (*utils.CodeViolation, error) {
+	violation, err := utils.CheckGoCode(path, policy)
+	var syntaxErr scanner.ErrorList
+	if errors.As(err, &syntaxErr) {
+		return nil, nil
+	}
+	return violation, err
+}
+
+// binary 返回编译产物 main 在代码目录下的路径
+func binary(dir string) []string {
+	return []string{filepath.Join(dir, "main")}
+}
+
+func init() {
+	RegisterLanguage(&builtinLanguage{
+		name:       "go",
+		sourceFile: "main.go",
+		compile: func(dir string) []string {
+			return []string{"go", "build", "-o", filepath.Join(dir, "main"), "main.go"}
+		},
+		// 禁用 cgo，编译时不调用 C 编译器；只使用本地工具链，不访问网络；
+		// 构建缓存保留在编译缓存目录中，避免每次编译都重新编译标准库
+		compileEnv: func(cacheDir string) []string {
+			return []string{"CGO_ENABLED=0", "GOTOOLCHAIN=local", "GOPROXY=off", "GOPATH=/tmp/go",
+				"GOCACHE=" + filepath.Join(cacheDir, "go-build")}
+		},
+		run:        binary,
+		timeFactor: 1,
+		memFactor:  1,
+		validate:   validateGo,
+	})
+	RegisterLanguage(&builtinLanguage{
+		name:       "c",
+		sourceFile: "main.c",
+		compile: func(dir string) []string {
+			return []string{"gcc", "-O2", "-std=c11", "-o", filepath.Join(dir, "main"), "main.c", "-lm"}
+		},
+		run:        binary,
+		timeFactor: 1,
+		memFactor:  1,
+	})
+	RegisterLanguage(&builtinLanguage{
+		name:       "cpp",
+		sourceFile: "main.cpp",
+		compile: func(dir string) []string {
+			return []string{"g++", "-O2", "-std=c++17", "-o", filepath.Join(dir, "main"), "main.cpp"}
+		},
+		run:        binary,
+		timeFactor: 1,
+		memFactor:  1,
+	})
+	RegisterLanguage(&builtinLanguage{
+		name:       "python3",
+		sourceFile: "main.py",
+		// 预先编译一次，语法错误按编译错误处理
+		compile: func(dir string) []string {
+			return []string{"python3", "-m", "py_compile", "main.py"}
+		},
+		run: func(dir string) []string {
+			return []string{"python3", "main.py"}
+		},
+		timeFactor: 3,
+		memFactor:  2,
+	})
+	RegisterLanguage(&builtinLanguage{
+		name:       "java",
+		sourceFile: "Main.java",
+		compile: func(dir string) []string {
+			return []string{"javac", "-encoding", "UTF-8", "Main.java"}
+		},
+		run: func(dir string) []string {
+			// JVM 默认按物理内存的 1/64 预先提交初始堆，在内存较大的判题机上会超过 RLIMIT_DATA，这里固定为较小的初始堆
+			return []string{"java", "-Xms16m", "-Xss64m", "-XX:+UseSerialGC", "-cp", ".", "Main"}
+		},
+		timeFactor: 2,
+		memFactor:  2,
+	})
+}
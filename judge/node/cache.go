@@ -0,0 +1,94 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Checksum 返回测试数据的 SHA-256 校验和（十六进制），API 和判题节点使用相同的算法
+func Checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// validChecksum 判断校验和是否为 64 位十六进制字符串，校验和会被用作文件名
+func validChecksum(hash string) bool {
+	b, err := hex.DecodeString(hash)
+	return err == nil && len(b) == sha256.Size
+}
+
+// Cache 是判题节点本地的测试数据缓存
+// 测试数据按校验和保存为文件，内容不变的测试用例只需下载一次，修改过的测试用例因校验和变化会重新下载
+type Cache struct {
+	// Dir 是缓存目录
+	Dir string
+
+	lock sync.Mutex // 串行化下载，避免多个判题协程重复下载同一个测试用例
+}
+
+// Load 返回测试用例的输入和预期输出在缓存中的文件路径，缓存中没有时从 API 下载原始内容并校验
+func (c *Cache) Load(ctx context.Context, client *Client, tc *TestCase) (input, output string, err error) {
+	if !validChecksum(tc.InputHash) || !validChecksum(tc.OutputHash) {
+		return "", "", fmt.Errorf("test case %s has invalid checksum", tc.Identity)
+	}
+	input, output = filepath.Join(c.Dir, tc.InputHash), filepath.Join(c.Dir, tc.OutputHash)
+	if c.cached(tc) {
+		return input, output, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// 拿到锁后逐个检查，其他协程可能已经下载过
+	for _, hash := range []string{tc.InputHash, tc.OutputHash} {
+		if err = c.download(ctx, client, tc.Identity, hash); err != nil {
+			return "", "", fmt.Errorf("download test case %s: %w", tc.Identity, err)
+		}
+	}
+	return input, output, nil
+}
+
+// cached 判断测试用例的输入和预期输出是否都已缓存
+func (c *Cache) cached(tc *TestCase) bool {
+	for _, hash := range []string{tc.InputHash, tc.OutputHash} {
+		if _, err := os.Stat(filepath.Join(c.Dir, hash)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// download 把测试用例中校验和为 hash 的数据下载到缓存，已缓存时直接返回
+// 数据边下载边写入临时文件并计算校验和，校验通过后再重命名，避免进程中途退出或数据损坏时留下错误的缓存
+func (c *Cache) download(ctx context.Context, client *Client, identity, hash string) error {
+	path := filepath.Join(c.Dir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, hash+".tmp-")
+	if err != nil {
+		return err
+	}
+	sum := sha256.New()
+	err = client.TestData(ctx, identity, hash, io.MultiWriter(tmp, sum))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && hex.EncodeToString(sum.Sum(nil)) != hash {
+		err = errors.New("checksum mismatch")
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
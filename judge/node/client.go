@@ -0,0 +1,138 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client 是判题节点访问 API 的客户端
+type Client struct {
+	// Server 是 API 的地址，例如 http://127.0.0.1:8080
+	Server string
+	// Token 是与 API 约定的共享密钥
+	Token string
+	// HTTP 是底层的 HTTP 客户端，为空时使用默认客户端
+	HTTP *http.Client
+}
+
+// response 是 API 的响应格式
+type response struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Register 注册判题节点
+func (c *Client) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	res := new(RegisterResponse)
+	return res, c.do(ctx, http.MethodPost, PathRegister, req, res)
+}
+
+// Heartbeat 发送心跳
+func (c *Client) Heartbeat(ctx context.Context, req *HeartbeatRequest) error {
+	return c.do(ctx, http.MethodPost, PathHeartbeat, req, nil)
+}
+
+// Pull 拉取一个判题任务，没有任务时返回 nil
+func (c *Client) Pull(ctx context.Context, req *PullRequest) (*Job, error) {
+	var job *Job
+	if err := c.do(ctx, http.MethodPost, PathPull, req, &job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// TestData 下载测试用例中校验和为 hash 的输入或预期输出，把原始内容边下载边写入 w
+func (c *Client) TestData(ctx context.Context, identity, hash string, w io.Writer) error {
+	path := PathTestData + "?" + url.Values{"identity": {identity}, "hash": {hash}}.Encode()
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		// 出错时 API 返回 JSON 格式的错误信息
+		if err = decode(http.MethodGet, path, resp, nil); err == nil {
+			err = fmt.Errorf("%s %s: unexpected JSON response", http.MethodGet, path)
+		}
+		return err
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Progress 报告判题进度
+func (c *Client) Progress(ctx context.Context, req *ProgressRequest) error {
+	return c.do(ctx, http.MethodPost, PathProgress, req, nil)
+}
+
+// Result 上报判题结果
+func (c *Client) Result(ctx context.Context, req *ResultRequest) error {
+	return c.do(ctx, http.MethodPost, PathResult, req, nil)
+}
+
+// do 发送请求并把响应中的 data 解析到 out，out 为空时忽略 data
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	resp, err := c.send(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(method, path, resp, out)
+}
+
+// send 以 JSON 格式发送请求体 in，in 为空时不带请求体，HTTP 状态码不是 200 时返回错误，调用方负责关闭响应体
+func (c *Client) send(ctx context.Context, method, path string, in interface{}) (*http.Response, error) {
+	var body *bytes.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.Server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TokenHeader, c.Token)
+	hc := c.HTTP
+	if hc == nil {
+		hc = &http.Client{Timeout: 2 * time.Minute}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: HTTP %d", method, path, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// decode 解析 API 的 JSON 响应，code 不是 200 时返回 msg 作为错误，否则把 data 解析到 out
+func decode(method, path string, resp *http.Response, out interface{}) error {
+	res := new(response)
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if res.Code != http.StatusOK {
+		return errors.New(res.Msg)
+	}
+	if out == nil || len(res.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Data, out)
+}
@@ -0,0 +1,253 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/utils"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pullWait 是拉取任务时最多等待的时间（秒）
+const pullWait = 10
+
+// retryInterval 是请求 API 失败后的重试间隔
+const retryInterval = 2 * time.Second
+
+// Node 是一个独立的判题节点
+type Node struct {
+	// Client 是访问 API 的客户端
+	Client *Client
+	// Name 是判题节点名称
+	Name string
+	// Workers 是判题协程数
+	Workers int
+	// Cache 是测试数据缓存
+	Cache *Cache
+	// OutputLimit 是用户程序输出的上限（字节），为 0 时使用 judge.DefaultOutputLimit
+	OutputLimit int
+
+	identity  string
+	heartbeat time.Duration
+	lock      sync.Mutex
+	running   map[string]string // 任务凭据到提交记录唯一标识的映射
+}
+
+// Identity 返回注册后得到的节点唯一标识
+func (n *Node) Identity() string {
+	return n.identity
+}
+
+// Run 注册判题节点后启动心跳和判题协程，阻塞直到 ctx 被取消
+// API 暂时不可用时会一直重试注册，正在判的任务在 ctx 取消后会被放弃，由 API 在节点离线后重新分配
+func (n *Node) Run(ctx context.Context) error {
+	for {
+		res, err := n.Client.Register(ctx, &RegisterRequest{Name: n.Name, Workers: n.Workers})
+		if err == nil {
+			n.identity, n.heartbeat = res.NodeIdentity, time.Duration(res.Heartbeat)*time.Second
+			break
+		}
+		log.Printf("Judge Node Register Error: %v", err)
+		if !sleep(ctx, retryInterval) {
+			return ctx.Err()
+		}
+	}
+	log.Printf("Judge Node %s registered as %s with %d workers", n.Name, n.identity, n.Workers)
+	n.running = make(map[string]string)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.heartbeatLoop(ctx)
+	}()
+	for i := 0; i < max(n.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.work(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// heartbeatLoop 定时发送心跳，报告正在判题的提交
+func (n *Node) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(max(n.heartbeat, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n.lock.Lock()
+		running := make([]string, 0, len(n.running))
+		for _, identity := range n.running {
+			running = append(running, identity)
+		}
+		n.lock.Unlock()
+		if err := n.Client.Heartbeat(ctx, &HeartbeatRequest{NodeIdentity: n.identity, Running: running}); err != nil && ctx.Err() == nil {
+			log.Printf("Judge Node Heartbeat Error: %v", err)
+		}
+	}
+}
+
+// work 循环拉取任务、判题并上报结果
+func (n *Node) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := n.Client.Pull(ctx, &PullRequest{NodeIdentity: n.identity, Wait: pullWait})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Judge Node Pull Error: %v", err)
+				sleep(ctx, retryInterval)
+			}
+			continue
+		}
+		if job == nil {
+			continue
+		}
+		n.lock.Lock()
+		n.running[job.Ticket] = job.SubmitIdentity
+		n.lock.Unlock()
+
+		req := &ResultRequest{NodeIdentity: n.identity, Ticket: job.Ticket}
+		result, err := n.judge(ctx, job)
+		if err != nil {
+			log.Printf("Judge Node Judge Error: %v, submit: %s", err, job.SubmitIdentity)
+			req.Error = err.Error()
+		} else {
+			req.Result = result
+		}
+		// 结果上报失败时一直重试，任务在 API 的处理中列表里，节点离线前不会被重新分配
+		for {
+			if err = n.Client.Result(ctx, req); err == nil {
+				break
+			}
+			log.Printf("Judge Node Report Error: %v, submit: %s", err, job.SubmitIdentity)
+			if !sleep(ctx, retryInterval) {
+				break
+			}
+		}
+
+		n.lock.Lock()
+		delete(n.running, job.Ticket)
+		n.lock.Unlock()
+	}
+}
+
+// judge 准备代码和测试数据后判题，返回的 error 表示判题节点自身的错误
+func (n *Node) judge(ctx context.Context, job *Job) (*judge.Result, error) {
+	lang, ok := judge.GetLanguage(job.Language)
+	if !ok {
+		return nil, errors.New("不支持的语言：" + job.Language)
+	}
+	task := &judge.Task{
+		Language:     lang.Name(),
+		MaxRuntime:   job.MaxRuntime,
+		MaxMem:       job.MaxMem,
+		CompareMode:  job.CompareMode,
+		FloatEpsilon: job.FloatEpsilon,
+		Mode:         job.JudgeMode,
+		Interactive:  job.Interactive,
+		Harness:      job.Harness,
+		OutputLimit:  n.OutputLimit,
+		TestCases:    make([]*judge.Case, 0, len(job.TestCases)),
+	}
+	if len(job.AllowImports) > 0 || len(job.DenyImports) > 0 {
+		task.Policy = &utils.CodePolicy{AllowImports: job.AllowImports, DenyImports: job.DenyImports}
+	}
+	for _, tc := range job.TestCases {
+		input, output, err := n.Cache.Load(ctx, n.Client, tc)
+		if err != nil {
+			return nil, err
+		}
+		jc := &judge.Case{Identity: tc.Identity, Subtask: tc.Subtask}
+		if tc.Stored {
+			jc.InputFile, jc.OutputFile = input, output
+		} else if jc.Input, jc.Output, err = readCase(input, output); err != nil {
+			return nil, err
+		}
+		task.TestCases = append(task.TestCases, jc)
+	}
+	for _, st := range job.Subtasks {
+		task.Subtasks = append(task.Subtasks, &judge.Subtask{Number: st.Number, Score: st.Score, Depends: st.Depends})
+	}
+
+	path, err := utils.CodeSaveAs([]byte(job.Code), lang.SourceFile())
+	if err != nil {
+		return nil, err
+	}
+	defer removeDir(path)
+	task.Path = path
+	if job.Checker != nil {
+		checkerLang, ok := judge.GetLanguage(job.Checker.Language)
+		if !ok {
+			return nil, errors.New("不支持的特判程序语言：" + job.Checker.Language)
+		}
+		checkerPath, err := utils.CodeSaveAs([]byte(job.Checker.Code), checkerLang.SourceFile())
+		if err != nil {
+			return nil, err
+		}
+		defer removeDir(checkerPath)
+		task.Checker = &judge.Checker{Path: checkerPath, Language: checkerLang.Name()}
+	}
+
+	// 进度由单独的协程按顺序上报，队列满时丢弃，不阻塞判题
+	progress := make(chan *ProgressRequest, 64)
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		for req := range progress {
+			if err := n.Client.Progress(ctx, req); err != nil && ctx.Err() == nil {
+				log.Printf("Judge Node Progress Error: %v, submit: %s", err, job.SubmitIdentity)
+			}
+		}
+	}()
+	task.Progress = func(stage string, done, total int) {
+		select {
+		case progress <- &ProgressRequest{NodeIdentity: n.identity, Ticket: job.Ticket, Stage: stage, Done: done, Total: total}:
+		default:
+		}
+	}
+	result := judge.Run(task)
+	close(progress)
+	<-reported
+	return result, nil
+}
+
+// removeDir 删除代码文件所在的目录
+func removeDir(path string) {
+	dir := filepath.Dir(path)
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("Failed to delete directory %s: %v", dir, err)
+	}
+}
+
+// sleep 等待 d，ctx 被取消时提前返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// readCase 读取缓存的输入和预期输出，用于数据库中以文本保存的测试用例，输入写入标准输入时会追加换行符
+func readCase(inputFile, outputFile string) (input, output string, err error) {
+	b, err := os.ReadFile(inputFile)
+	if err != nil {
+		return "", "", err
+	}
+	input = string(b)
+	if b, err = os.ReadFile(outputFile); err != nil {
+		return "", "", err
+	}
+	return input, string(b), nil
+}
@@ -0,0 +1,135 @@
+// Package node 实现独立判题节点（judged）与 API 服务之间的 HTTP 协议
+// 判题节点向 API 注册后定时发送心跳，循环拉取待判任务，按校验和缓存测试数据，判题完成后上报结果；
+// 协议中的请求和响应都是 JSON，响应沿用 API 的 {"code", "msg", "data"} 格式，code 为 200 表示成功；
+// 只有下载测试数据时响应体是 application/octet-stream 的原始内容，出错时仍返回 JSON
+package node
+
+import (
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+)
+
+// TokenHeader 是判题节点请求中携带共享密钥的请求头
+const TokenHeader = "X-Judge-Token"
+
+// 判题节点协议的接口路径
+const (
+	PathRegister  = "/judge-node/register"
+	PathHeartbeat = "/judge-node/heartbeat"
+	PathPull      = "/judge-node/pull"
+	PathTestData  = "/judge-node/test-data"
+	PathProgress  = "/judge-node/progress"
+	PathResult    = "/judge-node/result"
+)
+
+// RegisterRequest 是判题节点注册的请求
+type RegisterRequest struct {
+	// Name 是判题节点名称，同名节点重新注册时视为重启，沿用原有的节点标识
+	Name string `json:"name"`
+	// Workers 是判题节点的判题协程数
+	Workers int `json:"workers"`
+}
+
+// RegisterResponse 是判题节点注册的响应
+type RegisterResponse struct {
+	// NodeIdentity 是判题节点的唯一标识，后续请求都需要携带
+	NodeIdentity string `json:"node_identity"`
+	// Heartbeat 是心跳间隔（秒），超过约三个间隔没有心跳的节点会被视为离线
+	Heartbeat int `json:"heartbeat"`
+}
+
+// HeartbeatRequest 是判题节点的心跳
+type HeartbeatRequest struct {
+	// NodeIdentity 是判题节点的唯一标识
+	NodeIdentity string `json:"node_identity"`
+	// Running 是正在判题的提交记录的唯一标识
+	Running []string `json:"running"`
+}
+
+// PullRequest 是拉取判题任务的请求
+type PullRequest struct {
+	// NodeIdentity 是判题节点的唯一标识
+	NodeIdentity string `json:"node_identity"`
+	// Wait 是没有任务时最多等待的时间（秒）
+	Wait int `json:"wait"`
+}
+
+// Job 是下发给判题节点的判题任务，没有任务时为空
+type Job struct {
+	// Ticket 是任务凭据，报告进度和上报结果时原样带回
+	Ticket string `json:"ticket"`
+	// SubmitIdentity 是提交记录的唯一标识
+	SubmitIdentity string `json:"submit_identity"`
+	// Language 是代码的语言标识
+	Language string `json:"language"`
+	// Code 是源代码
+	Code string `json:"code"`
+	// MaxRuntime 是最大运行时长（毫秒）
+	MaxRuntime int `json:"max_runtime"`
+	// MaxMem 是最大运行内存（KB）
+	MaxMem int `json:"max_mem"`
+	// CompareMode 是输出比较方式
+	CompareMode string `json:"compare_mode"`
+	// FloatEpsilon 是浮点数比较的误差
+	FloatEpsilon float64 `json:"float_epsilon"`
+	// JudgeMode 是判题模式
+	JudgeMode string `json:"judge_mode"`
+	// AllowImports 和 DenyImports 是 Go 代码检查的规则
+	AllowImports []string `json:"allow_imports"`
+	DenyImports  []string `json:"deny_imports"`
+	// Harness 是函数式问题在该语言下的驱动模板，为空表示直接编译用户代码
+	Harness string `json:"harness"`
+	// Interactive 表示交互题，此时 Checker 是交互程序
+	Interactive bool `json:"interactive"`
+	// Checker 是特判程序或交互程序，为空表示按比较方式判定
+	Checker *Checker `json:"checker"`
+	// TestCases 是测试用例，只包含校验和，内容按需下载
+	TestCases []*TestCase `json:"test_cases"`
+	// Subtasks 是子任务列表，按依赖顺序排列
+	Subtasks []*define.Subtask `json:"subtasks"`
+}
+
+// Checker 是下发给判题节点的特判程序或交互程序
+type Checker struct {
+	// Language 是特判程序的语言标识
+	Language string `json:"language"`
+	// Code 是特判程序的源代码
+	Code string `json:"code"`
+}
+
+// TestCase 是下发给判题节点的测试用例，输入和预期输出按校验和从 PathTestData 下载
+type TestCase struct {
+	// Identity 是测试用例的唯一标识
+	Identity string `json:"identity"`
+	// Subtask 是测试用例所属子任务的编号
+	Subtask int `json:"subtask"`
+	// InputHash 和 OutputHash 是输入和预期输出的 SHA-256 校验和（十六进制）
+	InputHash  string `json:"input_hash"`
+	OutputHash string `json:"output_hash"`
+	// Stored 表示测试数据保存为文件，输入原样作为标准输入，见 judge.Case 的 InputFile
+	Stored bool `json:"stored"`
+}
+
+// ProgressRequest 是判题进度的报告
+type ProgressRequest struct {
+	// NodeIdentity 是判题节点的唯一标识
+	NodeIdentity string `json:"node_identity"`
+	// Ticket 是任务凭据
+	Ticket string `json:"ticket"`
+	// Stage、Done 和 Total 见 judge.Task.Progress
+	Stage string `json:"stage"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// ResultRequest 是判题结果的上报
+type ResultRequest struct {
+	// NodeIdentity 是判题节点的唯一标识
+	NodeIdentity string `json:"node_identity"`
+	// Ticket 是任务凭据
+	Ticket string `json:"ticket"`
+	// Error 是判题节点自身的错误（例如下载测试数据失败），不为空时由 API 按重试规则处理
+	Error string `json:"error,omitempty"`
+	// Result 是判题结果，Error 不为空时忽略
+	Result *judge.Result `json:"result,omitempty"`
+}
@@ -0,0 +1,123 @@
+package judge
+
+import (
+	"context"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/utils"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// RunTask 描述一次自定义输入运行，只编译运行代码，不与预期输出比较
+type RunTask struct {
+	// Path 是代码文件的路径
+	Path string
+	// Language 是代码的语言标识，为空时使用 DefaultLanguage
+	Language string
+	// MaxRuntime 是最大运行时长（毫秒）
+	MaxRuntime int
+	// MaxMem 是最大运行内存（KB）
+	MaxMem int
+	// Input 是程序的标准输入
+	Input string
+	// Policy 是编译前代码检查的规则，为空时使用默认规则
+	Policy *utils.CodePolicy
+	// OutputLimit 是标准输出和标准错误输出各自的最大字节数，超出时杀掉程序并返回已有的输出，为 0 时使用 DefaultOutputLimit
+	OutputLimit int
+	// Harness 是函数式问题的驱动模板，见 Task.Harness
+	Harness string
+}
+
+// RunResult 表示一次自定义输入运行的结果
+type RunResult struct {
+	// Status 是运行状态：define.SubmitStatusAccepted 表示程序正常结束，
+	// 其他取值表示编译错误、无效代码、超时、超内存、输出超限、运行错误或系统错误
+	Status int
+	// Msg 是提示信息，编译错误时为编译器输出
+	Msg string
+	// Stdout 是程序的标准输出
+	Stdout string
+	// Stderr 是程序的标准错误输出
+	Stderr string
+	// TimeUsed 是 CPU 时间（毫秒）
+	TimeUsed int64
+	// MemUsed 是峰值内存（KB）
+	MemUsed int64
+	// ExitCode 是程序的退出码，被信号杀死或没有运行时为 -1
+	ExitCode int
+}
+
+// RunCode 编译代码并使用给定的输入运行一次，限制与判题相同，由调用方负责删除代码目录
+func RunCode(task *RunTask) *RunResult {
+	lang, ok := GetLanguage(task.Language)
+	if !ok {
+		return &RunResult{Status: define.SubmitStatusSystemError, Msg: "不支持的语言：" + task.Language, ExitCode: -1}
+	}
+	timeFactor, memFactor := lang.LimitFactor()
+	maxRuntime := int(float64(task.MaxRuntime) * timeFactor)
+	maxMem := int(float64(task.MaxMem) * memFactor)
+	outputLimit := task.OutputLimit
+	if outputLimit <= 0 {
+		outputLimit = DefaultOutputLimit
+	}
+
+	path := task.Path
+	if task.Harness != "" {
+		merged, err := mergeHarness(lang, path, task.Harness)
+		if err != nil {
+			return &RunResult{Status: define.SubmitStatusSystemError, Msg: "判题系统错误：" + err.Error(), ExitCode: -1}
+		}
+		defer removeHarness(merged)
+		path = merged
+	}
+
+	violation, err := lang.Validate(path, task.Policy)
+	if err != nil {
+		return &RunResult{Status: define.SubmitStatusInvalidCode, Msg: "代码合法性检查失败：" + err.Error(), ExitCode: -1}
+	}
+	if violation != nil {
+		return &RunResult{Status: define.SubmitStatusInvalidCode, Msg: "无效代码：" + violation.Error(), ExitCode: -1}
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return &RunResult{Status: define.SubmitStatusSystemError, Msg: "判题系统错误：" + err.Error(), ExitCode: -1}
+	}
+	compiled, compileMsg, err := compile(lang, dir)
+	if err != nil {
+		log.Printf("Compile System Error: %v", err)
+		return &RunResult{Status: define.SubmitStatusSystemError, Msg: "编译环境异常：" + err.Error(), ExitCode: -1}
+	}
+	if !compiled {
+		return &RunResult{Status: define.SubmitStatusCompileError, Msg: compileMsg, ExitCode: -1}
+	}
+
+	ex, err := execute(context.Background(), dir, lang.RunCommand(dir), maxRuntime, maxMem, strings.NewReader(task.Input+"\n"), outputLimit)
+	if err != nil {
+		log.Printf("Failed to start program: %v", err)
+		return &RunResult{Status: define.SubmitStatusSystemError, Msg: "判题系统错误", ExitCode: -1}
+	}
+	res := &RunResult{
+		Status:   define.SubmitStatusAccepted,
+		Msg:      "运行结束",
+		Stdout:   ex.stdout,
+		Stderr:   ex.stderr,
+		TimeUsed: ex.timeUsed,
+		MemUsed:  ex.memUsed,
+		ExitCode: ex.exitCode,
+	}
+	switch {
+	case ex.timedOut || ex.timeUsed > int64(maxRuntime):
+		res.Status, res.Msg = define.SubmitStatusTimeLimitExceeded, "运行超时"
+	case ex.memUsed > int64(maxMem):
+		res.Status, res.Msg = define.SubmitStatusMemoryLimitExceeded, "运行超内存"
+	case ex.err != nil && ex.sandboxFailed():
+		log.Printf("Sandbox Error: %s", ex.stderr)
+		res.Status, res.Msg, res.Stderr = define.SubmitStatusSystemError, "判题系统错误", ""
+	case ex.outputExceeded:
+		res.Status, res.Msg = define.SubmitStatusOutputLimitExceeded, "输出超限"
+	case ex.err != nil:
+		res.Status, res.Msg = define.SubmitStatusRuntimeError, "运行错误："+runtimeErrorReason(ex)
+	}
+	return res
+}
@@ -0,0 +1,54 @@
+package judge
+
+import (
+	"strconv"
+	"strings"
+)
+
+// reasonSize 是运行错误原因的最大字节数
+const reasonSize = 256
+
+// runtimeErrorReason 概括运行错误的原因
+// 优先从标准错误输出中找出 panic 或异常的类型，再附上杀死程序的信号，都没有时给出退出码
+func runtimeErrorReason(ex *execution) string {
+	var parts []string
+	if line := exceptionLine(ex.stderr); line != "" {
+		parts = append(parts, line)
+	}
+	if ex.signal != "" {
+		parts = append(parts, "signal "+ex.signal)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "exit status "+strconv.Itoa(ex.exitCode))
+	}
+	return truncate(strings.Join(parts, "，"), reasonSize)
+}
+
+// exceptionLine 从标准错误输出中找出描述 panic 或异常类型的一行，找不到时返回空字符串
+// 支持 Go 的 panic 和 fatal error、Java 未捕获的异常、C++ 未捕获的异常以及 Python 的 Traceback
+func exceptionLine(stderr string) string {
+	lines := strings.Split(stderr, "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "panic: "), strings.HasPrefix(line, "fatal error: "):
+			return strings.TrimSuffix(line, " [recovered]")
+		case strings.HasPrefix(line, "Exception in thread "):
+			// 形如 Exception in thread "main" java.lang.ArithmeticException: / by zero
+			if i := strings.Index(line, "\" "); i >= 0 {
+				return line[i+2:]
+			}
+			return line
+		case strings.HasPrefix(line, "terminate called after throwing"):
+			return line
+		case strings.HasPrefix(line, "Traceback (most recent call last):"):
+			// Python 的异常类型和信息在 Traceback 的最后一个不缩进的行
+			for j := len(lines) - 1; j >= 0; j-- {
+				if l := strings.TrimRight(lines[j], "\r"); l != "" && !strings.HasPrefix(l, " ") {
+					return l
+				}
+			}
+		}
+	}
+	return ""
+}
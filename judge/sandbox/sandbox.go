@@ -0,0 +1,76 @@
+// Package sandbox 在独立的 Linux 命名空间中运行不可信的用户程序
+//
+// 沙箱通过重新执行当前程序（/proc/self/exe）实现：子进程在新的 mount、pid、net、ipc、uts、user
+// 命名空间中启动，由本包的 init 函数接管，完成根文件系统、资源限制和 seccomp 过滤器的设置后，
+// 作为监控进程 fork 出真正的用户程序并等待它结束。
+//
+// 沙箱的根文件系统是一个新的只读 tmpfs，只以只读方式绑定挂载 Paths 中的工具链目录和工作目录，
+// 另有私有的 /tmp、/proc 和少数设备文件；配置文件、测试数据等宿主机上的其他文件在沙箱中都不可见。
+//
+// 判题进程通过 vfork 启动子进程，子进程在 execve 后仍会继承判题进程的峰值内存，
+// 所以判题进程拿到的 rusage 不能作为用户程序的内存统计。用户程序由监控进程 fork，
+// 监控进程通过 wait4 取得用户程序自己的 rusage，写入判题进程传入的文件后退出，见 ReadUsage。
+// 这样得到的峰值内存只会额外包含监控进程 fork 时的常驻内存（几 MB）。
+//
+// 本包只依赖标准库，且导入路径排在 gin_gorm_oj/models 之前，Go 会先执行本包的 init，
+// 从而保证沙箱子进程不会执行数据库和 Redis 的初始化。
+package sandbox
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// errNoUsage 表示监控进程没有报告用户程序的资源使用
+var errNoUsage = errors.New("sandbox: no usage report")
+
+// initArg 是监控进程的第一个命令行参数，用于识别重新执行的沙箱初始化进程
+const initArg = "__oj_sandbox_init__"
+
+// InitFailedCode 是沙箱初始化失败时的退出码，此时用户程序尚未开始运行，
+// 标准错误输出以 "sandbox: " 开头
+const InitFailedCode = 125
+
+// Limits 是沙箱中用户程序的资源限制
+type Limits struct {
+	// CPUTime 是 CPU 时间上限（秒），超过后内核会发送 SIGXCPU/SIGKILL
+	CPUTime uint64
+	// FileSize 是可写文件的最大字节数
+	FileSize uint64
+	// Processes 是最多可创建的进程（线程）数
+	Processes uint64
+	// OpenFiles 是最多可打开的文件描述符数
+	OpenFiles uint64
+	// TmpSize 是私有 /tmp 的最大字节数
+	TmpSize uint64
+	// Memory 是每个进程可写私有内存（堆、数据段、匿名映射）的最大字节数，即 RLIMIT_DATA，0 表示不限制
+	Memory uint64
+}
+
+// Usage 是用户程序结束后的资源使用统计和退出状态
+type Usage struct {
+	// Time 是用户态和内核态 CPU 时间之和
+	Time time.Duration
+	// Memory 是峰值常驻内存（KB）
+	Memory int64
+	// ExitCode 是退出码，被信号杀死时为 -1
+	ExitCode int
+	// Signal 是杀死用户程序的信号，正常退出时为 0
+	Signal syscall.Signal
+	// ExitTime 是监控进程观察到用户程序结束的时间，无法获取时为零值
+	ExitTime time.Time
+}
+
+// Paths 是沙箱中以只读方式可见的宿主机路径，通常是编译器、解释器和动态链接库所在的目录，不存在的路径会被跳过
+// 默认值适用于常见的 Linux 发行版，需在调用 Available 和 Command 之前修改
+var Paths = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/etc/alternatives", "/etc/ld.so.cache"}
+
+// DefaultLimits 是沙箱的默认资源限制
+var DefaultLimits = Limits{
+	CPUTime:   10,
+	FileSize:  64 << 20,
+	Processes: 64,
+	OpenFiles: 64,
+	TmpSize:   64 << 20,
+}
@@ -0,0 +1,628 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func init() {
+	// 监控进程在这里完成初始化、运行用户程序并报告资源使用，不会返回
+	if len(os.Args) > 1 && os.Args[1] == initArg {
+		runInit(os.Args[2:])
+	}
+}
+
+// defaultPath 是沙箱中的 PATH 环境变量
+const defaultPath = "/usr/local/bin:/usr/bin:/bin"
+
+// nobody 是以 root 运行判题进程时用户程序在宿主机上使用的 uid 和 gid
+// 内核不对宿主机的 root 用户检查 RLIMIT_NPROC，用户程序必须以非特权用户运行，进程数限制才会生效
+const nobody = 65534
+
+// Command 构造在沙箱中运行 argv 的命令，dir 为用户程序的工作目录，必须是绝对路径且不能是根目录，在沙箱中只读
+// 返回的命令可以像普通的 exec.Cmd 一样设置标准输入输出并运行，结束后通过 ReadUsage 读取用户程序的资源使用
+// 判题进程以 root 运行时，用户程序以宿主机上的 nobody 用户运行，工作目录中的文件需要对其他用户可读
+func Command(ctx context.Context, dir string, limits Limits, argv ...string) *exec.Cmd {
+	uid := 0
+	if os.Getuid() == 0 {
+		uid = nobody
+	}
+	return isolated(ctx, dir, limits, uid, nil, argv)
+}
+
+// CommandWritable 与 Command 相同，writable 中的宿主机目录以可写方式挂载在原路径，
+// 判题进程以 root 运行时这些目录同样需要对 nobody 用户可写
+func CommandWritable(ctx context.Context, dir string, limits Limits, writable []string, argv ...string) *exec.Cmd {
+	uid := 0
+	if os.Getuid() == 0 {
+		uid = nobody
+	}
+	return isolated(ctx, dir, limits, uid, writable, argv)
+}
+
+// Compile 构造在沙箱中执行编译命令 argv 的命令：工作目录 dir 和 writable 中的宿主机目录（如编译缓存）
+// 以可写方式挂载在原路径，env 是附加的环境变量；编译器在宿主机 PATH 中所在的目录排在沙箱 PATH 的最前面，
+// 该目录需要在 Paths 之下才能在沙箱中找到
+func Compile(ctx context.Context, dir string, limits Limits, writable, env []string, argv ...string) *exec.Cmd {
+	cmd := isolated(ctx, dir, limits, 0, append([]string{dir}, writable...), argv)
+	if path, err := exec.LookPath(argv[0]); err == nil {
+		cmd.Env[0] = "PATH=" + filepath.Dir(path) + ":" + defaultPath
+	}
+	cmd.Env = append(cmd.Env, env...)
+	return cmd
+}
+
+// isolated 构造在新的命名空间中运行 argv 的监控进程，writable 是以可写方式挂载的宿主机目录
+// uid 不为 0 时用户程序以该 uid 和同名 gid 运行，它们在命名空间内外相同，只有判题进程以 root 运行时可以使用
+func isolated(ctx context.Context, dir string, limits Limits, uid int, writable, argv []string) *exec.Cmd {
+	cmd := command(ctx, dir, limits, true, uid, writable, argv)
+	cmd.Env = []string{"PATH=" + defaultPath, "HOME=/tmp", "LANG=C.UTF-8"}
+	cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS | syscall.CLONE_NEWUSER
+	// 在新的 user 命名空间中把当前用户映射为 root，使其有权限设置挂载点，但在宿主机上没有任何额外权限
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	if uid != 0 {
+		// 监控进程仍以命名空间内的 root 完成挂载，用户程序切换到额外映射的非特权用户，并清空附加组
+		cmd.SysProcAttr.UidMappings = append(cmd.SysProcAttr.UidMappings, syscall.SysProcIDMap{ContainerID: uid, HostID: uid, Size: 1})
+		cmd.SysProcAttr.GidMappings = append(cmd.SysProcAttr.GidMappings, syscall.SysProcIDMap{ContainerID: uid, HostID: uid, Size: 1})
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = true
+	}
+	return cmd
+}
+
+// Monitor 构造不做隔离的命令：用户程序以判题进程的身份和环境变量运行，只设置资源限制并统计资源使用
+func Monitor(ctx context.Context, dir string, limits Limits, argv ...string) *exec.Cmd {
+	return command(ctx, dir, limits, false, 0, nil, argv)
+}
+
+// killDelay 是通知监控进程结束用户程序后，等待它报告资源使用并退出的最长时间
+const killDelay = time.Second
+
+// command 构造重新执行当前程序的监控进程，isolate 表示是否在新的命名空间中隔离用户程序，uid 和 writable 见 isolated
+func command(ctx context.Context, dir string, limits Limits, isolate bool, uid int, writable, argv []string) *exec.Cmd {
+	args := []string{
+		initArg,
+		"isolate=" + strconv.FormatBool(isolate),
+		"uid=" + strconv.Itoa(uid),
+		"dir=" + dir,
+		"cpu=" + strconv.FormatUint(limits.CPUTime, 10),
+		"fsize=" + strconv.FormatUint(limits.FileSize, 10),
+		"nproc=" + strconv.FormatUint(limits.Processes, 10),
+		"nofile=" + strconv.FormatUint(limits.OpenFiles, 10),
+		"tmp=" + strconv.FormatUint(limits.TmpSize, 10),
+		"mem=" + strconv.FormatUint(limits.Memory, 10),
+		"paths=" + strings.Join(Paths, ":"),
+		"rw=" + strings.Join(writable, ":"),
+		"--",
+	}
+	args = append(args, argv...)
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Pdeathsig: syscall.SIGKILL, // 判题进程退出时一并杀掉监控进程，用户程序随监控进程退出
+	}
+	// 超时或被取消时先让监控进程杀掉用户程序并报告资源使用，监控进程没有及时退出时再强制杀掉
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = killDelay
+	// 资源使用报告写入一个已删除的临时文件，只能通过传给监控进程的文件描述符访问
+	report, err := os.CreateTemp("", "oj-usage-*")
+	if err != nil {
+		cmd.Err = fmt.Errorf("create usage report: %w", err)
+		return cmd
+	}
+	os.Remove(report.Name())
+	cmd.ExtraFiles = []*os.File{report}
+	return cmd
+}
+
+// reportFd 是监控进程中资源使用报告的文件描述符，即 cmd.ExtraFiles[0]
+const reportFd = 3
+
+// ReadUsage 读取监控进程报告的用户程序资源使用，必须在命令结束后调用，调用后报告文件被关闭
+// 沙箱初始化失败、用户程序没有启动或监控进程被强制杀掉时没有报告，返回错误
+func ReadUsage(cmd *exec.Cmd) (*Usage, error) {
+	if len(cmd.ExtraFiles) == 0 || cmd.ExtraFiles[0] == nil {
+		return nil, errNoUsage
+	}
+	report := cmd.ExtraFiles[0]
+	defer report.Close()
+	buf := make([]byte, 128)
+	n, _ := report.ReadAt(buf, 0)
+	if n == 0 {
+		return nil, errNoUsage
+	}
+	var utime, stime, maxrss, exited int64
+	var status uint32
+	if _, err := fmt.Sscan(string(buf[:n]), &utime, &stime, &maxrss, &status, &exited); err != nil {
+		return nil, fmt.Errorf("parse usage report: %w", err)
+	}
+	ws := syscall.WaitStatus(status)
+	usage := &Usage{
+		Time:     time.Duration(utime+stime) * time.Microsecond,
+		Memory:   maxrss, // Linux 下 rusage.Maxrss 的单位本身就是 KB
+		ExitCode: ws.ExitStatus(),
+		ExitTime: time.Unix(0, exited),
+	}
+	if ws.Signaled() {
+		usage.Signal = ws.Signal()
+	}
+	return usage, nil
+}
+
+var (
+	availableOnce sync.Once
+	availableErr  error
+)
+
+// Available 检查当前主机是否支持沙箱（例如是否允许非特权用户创建 user 命名空间）
+// 检查结果会被缓存，返回 nil 表示可用
+func Available() error {
+	availableOnce.Do(func() {
+		// 不带用户程序运行一次沙箱初始化，完整执行挂载、资源限制和 seccomp 的设置
+		dir, err := os.MkdirTemp("", "oj-sandbox-*")
+		if err != nil {
+			availableErr = err
+			return
+		}
+		defer os.Remove(dir)
+		cmd := Command(context.Background(), dir, DefaultLimits)
+		out, err := cmd.CombinedOutput()
+		ReadUsage(cmd) // 关闭报告文件
+		if err != nil {
+			availableErr = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+		}
+	})
+	return availableErr
+}
+
+// runInit 是监控进程的入口，args 为 command 传入的参数
+func runInit(args []string) {
+	// seccomp 过滤器只作用于当前线程，必须保证设置过滤器和 fork 用户程序在同一个线程上
+	runtime.LockOSThread()
+
+	conf := make(map[string]string)
+	var argv []string
+	for i, arg := range args {
+		if arg == "--" {
+			argv = args[i+1:]
+			break
+		}
+		if k, v, ok := strings.Cut(arg, "="); ok {
+			conf[k] = v
+		}
+	}
+	isolate := conf["isolate"] == "true"
+	// 只有通过 Command 创建的子进程才会是新 pid 命名空间中的 1 号进程；
+	// 直接带 initArg 运行时拒绝继续，避免修改宿主机的挂载点
+	if isolate && os.Getpid() != 1 {
+		initFail("init", fmt.Errorf("not running in a new pid namespace"))
+	}
+	limits := Limits{
+		CPUTime:   parseUint(conf["cpu"]),
+		FileSize:  parseUint(conf["fsize"]),
+		Processes: parseUint(conf["nproc"]),
+		OpenFiles: parseUint(conf["nofile"]),
+		TmpSize:   parseUint(conf["tmp"]),
+		Memory:    parseUint(conf["mem"]),
+	}
+
+	if isolate {
+		paths, writable := strings.Split(conf["paths"], ":"), strings.Split(conf["rw"], ":")
+		if err := setupRoot(paths, conf["dir"], writable, limits.TmpSize); err != nil {
+			initFail("setup root", err)
+		}
+	}
+	if err := os.Chdir(conf["dir"]); err != nil {
+		initFail("chdir", err)
+	}
+	// 用户程序在切换根目录之后查找，只能运行沙箱中可见的程序
+	var path string
+	if len(argv) > 0 {
+		var err error
+		if path, err = lookPath(argv[0]); err != nil {
+			initFail("look path", err)
+		}
+	}
+	// 判题进程通过 SIGTERM 通知监控进程结束用户程序；需在设置内存限制之前注册，之后不再创建新的线程
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM)
+	if err := setupRlimits(limits); err != nil {
+		initFail("setrlimit", err)
+	}
+	if isolate {
+		if err := setupSeccomp(); err != nil {
+			initFail("seccomp", err)
+		}
+		if err := dropPrivileges(); err != nil {
+			initFail("drop privileges", err)
+		}
+	}
+	if path == "" {
+		os.Exit(0) // 可用性检查，不运行用户程序
+	}
+
+	// 用户程序不能继承报告文件，否则可以伪造自己的资源使用
+	report := os.NewFile(reportFd, "usage report")
+	if report == nil {
+		initFail("open usage report", syscall.EBADF)
+	}
+	syscall.CloseOnExec(reportFd)
+	sys := &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL} // 监控进程被强制杀掉时一并杀掉用户程序
+	if uid := uint32(parseUint(conf["uid"])); uid != 0 {
+		sys.Credential = &syscall.Credential{Uid: uid, Gid: uid}
+	}
+	pid, err := syscall.ForkExec(path, argv, &syscall.ProcAttr{
+		Env:   os.Environ(),
+		Files: []uintptr{0, 1, 2},
+		Sys:   sys,
+	})
+	if err != nil {
+		initFail("exec", err)
+	}
+	go func() {
+		<-stop
+		syscall.Kill(pid, syscall.SIGKILL)
+	}()
+	var ws syscall.WaitStatus
+	var ru syscall.Rusage
+	for {
+		if _, err = syscall.Wait4(pid, &ws, 0, &ru); err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		initFail("wait", err)
+	}
+	exited := time.Now()
+	fmt.Fprintf(report, "%d %d %d %d %d\n", ru.Utime.Nano()/1000, ru.Stime.Nano()/1000, ru.Maxrss, uint32(ws), exited.UnixNano())
+	if ws.Signaled() {
+		os.Exit(128 + int(ws.Signal()))
+	}
+	os.Exit(ws.ExitStatus())
+}
+
+// initFail 输出沙箱初始化错误并退出，退出码 InitFailedCode 与用户程序的退出码区分
+func initFail(step string, err error) {
+	fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", step, err)
+	os.Exit(InitFailedCode)
+}
+
+func parseUint(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
+
+// lookPath 在 PATH 中查找可执行文件，沙箱初始化阶段不依赖 os/exec 的查找逻辑
+func lookPath(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+	for _, dir := range strings.Split(os.Getenv("PATH"), ":") {
+		path := dir + "/" + name
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s: executable file not found in $PATH", name)
+}
+
+// newRoot 是构造沙箱根文件系统时临时挂载 tmpfs 的位置，切换根目录后不再可见
+const newRoot = "/tmp"
+
+// devices 是沙箱中可用的设备文件
+var devices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// setupRoot 在 tmpfs 上构造沙箱的根文件系统并通过 pivot_root 切换过去，宿主机的其他文件在沙箱中都不可见：
+// paths 中的宿主机路径和工作目录 dir 以只读方式绑定挂载在原路径，符号链接按原样重建；
+// writable 中的宿主机目录（可以包含 dir）以可写方式绑定挂载在原路径；
+// /tmp 是大小为 tmpSize 的私有 tmpfs，/proc 只包含沙箱内的进程，/dev 中只有 devices
+func setupRoot(paths []string, dir string, writable []string, tmpSize uint64) error {
+	// 挂载事件不再传播到宿主机
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make / private: %w", err)
+	}
+	// 宿主机路径和工作目录可能位于 /tmp 下，需在挂载新的根目录之前打开
+	var binds []*bindMount
+	for _, p := range append(paths, devices...) {
+		if p = filepath.Clean(p); p == "/" || !filepath.IsAbs(p) {
+			continue
+		}
+		b, err := openBind(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // 不同发行版的目录布局不同，不存在的路径直接跳过
+		}
+		if err != nil {
+			return err
+		}
+		binds = append(binds, b)
+	}
+	if dir = filepath.Clean(dir); dir == "/" || !filepath.IsAbs(dir) {
+		return fmt.Errorf("invalid work dir %q", dir)
+	}
+	work, err := openBind(dir)
+	if err != nil {
+		return err
+	}
+	works := []*bindMount{work}
+	for _, p := range writable {
+		if p = filepath.Clean(p); p == "/" || !filepath.IsAbs(p) {
+			continue
+		}
+		if p == dir {
+			work.writable = true
+			continue
+		}
+		b, err := openBind(p)
+		if err != nil {
+			return err
+		}
+		b.writable = true
+		works = append(works, b)
+	}
+
+	if err = syscall.Mount("tmpfs", newRoot, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=1m,mode=755"); err != nil {
+		return fmt.Errorf("mount root: %w", err)
+	}
+	for _, b := range binds {
+		if err = b.mount(); err != nil {
+			return err
+		}
+	}
+	// 私有 /tmp，进程退出后随命名空间一起销毁；工作目录和可写目录可能位于 /tmp 下，需在 /tmp 挂载之后绑定
+	if err = os.Mkdir(newRoot+"/tmp", 0755); err != nil {
+		return err
+	}
+	tmpOpts := fmt.Sprintf("size=%d,mode=1777", tmpSize)
+	if err = syscall.Mount("tmpfs", newRoot+"/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, tmpOpts); err != nil {
+		return fmt.Errorf("mount /tmp: %w", err)
+	}
+	for _, b := range works {
+		if err = b.mount(); err != nil {
+			return err
+		}
+	}
+	// 新的 /proc 只能看到沙箱内的进程；部分容器环境不允许挂载 proc，此时沙箱中没有 /proc
+	if err = os.Mkdir(newRoot+"/proc", 0555); err != nil {
+		return err
+	}
+	_ = syscall.Mount("proc", newRoot+"/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+
+	// 切换根目录并卸载原来的根目录
+	if err = syscall.Chdir(newRoot); err != nil {
+		return err
+	}
+	if err = syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot root: %w", err)
+	}
+	if err = syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	if err = syscall.Chdir("/"); err != nil {
+		return err
+	}
+	if err = syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount / read-only: %w", err)
+	}
+	return nil
+}
+
+// bindMount 是需要出现在沙箱根文件系统中的一个宿主机路径
+type bindMount struct {
+	path string
+	link string // 符号链接的目标，不为空时在沙箱中重建符号链接而不是绑定挂载
+	fd   int    // 以 O_PATH 打开的宿主机路径
+	dir  bool
+
+	writable bool // 是否以可写方式挂载
+}
+
+// openBind 打开宿主机路径 path，符号链接只读取其目标
+func openBind(path string) (*bindMount, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &bindMount{path: path, dir: fi.IsDir()}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		b.link, err = os.Readlink(path)
+		return b, err
+	}
+	if b.fd, err = syscall.Open(path, oPath|syscall.O_CLOEXEC, 0); err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return b, nil
+}
+
+// mount 在新的根目录中的原路径上绑定挂载宿主机路径，除 writable 外都是只读的，并关闭打开的描述符
+func (b *bindMount) mount() error {
+	target := newRoot + b.path
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if b.link != "" {
+		return os.Symlink(b.link, target)
+	}
+	defer syscall.Close(b.fd)
+	if b.dir {
+		if err := os.Mkdir(target, 0755); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(target, nil, 0644); err != nil {
+		return err
+	}
+	source := fmt.Sprintf("/proc/self/fd/%d", b.fd)
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", b.path, err)
+	}
+	if b.writable {
+		return nil
+	}
+	// 在 user 命名空间中重新挂载时必须保留原挂载点被锁定的 nosuid、nodev、noexec 和访问时间标志
+	var st syscall.Statfs_t
+	if err := syscall.Fstatfs(b.fd, &st); err != nil {
+		return fmt.Errorf("statfs %s: %w", b.path, err)
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for flag, ms := range map[int64]uintptr{
+		stNosuid:     syscall.MS_NOSUID,
+		stNodev:      syscall.MS_NODEV,
+		stNoexec:     syscall.MS_NOEXEC,
+		stNoatime:    syscall.MS_NOATIME,
+		stNodiratime: syscall.MS_NODIRATIME,
+		stRelatime:   syscall.MS_RELATIME,
+	} {
+		if int64(st.Flags)&flag != 0 {
+			flags |= ms
+		}
+	}
+	if int64(st.Flags)&(stNoatime|stRelatime) == 0 {
+		flags |= syscall.MS_STRICTATIME
+	}
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", b.path, err)
+	}
+	return nil
+}
+
+// oPath 是 O_PATH，syscall 包中没有导出该常量
+const oPath = 0x200000
+
+// statfs 返回的挂载标志，参见 linux/statfs.h
+const (
+	stNosuid     = 0x2
+	stNodev      = 0x4
+	stNoexec     = 0x8
+	stNoatime    = 0x400
+	stNodiratime = 0x800
+	stRelatime   = 0x1000
+)
+
+// rlimitNproc 是 RLIMIT_NPROC，syscall 包中没有导出该常量
+const rlimitNproc = 0x6
+
+// setupRlimits 设置用户程序的资源限制，限制会被 fork 出的用户程序继承
+func setupRlimits(limits Limits) error {
+	rlimits := []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, limits.CPUTime},
+		{syscall.RLIMIT_FSIZE, limits.FileSize},
+		{rlimitNproc, limits.Processes},
+		{syscall.RLIMIT_NOFILE, limits.OpenFiles},
+		{syscall.RLIMIT_DATA, limits.Memory},
+		{syscall.RLIMIT_CORE, 0},
+	}
+	for _, r := range rlimits {
+		if r.value == 0 && r.resource != syscall.RLIMIT_CORE {
+			continue // 0 表示不限制
+		}
+		if err := syscall.Setrlimit(r.resource, &syscall.Rlimit{Cur: r.value, Max: r.value}); err != nil {
+			return fmt.Errorf("resource %d: %w", r.resource, err)
+		}
+	}
+	return nil
+}
+
+// seccomp 相关常量，参见 linux/seccomp.h、linux/filter.h 和 linux/prctl.h
+const (
+	prSetDumpable     = 4
+	prSetSecurebits   = 28
+	prSetNoNewPrivs   = 38
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	bpfLdWAbs  = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJeqK    = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJgeK    = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfRetK    = 0x06 // BPF_RET | BPF_K
+	offsetNr   = 0    // seccomp_data.nr
+	offsetArch = 4    // seccomp_data.arch
+
+	secbitNoroot       = 1 << 0
+	secbitNorootLocked = 1 << 1
+
+	// x32SyscallBit 之上的系统调用号属于 x32 ABI，一律拒绝
+	x32SyscallBit = 0x40000000
+)
+
+// sockFilter 对应内核的 struct sock_filter
+type sockFilter struct {
+	code uint16
+	jt   uint8
+	jf   uint8
+	k    uint32
+}
+
+// sockFprog 对应内核的 struct sock_fprog
+type sockFprog struct {
+	len    uint16
+	filter *sockFilter
+}
+
+// setupSeccomp 安装 seccomp 过滤器：架构不匹配时直接杀死进程，危险的系统调用返回 EPERM
+func setupSeccomp() error {
+	if auditArch == 0 {
+		return nil // 当前架构未提供系统调用表，只依赖命名空间和资源限制
+	}
+	deny := uint32(seccompRetErrno | uint32(syscall.EPERM))
+	prog := []sockFilter{
+		{code: bpfLdWAbs, k: offsetArch},
+		{code: bpfJeqK, jt: 1, jf: 0, k: auditArch},
+		{code: bpfRetK, k: seccompRetKillProcess},
+		{code: bpfLdWAbs, k: offsetNr},
+		{code: bpfJgeK, jt: 0, jf: 1, k: x32SyscallBit},
+		{code: bpfRetK, k: deny},
+	}
+	for _, nr := range deniedSyscalls {
+		prog = append(prog,
+			sockFilter{code: bpfJeqK, jt: 0, jf: 1, k: nr},
+			sockFilter{code: bpfRetK, k: deny},
+		)
+	}
+	prog = append(prog, sockFilter{code: bpfRetK, k: seccompRetAllow})
+
+	// 禁止通过 execve 获得新权限，这也是非特权进程安装 seccomp 过滤器的前提
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("set no_new_privs: %w", errno)
+	}
+	fprog := sockFprog{len: uint16(len(prog)), filter: &prog[0]}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&fprog))); errno != 0 {
+		return fmt.Errorf("set seccomp filter: %w", errno)
+	}
+	runtime.KeepAlive(prog)
+	return nil
+}
+
+// dropPrivileges 使用户程序在 execve 后不再拥有命名空间内 root 的能力（capability），
+// 并把监控进程设为不可转储，用户程序无法通过 /proc/1 访问监控进程的文件描述符
+func dropPrivileges() error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSecurebits, secbitNoroot|secbitNorootLocked, 0); errno != 0 {
+		return fmt.Errorf("set securebits: %w", errno)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetDumpable, 0, 0); errno != 0 {
+		return fmt.Errorf("set dumpable: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// Command 在非 Linux 平台上不可用，返回的命令在启动时报错，不会在没有隔离的情况下运行用户程序
+func Command(ctx context.Context, dir string, limits Limits, argv ...string) *exec.Cmd {
+	cmd := Monitor(ctx, dir, limits, argv...)
+	cmd.Err = errUnsupported
+	return cmd
+}
+
+// CommandWritable 在非 Linux 平台上不可用，返回的命令在启动时报错
+func CommandWritable(ctx context.Context, dir string, limits Limits, writable []string, argv ...string) *exec.Cmd {
+	return Command(ctx, dir, limits, argv...)
+}
+
+// Compile 在非 Linux 平台上不可用，返回的命令在启动时报错
+func Compile(ctx context.Context, dir string, limits Limits, writable, env []string, argv ...string) *exec.Cmd {
+	return Command(ctx, dir, limits, argv...)
+}
+
+// Monitor 在非 Linux 平台上直接运行 argv，不设置资源限制
+func Monitor(ctx context.Context, dir string, limits Limits, argv ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// ReadUsage 在非 Linux 平台上只能从进程状态中读取 CPU 时间和退出码，无法可靠获取峰值内存
+func ReadUsage(cmd *exec.Cmd) (*Usage, error) {
+	if cmd.ProcessState == nil {
+		return nil, errNoUsage
+	}
+	state := cmd.ProcessState
+	return &Usage{Time: state.UserTime() + state.SystemTime(), ExitCode: state.ExitCode()}, nil
+}
+
+// errUnsupported 表示当前平台不支持沙箱
+var errUnsupported = errors.New("sandbox is only supported on linux")
+
+// Available 在非 Linux 平台上总是返回错误
+func Available() error {
+	return errUnsupported
+}
@@ -0,0 +1,40 @@
+package sandbox
+
+import "syscall"
+
+// auditArch 是 AUDIT_ARCH_X86_64
+const auditArch = 0xc000003e
+
+// deniedSyscalls 是沙箱中禁止调用的系统调用，调用时返回 EPERM
+var deniedSyscalls = []uint32{
+	syscall.SYS_PTRACE,
+	syscall.SYS_MOUNT,
+	syscall.SYS_UMOUNT2,
+	syscall.SYS_PIVOT_ROOT,
+	syscall.SYS_CHROOT,
+	syscall.SYS_UNSHARE,
+	308, // setns
+	syscall.SYS_REBOOT,
+	syscall.SYS_KEXEC_LOAD,
+	320, // kexec_file_load
+	syscall.SYS_INIT_MODULE,
+	313, // finit_module
+	syscall.SYS_DELETE_MODULE,
+	syscall.SYS_SWAPON,
+	syscall.SYS_SWAPOFF,
+	syscall.SYS_SETHOSTNAME,
+	syscall.SYS_SETDOMAINNAME,
+	syscall.SYS_ACCT,
+	syscall.SYS_SETTIMEOFDAY,
+	syscall.SYS_CLOCK_SETTIME,
+	syscall.SYS_ADJTIMEX,
+	syscall.SYS_PERF_EVENT_OPEN,
+	321, // bpf
+	syscall.SYS_KEYCTL,
+	syscall.SYS_ADD_KEY,
+	syscall.SYS_REQUEST_KEY,
+	310, // process_vm_readv
+	311, // process_vm_writev
+	323, // userfaultfd
+	304, // open_by_handle_at
+}
@@ -0,0 +1,40 @@
+package sandbox
+
+import "syscall"
+
+// auditArch 是 AUDIT_ARCH_AARCH64
+const auditArch = 0xc00000b7
+
+// deniedSyscalls 是沙箱中禁止调用的系统调用，调用时返回 EPERM
+var deniedSyscalls = []uint32{
+	syscall.SYS_PTRACE,
+	syscall.SYS_MOUNT,
+	syscall.SYS_UMOUNT2,
+	syscall.SYS_PIVOT_ROOT,
+	syscall.SYS_CHROOT,
+	syscall.SYS_UNSHARE,
+	syscall.SYS_SETNS,
+	syscall.SYS_REBOOT,
+	syscall.SYS_KEXEC_LOAD,
+	294, // kexec_file_load
+	syscall.SYS_INIT_MODULE,
+	syscall.SYS_FINIT_MODULE,
+	syscall.SYS_DELETE_MODULE,
+	syscall.SYS_SWAPON,
+	syscall.SYS_SWAPOFF,
+	syscall.SYS_SETHOSTNAME,
+	syscall.SYS_SETDOMAINNAME,
+	syscall.SYS_ACCT,
+	syscall.SYS_SETTIMEOFDAY,
+	syscall.SYS_CLOCK_SETTIME,
+	syscall.SYS_ADJTIMEX,
+	syscall.SYS_PERF_EVENT_OPEN,
+	syscall.SYS_BPF,
+	syscall.SYS_KEYCTL,
+	syscall.SYS_ADD_KEY,
+	syscall.SYS_REQUEST_KEY,
+	syscall.SYS_PROCESS_VM_READV,
+	syscall.SYS_PROCESS_VM_WRITEV,
+	282, // userfaultfd
+	265, // open_by_handle_at
+}
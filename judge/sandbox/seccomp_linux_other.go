@@ -0,0 +1,9 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+// auditArch 为 0 表示当前架构未提供系统调用表，不安装 seccomp 过滤器
+const auditArch = 0
+
+// deniedSyscalls 在未适配的架构上为空
+var deniedSyscalls []uint32
@@ -0,0 +1,31 @@
+//go:build linux
+
+package judge
+
+import "syscall"
+
+// signalNames 是常见的导致运行错误的信号名称
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGSYS:  "SIGSYS",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+// signalName 返回杀死用户程序的信号的名称，形如 "SIGSEGV (segmentation fault)"，sig 为 0 时返回空字符串
+func signalName(sig syscall.Signal) string {
+	if sig == 0 {
+		return ""
+	}
+	if name, ok := signalNames[sig]; ok {
+		return name + " (" + sig.String() + ")"
+	}
+	return sig.String()
+}
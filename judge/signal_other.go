@@ -0,0 +1,10 @@
+//go:build !linux
+
+package judge
+
+import "syscall"
+
+// signalName 在非 Linux 平台上不区分信号，返回空字符串
+func signalName(sig syscall.Signal) string {
+	return ""
+}
@@ -0,0 +1,63 @@
+package judge
+
+import "math"
+
+// Subtask 描述一个子任务
+// 子任务的得分为分值乘以其中测试用例得分比例的最小值，依赖的子任务没有全部满分时不得分
+type Subtask struct {
+	// Number 是子任务编号，测试用例通过 Case.Subtask 引用
+	Number int
+	// Score 是子任务的分值
+	Score int
+	// Depends 是依赖的子任务编号
+	Depends []int
+}
+
+// SubtaskResult 表示单个子任务的得分
+type SubtaskResult struct {
+	// Number 是子任务编号
+	Number int
+	// Score 是子任务的得分，取值 0 到子任务的分值
+	Score float64
+	// Passed 表示子任务是否满分且依赖的子任务全部满分
+	Passed bool
+}
+
+// scoreSubtasks 根据各测试用例的结果计算子任务的得分，返回各子任务的结果和总分
+// 没有执行完的测试用例按 0 分计算，不属于任何子任务的测试用例不计分
+func scoreSubtasks(subtasks []*Subtask, testCases []*Case, cases []*CaseResult) ([]*SubtaskResult, float64) {
+	// 每个子任务的得分比例取其中测试用例得分比例的最小值
+	ratio := make(map[int]float64, len(subtasks))
+	for _, st := range subtasks {
+		ratio[st.Number] = 1
+	}
+	for i, tc := range testCases {
+		if _, ok := ratio[tc.Subtask]; !ok {
+			continue
+		}
+		var score float64
+		if cases[i] != nil {
+			score = cases[i].Score
+		}
+		ratio[tc.Subtask] = math.Min(ratio[tc.Subtask], score)
+	}
+
+	// 按给定顺序计算，依赖只能指向已经计算过的子任务
+	passed := make(map[int]bool, len(subtasks))
+	results := make([]*SubtaskResult, 0, len(subtasks))
+	var total float64
+	for _, st := range subtasks {
+		r := ratio[st.Number]
+		for _, dep := range st.Depends {
+			if !passed[dep] {
+				r = 0
+				break
+			}
+		}
+		passed[st.Number] = r == 1
+		sr := &SubtaskResult{Number: st.Number, Score: r * float64(st.Score), Passed: r == 1}
+		total += sr.Score
+		results = append(results, sr)
+	}
+	return results, total
+}
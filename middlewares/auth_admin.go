@@ -27,6 +27,7 @@ func AuthAdminCheck() gin.HandlerFunc { // 定义 AuthAdminCheck 函数，返回
 			})
 			return // 终止函数执行。
 		}
-		c.Next() // 如果认证和管理员检查都通过，则继续执行请求链中的下一个处理函数（或下一个中间件）。
+		c.Set("user_claims", userClaim) // 将用户声明信息保存到上下文中，供后续处理函数获取当前管理员。
+		c.Next()                        // 如果认证和管理员检查都通过，则继续执行请求链中的下一个处理函数（或下一个中间件）。
 	}
 }
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// JudgeNodeAuth 校验独立判题节点请求头 X-Judge-Token 中的共享密钥
+// token 为空时拒绝所有请求，即不接受独立判题节点
+func JudgeNodeAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got := c.GetHeader("X-Judge-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.Abort()
+			c.JSON(http.StatusOK, gin.H{
+				"code": http.StatusUnauthorized,
+				"msg":  "Unauthorized Judge Node",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// QueryToken 在请求头没有 Authorization 时使用查询参数 token 代替
+// 浏览器的 EventSource 不能设置请求头，只应在 SSE 等长连接接口上使用，放在 AuthUserCheck 之前
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("token"); token != "" {
+				c.Request.Header.Set("Authorization", token)
+			}
+		}
+		c.Next()
+	}
+}
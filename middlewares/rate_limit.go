@@ -0,0 +1,155 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter 是请求频率限制器，按固定时间窗口计数
+type RateLimiter interface {
+	// Allow 记录 key 的一次请求，返回是否允许；不允许时 retryAfter 为距离窗口结束的时间
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// redisRateLimiter 基于 Redis 计数的限流器，多个节点共享计数
+type redisRateLimiter struct {
+	rdb    *redis.Client
+	prefix string
+	limit  int64
+	window time.Duration
+}
+
+// NewRedisRateLimiter 创建基于 Redis 的限流器，每个 key 在 window 内最多允许 limit 次请求
+func NewRedisRateLimiter(rdb *redis.Client, name string, limit int, window time.Duration) RateLimiter {
+	return &redisRateLimiter{rdb: rdb, prefix: "oj:ratelimit:" + name + ":", limit: int64(limit), window: window}
+}
+
+func (l *redisRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	key = l.prefix + key
+	count, err := l.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if count == 1 {
+		// 窗口内的第一次请求，设置过期时间作为窗口长度
+		if err = l.rdb.Expire(ctx, key, l.window).Err(); err != nil {
+			return false, 0, err
+		}
+	}
+	if count <= l.limit {
+		return true, 0, nil
+	}
+	ttl, err := l.rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if ttl < 0 {
+		// 设置过期时间失败导致 key 永不过期时补上过期时间
+		l.rdb.Expire(ctx, key, l.window)
+		ttl = l.window
+	}
+	return false, ttl, nil
+}
+
+// memoryRateLimiter 基于进程内计数的限流器
+type memoryRateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+}
+
+// rateWindow 是一个 key 当前时间窗口的计数
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewMemoryRateLimiter 创建进程内的限流器，每个 key 在 window 内最多允许 limit 次请求
+func NewMemoryRateLimiter(limit int, window time.Duration) RateLimiter {
+	return &memoryRateLimiter{limit: limit, window: window, windows: make(map[string]*rateWindow)}
+}
+
+func (l *memoryRateLimiter) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		if len(l.windows) >= 10000 {
+			// 清理已经过期的窗口，避免 key 无限增长
+			for k, v := range l.windows {
+				if now.Sub(v.start) >= l.window {
+					delete(l.windows, k)
+				}
+			}
+		}
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+	w.count++
+	if w.count <= l.limit {
+		return true, 0, nil
+	}
+	return false, w.start.Add(l.window).Sub(now), nil
+}
+
+// fallbackRateLimiter 优先使用 primary，primary 出错时退化为 fallback
+type fallbackRateLimiter struct {
+	primary  RateLimiter
+	fallback RateLimiter
+}
+
+// NewRateLimiter 创建限流器，Redis 可用时各节点共享计数，Redis 出错时退化为进程内计数
+func NewRateLimiter(rdb *redis.Client, name string, limit int, window time.Duration) RateLimiter {
+	return &fallbackRateLimiter{
+		primary:  NewRedisRateLimiter(rdb, name, limit, window),
+		fallback: NewMemoryRateLimiter(limit, window),
+	}
+}
+
+func (l *fallbackRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	allowed, retryAfter, err := l.primary.Allow(ctx, key)
+	if err == nil {
+		return allowed, retryAfter, nil
+	}
+	log.Printf("Rate Limiter Error: %v, fallback to memory", err)
+	return l.fallback.Allow(ctx, key)
+}
+
+// RateLimit 返回按用户限制请求频率的中间件，需要放在 AuthUserCheck 之后使用
+func RateLimit(limiter RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, _ := c.Get("user_claims")
+		userClaim, ok := u.(*UserClaims)
+		if !ok {
+			c.Abort()
+			c.JSON(http.StatusOK, gin.H{
+				"code": http.StatusUnauthorized,
+				"msg":  "Unauthorized Authorization",
+			})
+			return
+		}
+		allowed, retryAfter, err := limiter.Allow(c.Request.Context(), userClaim.Identity)
+		if err != nil {
+			log.Printf("Rate Limit Error: %v", err)
+		}
+		if err == nil && !allowed {
+			seconds := int(retryAfter.Seconds() + 0.999) // 向上取整
+			c.Abort()
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusOK, gin.H{
+				"code": http.StatusTooManyRequests,
+				"msg":  "请求过于频繁，请 " + strconv.Itoa(seconds) + " 秒后重试",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -58,7 +58,7 @@ func Init() *gorm.DB { //
 	// 迁移数据表。
 	// 注意: 数据库表结构初次运行后可注销此行，或仅在开发环境开启，生产环境慎用或手动迁移。
 	// _ = db.AutoMigrate(&CategoryBasic{}, &ContestBasic{}, &ContestProblem{},
-	// 	&ContestUser{}, &ProblemBasic{}, &ProblemCategory{}, &SubmitBasic{}, &TestCase{}, &UserBasic{})
+	// 	&ContestUser{}, &JudgeNode{}, &ProblemBasic{}, &ProblemCategory{}, &ProblemChecker{}, &ProblemRevision{}, &ProblemSubtask{}, &ProblemTemplate{}, &RejudgeTask{}, &SubmitBasic{}, &SubmitCaseResult{}, &TestCase{}, &UserBasic{})
 
 	// 获取底层的 *sql.DB 实例，用于配置连接池参数。
 	sqlDB, err := db.DB()
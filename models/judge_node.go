@@ -0,0 +1,43 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// 判题节点的状态
+const (
+	JudgeNodeOnline  = "online"  // 在线
+	JudgeNodeOffline = "offline" // 超时没有心跳，任务已被重新分配
+)
+
+// JudgeNode 表示一个独立的判题节点（judged）
+// 判题节点注册后定时发送心跳，超时没有心跳的节点被标记为离线，其处理中的任务放回待判队列
+type JudgeNode struct {
+	// ID 是该记录的主键，用于唯一标识每个判题节点
+	ID uint `gorm:"primarykey;" json:"id"`
+	// CreatedAt 记录该记录的创建时间
+	CreatedAt MyTime `json:"created_at"`
+	// UpdatedAt 记录该记录的最后更新时间
+	UpdatedAt MyTime `json:"updated_at"`
+	// DeletedAt 是软删除标记，使用 gorm 的软删除功能
+	DeletedAt gorm.DeletedAt `gorm:"index;" json:"deleted_at"`
+	// Identity 是判题节点的唯一标识，同时用作该节点处理中队列的名称
+	Identity string `gorm:"column:identity;type:varchar(36);uniqueIndex;" json:"identity"`
+	// Name 是判题节点名称，同名节点重新注册时沿用原有记录
+	Name string `gorm:"column:name;type:varchar(100);uniqueIndex;" json:"name"`
+	// Address 是判题节点最近一次请求的来源地址
+	Address string `gorm:"column:address;type:varchar(64);" json:"address"`
+	// Workers 是判题节点的判题协程数
+	Workers int `gorm:"column:workers;type:int(11);" json:"workers"`
+	// Running 是最近一次心跳时正在判题的提交数
+	Running int `gorm:"column:running;type:int(11);default:0;" json:"running"`
+	// Status 是判题节点的状态：online、offline
+	Status string `gorm:"column:status;type:varchar(20);" json:"status"`
+	// HeartbeatAt 是最近一次心跳的时间
+	HeartbeatAt MyTime `gorm:"column:heartbeat_at;" json:"heartbeat_at"`
+}
+
+// TableName 指定该模型对应的数据库表名
+func (table *JudgeNode) TableName() string {
+	return "judge_node"
+}
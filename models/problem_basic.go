@@ -1,7 +1,10 @@
 package models
 
 import (
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/utils"
 	"gorm.io/gorm"
+	"strings"
 )
 
 // ProblemBasic 表示问题基础信息的模型结构
@@ -27,8 +30,38 @@ type ProblemBasic struct {
 	MaxRuntime int `gorm:"column:max_runtime;type:int(11);" json:"max_runtime"`
 	// MaxMem 是问题的最大运行内存
 	MaxMem int `gorm:"column:max_mem;type:int(11);" json:"max_mem"`
+	// Languages 是允许提交的语言标识，以逗号分隔，为空表示允许所有语言
+	Languages string `gorm:"column:languages;type:varchar(255);" json:"languages"`
+	// CompareMode 是输出比较方式：exact、trailing、token、token_ci、float，没有特判程序时生效
+	CompareMode string `gorm:"column:compare_mode;type:varchar(20);default:exact;" json:"compare_mode"`
+	// FloatEpsilon 是 float 比较方式下允许的绝对或相对误差，0 表示使用默认误差 1e-6
+	FloatEpsilon float64 `gorm:"column:float_epsilon;type:double;default:0;" json:"float_epsilon"`
+	// JudgeMode 是判题模式：first_fail 在第一个未通过的测试用例后停止，run_all 执行全部测试用例，为空表示有子任务时 run_all，否则 first_fail
+	JudgeMode string `gorm:"column:judge_mode;type:varchar(20);" json:"judge_mode"`
+	// ProblemType 是问题类型：standard、interactive，交互题的 CheckerVersion 指定的是交互程序
+	ProblemType string `gorm:"column:problem_type;type:varchar(20);default:standard;" json:"problem_type"`
+	// AllowImports 是允许导入的包，以逗号分隔，为空表示使用默认的白名单
+	AllowImports string `gorm:"column:allow_imports;type:varchar(1024);" json:"allow_imports"`
+	// DenyImports 是禁止导入的包，以逗号分隔，优先于 AllowImports
+	DenyImports string `gorm:"column:deny_imports;type:varchar(1024);" json:"deny_imports"`
+	// CheckerVersion 是当前使用的特判程序版本，0 表示不使用特判程序，按输出完全一致判定
+	CheckerVersion int `gorm:"column:checker_version;type:int(11);default:0;" json:"checker_version"`
+	// TestDataVersion 是测试数据版本，修改问题（测试用例、限制等）、上传测试数据或切换特判程序时递增，用于使判题结果缓存失效
+	TestDataVersion int `gorm:"column:test_data_version;type:int(11);default:1;" json:"test_data_version"`
+	// TestDataChecksum 是最近一次上传的测试数据的校验和，由各测试用例输入输出文件的校验和按顺序计算，随问题一起提交测试用例时为空
+	TestDataChecksum string `gorm:"column:test_data_checksum;type:varchar(64);" json:"test_data_checksum"`
+	// Revision 是问题当前的版本号，对应 ProblemRevision 中最新的快照，0 表示还没有保存过快照
+	Revision int `gorm:"column:revision;type:int(11);default:0;" json:"revision"`
 	// TestCases 是关联的测试用例列表，通过 problem_identity 关联到 TestCase 表
 	TestCases []*TestCase `gorm:"foreignKey:problem_identity;references:identity;" json:"test_cases"`
+	// Subtasks 是关联的子任务列表，通过 problem_identity 关联到 ProblemSubtask 表，为空时按测试用例平均计分
+	Subtasks []*ProblemSubtask `gorm:"foreignKey:problem_identity;references:identity;" json:"subtasks"`
+	// FunctionSignature 是函数式问题要求用户实现的函数签名，仅用于展示，例如 func twoSum(nums []int, target int) []int
+	FunctionSignature string `gorm:"column:function_signature;type:varchar(512);" json:"function_signature"`
+	// Templates 是函数式问题各语言的代码模板，通过 problem_identity 关联到 ProblemTemplate 表，为空表示普通的读写标准输入输出的问题
+	Templates []*ProblemTemplate `gorm:"foreignKey:problem_identity;references:identity;" json:"templates"`
+	// Samples 是展示给用户的样例，由样例测试用例生成，只在问题详情中填充，不保存到数据库
+	Samples []*define.ProblemSample `gorm:"-" json:"samples,omitempty"`
 	// PassNum 是问题的通过次数
 	PassNum int64 `gorm:"column:pass_num;type:int(11);" json:"pass_num"`
 	// SubmitNum 是问题的提交次数
@@ -40,6 +73,61 @@ func (table *ProblemBasic) TableName() string {
 	return "problem_basic"
 }
 
+// AllowLanguage 判断该问题是否允许使用指定的语言提交
+// 函数式问题只允许使用有代码模板的语言，需要预加载 Templates
+func (table *ProblemBasic) AllowLanguage(language string) bool {
+	if len(table.Templates) > 0 && table.Template(language) == nil {
+		return false
+	}
+	if table.Languages == "" {
+		return true
+	}
+	for _, l := range strings.Split(table.Languages, ",") {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// Template 返回函数式问题在指定语言下的代码模板，需要预加载 Templates，没有该语言的模板时返回 nil
+func (table *ProblemBasic) Template(language string) *ProblemTemplate {
+	for _, t := range table.Templates {
+		if t.Language == language {
+			return t
+		}
+	}
+	return nil
+}
+
+// Harness 返回指定语言的驱动模板，需要预加载 Templates，不是函数式问题时返回空字符串
+func (table *ProblemBasic) Harness(language string) string {
+	if t := table.Template(language); t != nil {
+		return t.Harness
+	}
+	return ""
+}
+
+// Interactive 判断该问题是否为交互题
+func (table *ProblemBasic) Interactive() bool {
+	return table.ProblemType == define.ProblemTypeInteractive
+}
+
+// CodePolicy 返回该问题的代码检查规则，没有配置时返回 nil 表示使用默认规则
+func (table *ProblemBasic) CodePolicy() *utils.CodePolicy {
+	if table.AllowImports == "" && table.DenyImports == "" {
+		return nil
+	}
+	policy := new(utils.CodePolicy)
+	if table.AllowImports != "" {
+		policy.AllowImports = strings.Split(table.AllowImports, ",")
+	}
+	if table.DenyImports != "" {
+		policy.DenyImports = strings.Split(table.DenyImports, ",")
+	}
+	return policy
+}
+
 // GetProblemList 根据关键字和分类标识查询问题列表
 // 返回一个 GORM 的查询构建器，可用于进一步的查询操作
 func GetProblemList(keyword, categoryIdentity string) *gorm.DB {
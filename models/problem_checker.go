@@ -0,0 +1,31 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProblemChecker 表示问题特判程序的一个版本
+// 每次上传特判程序都会新增一个版本，问题通过 CheckerVersion 指定当前使用的版本
+type ProblemChecker struct {
+	// ID 是该记录的主键，用于唯一标识每个特判程序版本
+	ID uint `gorm:"primarykey;" json:"id"`
+	// CreatedAt 记录该版本的创建时间
+	CreatedAt MyTime `json:"created_at"`
+	// UpdatedAt 记录该版本的最后更新时间
+	UpdatedAt MyTime `json:"updated_at"`
+	// DeletedAt 是软删除标记，使用 gorm 的软删除功能
+	DeletedAt gorm.DeletedAt `gorm:"index;" json:"deleted_at"`
+	// ProblemIdentity 表示该特判程序所属问题的唯一标识
+	ProblemIdentity string `gorm:"column:problem_identity;type:varchar(36);index;" json:"problem_identity"`
+	// Version 是特判程序的版本号，同一问题内从 1 开始递增
+	Version int `gorm:"column:version;type:int(11);" json:"version"`
+	// Language 是特判程序的语言标识
+	Language string `gorm:"column:language;type:varchar(20);" json:"language"`
+	// Code 是特判程序的源代码
+	Code string `gorm:"column:code;type:mediumtext;" json:"code"`
+}
+
+// TableName 指定该模型对应的数据库表名
+func (table *ProblemChecker) TableName() string {
+	return "problem_checker"
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProblemRevision 表示问题的一个版本快照
+// 创建、修改问题以及修改测试数据、切换特判程序时都会保存一个快照，问题通过 Revision 记录当前的版本号；
+// 测试数据只保存各测试用例在 storage 中的校验和，恢复时从 storage 读取
+type ProblemRevision struct {
+	// ID 是该记录的主键，用于唯一标识每个版本快照
+	ID uint `gorm:"primarykey;" json:"id"`
+	// CreatedAt 记录该版本的创建时间
+	CreatedAt MyTime `json:"created_at"`
+	// UpdatedAt 记录该版本的最后更新时间
+	UpdatedAt MyTime `json:"updated_at"`
+	// DeletedAt 是软删除标记，使用 gorm 的软删除功能
+	DeletedAt gorm.DeletedAt `gorm:"index;" json:"deleted_at"`
+	// ProblemIdentity 表示该版本所属问题的唯一标识
+	ProblemIdentity string `gorm:"column:problem_identity;type:varchar(36);index;" json:"problem_identity"`
+	// Revision 是版本号，同一问题内从 1 开始递增
+	Revision int `gorm:"column:revision;type:int(11);" json:"revision"`
+	// Comment 说明产生该版本的操作，例如修改问题、上传测试数据、恢复到某个版本
+	Comment string `gorm:"column:comment;type:varchar(255);" json:"comment"`
+	// Title 是问题标题
+	Title string `gorm:"column:title;type:varchar(255);" json:"title"`
+	// Content 是问题内容
+	Content string `gorm:"column:content;type:text;" json:"content,omitempty"`
+	// MaxRuntime 是最大运行时长
+	MaxRuntime int `gorm:"column:max_runtime;type:int(11);" json:"max_runtime"`
+	// MaxMem 是最大运行内存
+	MaxMem int `gorm:"column:max_mem;type:int(11);" json:"max_mem"`
+	// Languages、CompareMode 等判题设置与 ProblemBasic 的同名字段相同
+	Languages         string  `gorm:"column:languages;type:varchar(255);" json:"languages"`
+	CompareMode       string  `gorm:"column:compare_mode;type:varchar(20);" json:"compare_mode"`
+	FloatEpsilon      float64 `gorm:"column:float_epsilon;type:double;default:0;" json:"float_epsilon"`
+	JudgeMode         string  `gorm:"column:judge_mode;type:varchar(20);" json:"judge_mode"`
+	ProblemType       string  `gorm:"column:problem_type;type:varchar(20);" json:"problem_type"`
+	AllowImports      string  `gorm:"column:allow_imports;type:varchar(1024);" json:"allow_imports"`
+	DenyImports       string  `gorm:"column:deny_imports;type:varchar(1024);" json:"deny_imports"`
+	FunctionSignature string  `gorm:"column:function_signature;type:varchar(512);" json:"function_signature"`
+	// Categories 是问题分类的 ID，以逗号分隔
+	Categories string `gorm:"column:categories;type:varchar(255);" json:"categories"`
+	// Subtasks 是子任务列表的 JSON
+	Subtasks string `gorm:"column:subtasks;type:text;" json:"-"`
+	// Templates 是代码模板列表的 JSON
+	Templates string `gorm:"column:templates;type:mediumtext;" json:"-"`
+	// TestCases 是按顺序排列的测试用例的 JSON，只包含输入输出文件的校验和与测试用例的属性
+	TestCases string `gorm:"column:test_cases;type:mediumtext;" json:"-"`
+	// TestCaseCount 是测试用例的个数
+	TestCaseCount int `gorm:"column:test_case_count;type:int(11);default:0;" json:"test_case_count"`
+	// TestDataHash 是测试数据的校验和，计算方式与 ProblemBasic 的 TestDataChecksum 相同，测试数据相同的版本校验和相同
+	TestDataHash string `gorm:"column:test_data_hash;type:varchar(64);" json:"test_data_hash"`
+	// CheckerVersion 是使用的特判程序版本，0 表示不使用特判程序
+	CheckerVersion int `gorm:"column:checker_version;type:int(11);default:0;" json:"checker_version"`
+}
+
+// TableName 指定该模型对应的数据库表名
+func (table *ProblemRevision) TableName() string {
+	return "problem_revision"
+}
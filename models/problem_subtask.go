@@ -0,0 +1,31 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProblemSubtask 表示问题的子任务
+// 测试用例通过 TestCase 的 Subtask 字段划分到子任务中，子任务的得分为分值乘以其中测试用例得分比例的最小值
+type ProblemSubtask struct {
+	// ID 是该记录的主键，用于唯一标识每个子任务记录
+	ID uint `gorm:"primarykey;" json:"id"`
+	// CreatedAt 记录该记录的创建时间
+	CreatedAt MyTime `json:"created_at"`
+	// UpdatedAt 记录该记录的最后更新时间
+	UpdatedAt MyTime `json:"updated_at"`
+	// DeletedAt 是软删除标记，使用 gorm 的软删除功能
+	DeletedAt gorm.DeletedAt `gorm:"index;" json:"deleted_at"`
+	// ProblemIdentity 表示该子任务所属问题的唯一标识
+	ProblemIdentity string `gorm:"column:problem_identity;type:varchar(36);index;" json:"problem_identity"`
+	// Number 是子任务在问题内的编号，从 1 开始
+	Number int `gorm:"column:number;type:int(11);" json:"number"`
+	// Score 是子任务的分值
+	Score int `gorm:"column:score;type:int(11);" json:"score"`
+	// Depends 是依赖的子任务编号，以逗号分隔，依赖的子任务全部满分时该子任务才能得分
+	Depends string `gorm:"column:depends;type:varchar(255);" json:"depends"`
+}
+
+// TableName 指定该模型对应的数据库表名
+func (table *ProblemSubtask) TableName() string {
+	return "problem_subtask"
+}
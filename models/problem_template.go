@@ -0,0 +1,31 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProblemTemplate 表示函数式问题在一种语言下的代码模板
+// 用户只需实现问题要求的函数，判题时用户代码被填入驱动模板 Harness 的占位符后再编译
+type ProblemTemplate struct {
+	// ID 是该记录的主键，用于唯一标识每个模板记录
+	ID uint `gorm:"primarykey;" json:"id"`
+	// CreatedAt 记录该记录的创建时间
+	CreatedAt MyTime `json:"created_at"`
+	// UpdatedAt 记录该记录的最后更新时间
+	UpdatedAt MyTime `json:"updated_at"`
+	// DeletedAt 是软删除标记，使用 gorm 的软删除功能
+	DeletedAt gorm.DeletedAt `gorm:"index;" json:"deleted_at"`
+	// ProblemIdentity 表示该模板所属问题的唯一标识
+	ProblemIdentity string `gorm:"column:problem_identity;type:varchar(36);index;" json:"problem_identity"`
+	// Language 是模板的语言标识，同一问题内每种语言只有一个模板
+	Language string `gorm:"column:language;type:varchar(20);" json:"language"`
+	// Starter 是展示给用户的初始代码，通常只包含待实现函数的空实现
+	Starter string `gorm:"column:starter;type:text;" json:"starter"`
+	// Harness 是驱动模板，包含读取输入、调用用户函数和输出结果的代码，不对普通用户展示
+	Harness string `gorm:"column:harness;type:text;" json:"harness,omitempty"`
+}
+
+// TableName 指定该模型对应的数据库表名
+func (table *ProblemTemplate) TableName() string {
+	return "problem_template"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// RejudgeTask 表示一次重判任务
+// 重判会把提交记录重置为待判状态后重新放入判题队列，范围内的提交在创建任务时标记上任务的唯一标识，
+// 判完或跳过后清除标记，Finished 为已清除标记的提交数，用于查询进度
+type RejudgeTask struct {
+	// ID 是该记录的主键，用于唯一标识每个重判任务
+	ID uint `gorm:"primarykey;" json:"id"`
+	// CreatedAt 记录该记录的创建时间
+	CreatedAt MyTime `json:"created_at"`
+	// UpdatedAt 记录该记录的最后更新时间
+	UpdatedAt MyTime `json:"updated_at"`
+	// DeletedAt 是软删除标记，使用 gorm 的软删除功能
+	DeletedAt gorm.DeletedAt `gorm:"index;" json:"deleted_at"`
+	// Identity 是重判任务的唯一标识
+	Identity string `gorm:"column:identity;type:varchar(36);uniqueIndex;" json:"identity"`
+	// Scope 是重判的范围：submit 表示单条提交，problem 表示问题的全部提交，contest 表示竞赛期间的全部提交
+	Scope string `gorm:"column:scope;type:varchar(20);" json:"scope"`
+	// TargetIdentity 是重判对象（提交、问题或竞赛）的唯一标识
+	TargetIdentity string `gorm:"column:target_identity;type:varchar(36);" json:"target_identity"`
+	// UserIdentity 是发起重判的管理员的唯一标识
+	UserIdentity string `gorm:"column:user_identity;type:varchar(36);" json:"user_identity"`
+	// Total 是需要重判的提交数
+	Total int64 `gorm:"column:total;type:int(11);" json:"total"`
+	// Finished 是已经判完或跳过的提交数
+	Finished int64 `gorm:"column:finished;type:int(11);default:0;" json:"finished"`
+	// Skipped 是没有保存代码或状态已变化而跳过的提交数，跳过的提交同样计入 Finished
+	Skipped int64 `gorm:"column:skipped;type:int(11);default:0;" json:"skipped"`
+	// Done 表示重判任务已经完成，并已按提交记录重新统计了相关用户和问题的通过数、提交数
+	Done bool `gorm:"column:done;type:tinyint(1);default:0;" json:"done"`
+}
+
+// TableName 指定该模型对应的数据库表名
+func (table *RejudgeTask) TableName() string {
+	return "rejudge_task"
+}
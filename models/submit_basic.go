@@ -25,10 +25,32 @@ type SubmitBasic struct {
 	UserIdentity string `gorm:"column:user_identity;type:varchar(36);" json:"user_identity"`
 	// UserBasic 是关联的用户基础信息，通过 user_identity 关联到 UserBasic 表
 	UserBasic *UserBasic `gorm:"foreignKey:identity;references:user_identity;" json:"user_basic"`
-	// Path 是提交代码的存放路径
+	// Path 是提交代码的存放路径，判题完成后文件会被删除
 	Path string `gorm:"column:path;type:varchar(255);" json:"path"`
-	// Status 表示提交的状态，-1 表示待判断，1 表示答案正确，2 表示答案错误，3 表示运行超时，4 表示运行超内存，5 表示编译错误，6 表示非法代码
+	// Code 是提交的源代码，重判时据此重新生成代码文件，不随提交列表返回
+	Code string `gorm:"column:code;type:mediumtext;" json:"-"`
+	// Language 是提交代码的语言标识，如 go、cpp、python3
+	Language string `gorm:"column:language;type:varchar(20);default:go;" json:"language"`
+	// Status 表示提交的状态，0 表示待判断，1 表示答案正确，2 表示答案错误，3 表示运行超时，4 表示运行超内存，5 表示编译错误，6 表示非法代码，7 表示判题系统错误，8 表示格式错误，9 表示运行错误，10 表示输出超限
 	Status int `gorm:"column:status;type:tinyint(1);" json:"status"`
+	// TimeUsed 是各测试用例中最大的 CPU 时间，单位毫秒
+	TimeUsed int64 `gorm:"column:time_used;type:int(11);" json:"time_used"`
+	// MemUsed 是各测试用例中最大的峰值内存，单位 KB
+	MemUsed int64 `gorm:"column:mem_used;type:int(11);" json:"mem_used"`
+	// Score 是提交的得分，问题没有子任务时取值 0 到 100，有子任务时为各子任务得分之和，特判程序可以给出部分分
+	Score float64 `gorm:"column:score;type:decimal(6,2);default:0;" json:"score"`
+	// Msg 是判题结果的提示信息，编译错误时为编译器输出，可能包含源代码片段，
+	// 因此只在提交详情中按查看源代码的规则返回，提交列表不查询该字段
+	Msg string `gorm:"column:msg;type:text;" json:"msg"`
+	// SourceHash 是判题时计算的判题结果缓存键，见 utils.SourceHash
+	SourceHash string `gorm:"column:source_hash;type:varchar(64);index;" json:"-"`
+	// ProblemRevision 是判题时问题的版本号，重判时更新为重判时的版本，0 表示判题时问题还没有版本记录
+	ProblemRevision int `gorm:"column:problem_revision;type:int(11);default:0;" json:"problem_revision"`
+	// Cached 表示判题结果复用自相同代码之前的判题结果，没有实际运行
+	Cached bool `gorm:"column:cached;default:false;" json:"cached"`
+	// RejudgeIdentity 是正在重判该提交的重判任务的唯一标识，判完或跳过后清空
+	// 重判任务据此在进程重启后继续重置尚未处理的提交，并统计进度
+	RejudgeIdentity string `gorm:"column:rejudge_identity;type:varchar(36);index;" json:"-"`
 }
 
 // TableName 指定该模型对应的数据库表名
@@ -39,8 +61,9 @@ func (table *SubmitBasic) TableName() string {
 // GetSubmitList 根据问题标识、用户标识和提交状态查询提交列表
 // 返回一个 GORM 的查询构建器，可用于进一步的查询操作
 func GetSubmitList(problemIdentity, userIdentity string, status int) *gorm.DB {
-	// 构建查询语句，预加载关联的问题和用户信息，并排除问题的内容和用户的密码
-	tx := DB.Model(new(SubmitBasic)).
+	// 构建查询语句，排除提交的代码和判题提示信息，预加载关联的问题和用户信息，并排除问题的内容和用户的密码
+	// 提示信息可能包含编译器输出的源代码片段，只在提交详情中按查看代码的规则返回
+	tx := DB.Model(new(SubmitBasic)).Omit("code", "msg").
 		Preload("ProblemBasic", func(db *gorm.DB) *gorm.DB {
 			return db.Omit("content")
 		}).
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// SubmitCaseResult 表示一次提交在单个测试用例上的判题结果
+// 该模型用于记录每个测试用例的判题状态、资源使用和错误信息，便于定位未通过的测试用例
+type SubmitCaseResult struct {
+	// ID 是该记录的主键，用于唯一标识每条测试用例结果
+	ID uint `gorm:"primarykey;" json:"id"`
+	// CreatedAt 记录该记录的创建时间
+	CreatedAt MyTime `json:"created_at"`
+	// UpdatedAt 记录该记录的最后更新时间
+	UpdatedAt MyTime `json:"updated_at"`
+	// DeletedAt 是软删除标记，使用 gorm 的软删除功能
+	DeletedAt gorm.DeletedAt `gorm:"index;" json:"deleted_at"`
+	// SubmitIdentity 表示该结果所属提交记录的唯一标识
+	SubmitIdentity string `gorm:"column:submit_identity;type:varchar(36);index;" json:"submit_identity"`
+	// TestCaseIdentity 表示该结果对应测试用例的唯一标识
+	TestCaseIdentity string `gorm:"column:test_case_identity;type:varchar(255);" json:"test_case_identity"`
+	// Subtask 是该测试用例所属子任务的编号，0 表示不属于任何子任务
+	Subtask int `gorm:"column:subtask;type:int(11);default:0;" json:"subtask"`
+	// Status 是该测试用例的判题状态，取值与 SubmitBasic 的 Status 相同
+	Status int `gorm:"column:status;type:tinyint(1);" json:"status"`
+	// TimeUsed 是该测试用例的 CPU 时间，单位毫秒
+	TimeUsed int64 `gorm:"column:time_used;type:int(11);" json:"time_used"`
+	// MemUsed 是该测试用例的峰值内存，单位 KB
+	MemUsed int64 `gorm:"column:mem_used;type:int(11);" json:"mem_used"`
+	// ExitCode 是程序的退出码，被信号杀死时为 -1
+	ExitCode int `gorm:"column:exit_code;type:int(11);" json:"exit_code"`
+	// Score 是该测试用例的得分比例，取值 0 到 1
+	Score float64 `gorm:"column:score;type:decimal(6,4);default:0;" json:"score"`
+	// Msg 是截断后的标准错误输出、输出差异或特判程序的信息
+	Msg string `gorm:"column:msg;type:text;" json:"msg"`
+}
+
+// TableName 指定该模型对应的数据库表名
+func (table *SubmitCaseResult) TableName() string {
+	return "submit_case_result"
+}
@@ -19,10 +19,34 @@ type TestCase struct {
 	Identity string `gorm:"column:identity;type:varchar(255);" json:"identity"`
 	// ProblemIdentity 表示该测试用例所属问题的唯一标识
 	ProblemIdentity string `gorm:"column:problem_identity;type:varchar(255);" json:"problem_identity"`
-	// Input 是测试用例的输入内容
+	// Input 是测试用例的输入内容，只用于随问题一起提交的小测试用例，通过压缩包上传的测试数据保存在 storage 中
 	Input string `gorm:"column:input;type:text;" json:"input"`
-	// Output 是测试用例的预期输出内容
+	// Output 是测试用例的预期输出内容，与 Input 相同
 	Output string `gorm:"column:output;type:text;" json:"output"`
+	// InputHash 是保存在 storage 中的输入文件的校验和，为空表示输入保存在 Input 中
+	InputHash string `gorm:"column:input_hash;type:varchar(64);" json:"input_hash"`
+	// InputSize 是输入文件的字节数
+	InputSize int64 `gorm:"column:input_size;type:bigint(20);default:0;" json:"input_size"`
+	// OutputHash 是保存在 storage 中的预期输出文件的校验和，为空表示预期输出保存在 Output 中
+	OutputHash string `gorm:"column:output_hash;type:varchar(64);" json:"output_hash"`
+	// OutputSize 是预期输出文件的字节数
+	OutputSize int64 `gorm:"column:output_size;type:bigint(20);default:0;" json:"output_size"`
+	// Subtask 是该测试用例所属子任务的编号，0 表示不属于任何子任务
+	Subtask int `gorm:"column:subtask;type:int(11);default:0;" json:"subtask"`
+	// IsSample 表示该测试用例是样例，样例会展示给用户，其余测试用例对用户隐藏
+	IsSample bool `gorm:"column:is_sample;default:false;" json:"is_sample"`
+	// Explanation 是样例的说明，随样例一起展示给用户
+	Explanation string `gorm:"column:explanation;type:text;" json:"explanation"`
+	// Sort 是测试用例的顺序，判题和展示都按 Sort 从小到大排列，相同时按创建顺序
+	Sort int `gorm:"column:sort;type:int(11);default:0;" json:"sort"`
+}
+
+// TestCaseOrder 是测试用例的排序方式
+const TestCaseOrder = "sort ASC, id ASC"
+
+// Stored 判断测试数据是否保存在 storage 中
+func (table *TestCase) Stored() bool {
+	return table.InputHash != "" && table.OutputHash != ""
 }
 
 // TableName 指定该模型对应的数据库表名
@@ -0,0 +1,165 @@
+package problempkg
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// fpsFile 是 FPS（FreeProblemSet）XML 文件的根元素，一个文件可以包含多个问题
+type fpsFile struct {
+	Items []*fpsItem `xml:"item"`
+}
+
+// fpsItem 是 FPS 中的一个问题，题面各部分为 HTML
+type fpsItem struct {
+	Title        string     `xml:"title"`
+	TimeLimit    fpsLimit   `xml:"time_limit"`
+	MemoryLimit  fpsLimit   `xml:"memory_limit"`
+	Description  string     `xml:"description"`
+	Input        string     `xml:"input"`
+	Output       string     `xml:"output"`
+	SampleInput  []string   `xml:"sample_input"`
+	SampleOutput []string   `xml:"sample_output"`
+	TestInput    []string   `xml:"test_input"`
+	TestOutput   []string   `xml:"test_output"`
+	Hint         string     `xml:"hint"`
+	SPJ          []*fpsCode `xml:"spj"`
+}
+
+// fpsLimit 是带单位的时间或内存限制
+type fpsLimit struct {
+	Unit  string `xml:"unit,attr"`
+	Value string `xml:",chardata"`
+}
+
+// fpsCode 是带语言的源代码
+type fpsCode struct {
+	Language string `xml:"language,attr"`
+	Code     string `xml:",chardata"`
+}
+
+// ReadFPS 读取 FPS XML 文件，每个 item 转换为一个问题包，样例排在测试数据之前并标记为样例
+// FPS 的特判程序遵循 HUSTOJ 的约定（参数顺序和退出码含义不同），无法直接使用，转换时忽略并给出警告
+func ReadFPS(r io.Reader) ([]*Package, error) {
+	var f fpsFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, errors.New("FPS 文件格式错误：" + err.Error())
+	}
+	if len(f.Items) == 0 {
+		return nil, errors.New("FPS 文件中没有问题")
+	}
+	pkgs := make([]*Package, 0, len(f.Items))
+	for i, item := range f.Items {
+		p, err := fpsPackage(item)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个问题：%w", i+1, err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// fpsPackage 把 FPS 中的一个问题转换为问题包
+func fpsPackage(item *fpsItem) (*Package, error) {
+	title := strings.TrimSpace(item.Title)
+	if title == "" {
+		return nil, errors.New("缺少标题")
+	}
+	if len(item.SampleInput) != len(item.SampleOutput) {
+		return nil, errors.New("样例输入和样例输出的数量不一致")
+	}
+	if len(item.TestInput) != len(item.TestOutput) {
+		return nil, errors.New("测试输入和测试输出的数量不一致")
+	}
+	runtime, err := fpsTimeLimit(item.TimeLimit)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := fpsMemoryLimit(item.MemoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	var content strings.Builder
+	content.WriteString(strings.TrimSpace(item.Description))
+	for _, section := range []struct{ name, html string }{
+		{"输入", item.Input}, {"输出", item.Output}, {"提示", item.Hint},
+	} {
+		if html := strings.TrimSpace(section.html); html != "" {
+			content.WriteString("\n<h3>" + section.name + "</h3>\n" + html)
+		}
+	}
+
+	p := &Package{Source: FormatFPS, Manifest: &Manifest{
+		Title:      title,
+		Content:    content.String(),
+		MaxRuntime: runtime,
+		MaxMem:     mem,
+	}}
+	add := func(input, output string, sample bool) {
+		n := len(p.Manifest.TestCases) + 1
+		tc := &TestCase{
+			Input:    fmt.Sprintf("data/%d.in", n),
+			Output:   fmt.Sprintf("data/%d.out", n),
+			IsSample: sample,
+		}
+		p.addFile(tc.Input, []byte(input))
+		p.addFile(tc.Output, []byte(output))
+		p.Manifest.TestCases = append(p.Manifest.TestCases, tc)
+	}
+	for i := range item.SampleInput {
+		add(item.SampleInput[i], item.SampleOutput[i], true)
+	}
+	for i := range item.TestInput {
+		add(item.TestInput[i], item.TestOutput[i], false)
+	}
+	if len(p.Manifest.TestCases) == 0 {
+		return nil, errors.New("没有测试数据")
+	}
+	if len(item.TestInput) == 0 {
+		p.Warnings = append(p.Warnings, "FPS 文件中只有样例，样例同时作为测试数据")
+	}
+	for _, spj := range item.SPJ {
+		if strings.TrimSpace(spj.Code) != "" {
+			p.Warnings = append(p.Warnings, "FPS 的特判程序遵循 HUSTOJ 的约定，未导入，请按本系统的约定改写后重新上传")
+			break
+		}
+	}
+	return p, nil
+}
+
+// fpsTimeLimit 把时间限制转换为毫秒，单位为 s（默认）或 ms
+func fpsTimeLimit(l fpsLimit) (int, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(l.Value), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("时间限制 %q 无效", l.Value)
+	}
+	switch strings.ToLower(l.Unit) {
+	case "", "s":
+		v *= 1000
+	case "ms":
+	default:
+		return 0, fmt.Errorf("不支持的时间单位 %q", l.Unit)
+	}
+	return int(math.Ceil(v)), nil
+}
+
+// fpsMemoryLimit 把内存限制转换为 KB，单位为 mb（默认）或 kb
+func fpsMemoryLimit(l fpsLimit) (int, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(l.Value), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("内存限制 %q 无效", l.Value)
+	}
+	switch strings.ToLower(l.Unit) {
+	case "", "mb":
+		v *= 1024
+	case "kb":
+	default:
+		return 0, fmt.Errorf("不支持的内存单位 %q", l.Unit)
+	}
+	return int(math.Ceil(v)), nil
+}
@@ -0,0 +1,107 @@
+package problempkg
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Writer 写入本系统格式的问题包，先逐个写入测试数据等文件，最后写入 manifest
+type Writer struct {
+	zw    *zip.Writer
+	names map[string]bool
+}
+
+// NewWriter 返回把问题包写入 w 的 Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w), names: make(map[string]bool)}
+}
+
+// Create 在问题包中创建文件，返回写入文件内容的 io.Writer，写入下一个文件前有效
+func (w *Writer) Create(name string) (io.Writer, error) {
+	if !validName(name) || name == ManifestName {
+		return nil, fmt.Errorf("问题包中的文件名 %q 无效", name)
+	}
+	if w.names[name] {
+		return nil, fmt.Errorf("问题包中的文件 %s 重复", name)
+	}
+	w.names[name] = true
+	return w.zw.Create(name)
+}
+
+// Close 检查 manifest 引用的文件都已写入，写入 manifest 并结束压缩包
+func (w *Writer) Close(m *Manifest) error {
+	m.Format, m.Version = ManifestFormat, ManifestVersion
+	for _, name := range manifestFiles(m) {
+		if !w.names[name] {
+			return fmt.Errorf("manifest 引用的文件 %s 没有写入问题包", name)
+		}
+	}
+	f, err := w.zw.Create(ManifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(m); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// ReadNative 读取本系统格式的问题包
+func ReadNative(zr *zip.Reader) (*Package, error) {
+	files := make(map[string]*zip.File)
+	for _, f := range zipFiles(zr) {
+		files[f.Name] = f
+	}
+	mf, ok := files[ManifestName]
+	if !ok {
+		return nil, errors.New("问题包中没有 " + ManifestName)
+	}
+	rc, err := mf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	m := new(Manifest)
+	if err = json.NewDecoder(rc).Decode(m); err != nil {
+		return nil, errors.New(ManifestName + " 格式错误：" + err.Error())
+	}
+	if m.Format != ManifestFormat {
+		return nil, fmt.Errorf("不支持的问题包格式 %q", m.Format)
+	}
+	if m.Version > ManifestVersion {
+		return nil, fmt.Errorf("问题包的版本 %d 高于支持的版本 %d，请升级后再导入", m.Version, ManifestVersion)
+	}
+	p := &Package{Source: FormatNative, Manifest: m}
+	for _, name := range manifestFiles(m) {
+		f, ok := files[name]
+		if !validName(name) || !ok {
+			return nil, fmt.Errorf("manifest 引用的文件 %s 不存在", name)
+		}
+		p.addZipFile(name, f)
+	}
+	return p, nil
+}
+
+// manifestFiles 返回 manifest 引用的所有文件
+func manifestFiles(m *Manifest) []string {
+	var names []string
+	for _, tc := range m.TestCases {
+		names = append(names, tc.Input, tc.Output)
+	}
+	if m.Checker != nil {
+		names = append(names, m.Checker.File)
+	}
+	return names
+}
+
+// validName 判断文件名是否为问题包内的相对路径
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "/") && path.Clean(name) == name && !strings.HasPrefix(name, "../") && name != ".."
+}
@@ -0,0 +1,233 @@
+// Package problempkg 实现问题包的读写，用于在不同实例之间导入导出问题
+// 本系统导出的问题包是一个 zip 压缩包，根目录的 manifest.json 描述问题，测试数据和特判程序按 manifest 中的路径保存为文件；
+// 导入时还支持 FPS（FreeProblemSet XML）和 Polygon 导出的问题包，统一转换为 Manifest
+package problempkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"gin_gorm_oj/define"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ManifestName 是问题包中 manifest 的文件名
+const ManifestName = "manifest.json"
+
+// ManifestFormat 和 ManifestVersion 标识问题包的格式，格式不兼容时递增版本
+const (
+	ManifestFormat  = "gin_gorm_oj/problem"
+	ManifestVersion = 1
+)
+
+// 问题包的来源格式
+const (
+	FormatAuto    = "auto"    // 根据内容自动识别
+	FormatNative  = "native"  // 本系统导出的问题包
+	FormatFPS     = "fps"     // FreeProblemSet XML，可以是单独的 XML 文件，也可以打包在 zip 中
+	FormatPolygon = "polygon" // Polygon 导出的问题包（包含测试数据的完整包）
+)
+
+// Manifest 描述一个问题，字段含义与 define.ProblemBasic 相同
+type Manifest struct {
+	// Format 和 Version 标识问题包的格式
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// Identity 是问题的唯一标识，导入时存在相同标识的问题则更新该问题，为空时新建问题
+	Identity string `json:"identity,omitempty"`
+	// Title 是问题标题
+	Title string `json:"title"`
+	// Content 是问题内容
+	Content string `json:"content"`
+	// MaxRuntime 是最大运行时长（毫秒）
+	MaxRuntime int `json:"max_runtime"`
+	// MaxMem 是最大运行内存（KB）
+	MaxMem int `json:"max_mem"`
+	// Languages 是允许提交的语言标识列表，为空表示允许所有语言
+	Languages []string `json:"languages,omitempty"`
+	// CompareMode 是输出比较方式
+	CompareMode string `json:"compare_mode,omitempty"`
+	// FloatEpsilon 是 float 比较方式下允许的误差
+	FloatEpsilon float64 `json:"float_epsilon,omitempty"`
+	// JudgeMode 是判题模式
+	JudgeMode string `json:"judge_mode,omitempty"`
+	// ProblemType 是问题类型
+	ProblemType string `json:"problem_type,omitempty"`
+	// AllowImports 和 DenyImports 是 Go 代码检查的规则
+	AllowImports []string `json:"allow_imports,omitempty"`
+	DenyImports  []string `json:"deny_imports,omitempty"`
+	// FunctionSignature 是函数式问题要求实现的函数签名
+	FunctionSignature string `json:"function_signature,omitempty"`
+	// Categories 是分类名称列表，导入时按名称匹配分类
+	Categories []string `json:"categories,omitempty"`
+	// Subtasks 是子任务列表
+	Subtasks []*define.Subtask `json:"subtasks,omitempty"`
+	// Templates 是函数式问题各语言的代码模板
+	Templates []*define.ProblemTemplate `json:"templates,omitempty"`
+	// Checker 是特判程序或交互程序，为空表示按比较方式判定
+	Checker *Checker `json:"checker,omitempty"`
+	// TestCases 是按判题顺序排列的测试用例
+	TestCases []*TestCase `json:"test_cases"`
+}
+
+// Checker 描述问题包中的特判程序
+type Checker struct {
+	// Language 是特判程序的语言标识
+	Language string `json:"language"`
+	// File 是源代码在问题包中的路径
+	File string `json:"file"`
+}
+
+// TestCase 描述问题包中的一个测试用例
+type TestCase struct {
+	// Input 和 Output 是输入和预期输出在问题包中的路径
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	// Subtask 是所属子任务的编号
+	Subtask int `json:"subtask,omitempty"`
+	// IsSample 表示是否为样例
+	IsSample bool `json:"is_sample,omitempty"`
+	// Explanation 是样例说明
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// Package 是读取后的问题包
+type Package struct {
+	// Source 是问题包的来源格式
+	Source string
+	// Manifest 描述问题，来自其他格式的问题包已转换为本系统的格式
+	Manifest *Manifest
+	// Warnings 是转换时无法完整保留的内容，导入前应当告知管理员
+	Warnings []string
+
+	files map[string]func() (io.ReadCloser, error)
+}
+
+// Open 打开问题包中 manifest 引用的文件
+func (p *Package) Open(name string) (io.ReadCloser, error) {
+	open, ok := p.files[name]
+	if !ok {
+		return nil, fmt.Errorf("问题包中没有文件 %s", name)
+	}
+	return open()
+}
+
+// ReadFile 读取问题包中 manifest 引用的文件的全部内容
+func (p *Package) ReadFile(name string) ([]byte, error) {
+	rc, err := p.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// addFile 把内存中的数据作为问题包中的文件
+func (p *Package) addFile(name string, data []byte) {
+	if p.files == nil {
+		p.files = make(map[string]func() (io.ReadCloser, error))
+	}
+	p.files[name] = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// addZipFile 把 zip 中的文件作为问题包中的文件
+func (p *Package) addZipFile(name string, f *zip.File) {
+	if p.files == nil {
+		p.files = make(map[string]func() (io.ReadCloser, error))
+	}
+	p.files[name] = f.Open
+}
+
+// Read 读取问题包，format 为 FormatAuto 时根据内容识别格式；FPS 文件可以包含多个问题，其他格式只包含一个问题
+func Read(r io.ReaderAt, size int64, format string) ([]*Package, error) {
+	head := make([]byte, 4)
+	n, _ := r.ReadAt(head, 0)
+	if n < 4 || string(head) != "PK\x03\x04" {
+		// 不是 zip 压缩包，只能是 FPS 的 XML 文件。
+		if format != FormatAuto && format != FormatFPS {
+			return nil, errors.New("问题包不是 zip 压缩包")
+		}
+		return ReadFPS(io.NewSectionReader(r, 0, size))
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("无法读取压缩包：" + err.Error())
+	}
+	if format == FormatAuto {
+		format = detect(zr)
+	}
+	switch format {
+	case FormatNative:
+		p, err := ReadNative(zr)
+		if err != nil {
+			return nil, err
+		}
+		return []*Package{p}, nil
+	case FormatPolygon:
+		p, err := ReadPolygon(zr)
+		if err != nil {
+			return nil, err
+		}
+		return []*Package{p}, nil
+	case FormatFPS:
+		var pkgs []*Package
+		for _, f := range zipFiles(zr) {
+			if !strings.EqualFold(path.Ext(f.Name), ".xml") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			ps, err := ReadFPS(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s：%w", f.Name, err)
+			}
+			pkgs = append(pkgs, ps...)
+		}
+		if len(pkgs) == 0 {
+			return nil, errors.New("压缩包中没有 FPS 文件")
+		}
+		return pkgs, nil
+	}
+	return nil, errors.New("无法识别问题包的格式")
+}
+
+// detect 根据压缩包中的文件识别格式
+func detect(zr *zip.Reader) string {
+	hasXML := false
+	for _, f := range zipFiles(zr) {
+		switch {
+		case f.Name == ManifestName:
+			return FormatNative
+		case path.Base(f.Name) == "problem.xml":
+			return FormatPolygon
+		case strings.EqualFold(path.Ext(f.Name), ".xml"):
+			hasXML = true
+		}
+	}
+	if hasXML {
+		return FormatFPS
+	}
+	return ""
+}
+
+// zipFiles 返回压缩包中按名称排序的文件，忽略目录和 macOS 附加的文件
+func zipFiles(zr *zip.Reader) []*zip.File {
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(path.Base(f.Name), ".") {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
@@ -0,0 +1,305 @@
+package problempkg
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"io"
+	"path"
+	"strings"
+)
+
+// polygonProblem 是 Polygon 问题包中 problem.xml 的根元素
+type polygonProblem struct {
+	Names      []polygonName      `xml:"names>name"`
+	Statements []polygonStatement `xml:"statements>statement"`
+	Testsets   []polygonTestset   `xml:"judging>testset"`
+	Checker    *polygonAsset      `xml:"assets>checker"`
+	Interactor *polygonAsset      `xml:"assets>interactor"`
+}
+
+type polygonName struct {
+	Language string `xml:"language,attr"`
+	Value    string `xml:"value,attr"`
+}
+
+type polygonStatement struct {
+	Language string `xml:"language,attr"`
+	Path     string `xml:"path,attr"`
+	Type     string `xml:"type,attr"`
+}
+
+type polygonTestset struct {
+	Name          string        `xml:"name,attr"`
+	TimeLimit     int           `xml:"time-limit"`
+	MemoryLimit   int64         `xml:"memory-limit"`
+	TestCount     int           `xml:"test-count"`
+	InputPattern  string        `xml:"input-path-pattern"`
+	AnswerPattern string        `xml:"answer-path-pattern"`
+	Tests         []polygonTest `xml:"tests>test"`
+}
+
+type polygonTest struct {
+	Sample bool   `xml:"sample,attr"`
+	Group  string `xml:"group,attr"`
+}
+
+// polygonAsset 是特判程序或交互程序，Name 为 std:: 开头时是 testlib 自带的标准特判程序
+type polygonAsset struct {
+	Name   string `xml:"name,attr"`
+	Source struct {
+		Path string `xml:"path,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"source"`
+}
+
+// polygonStdCheckers 是 testlib 标准特判程序对应的输出比较方式和误差
+var polygonStdCheckers = map[string]struct {
+	mode    string
+	epsilon float64
+}{
+	"std::fcmp.cpp":   {judge.CompareTrailing, 0},
+	"std::hcmp.cpp":   {judge.CompareToken, 0},
+	"std::lcmp.cpp":   {judge.CompareToken, 0},
+	"std::ncmp.cpp":   {judge.CompareToken, 0},
+	"std::uncmp.cpp":  {judge.CompareToken, 0},
+	"std::wcmp.cpp":   {judge.CompareToken, 0},
+	"std::yesno.cpp":  {judge.CompareTokenCI, 0},
+	"std::nyesno.cpp": {judge.CompareTokenCI, 0},
+	"std::dcmp.cpp":   {judge.CompareFloat, 1e-6},
+	"std::rcmp.cpp":   {judge.CompareFloat, 1.5e-6},
+	"std::rcmp4.cpp":  {judge.CompareFloat, 1e-4},
+	"std::rcmp6.cpp":  {judge.CompareFloat, 1e-6},
+	"std::rcmp9.cpp":  {judge.CompareFloat, 1e-9},
+}
+
+// polygonLanguages 把 Polygon 的源代码类型前缀映射为语言标识
+var polygonLanguages = []struct{ prefix, lang string }{
+	{"cpp.", "cpp"},
+	{"c.", "c"},
+	{"java", "java"},
+	{"python.3", "python3"},
+	{"python3", "python3"},
+	{"go", "go"},
+}
+
+// polygonStatementLanguages 是选择题面语言的优先顺序，都没有时使用 problem.xml 中的第一个语言
+var polygonStatementLanguages = []string{"chinese", "english"}
+
+// ReadPolygon 读取 Polygon 导出的完整问题包（包含生成好的测试数据），problem.xml 可以位于压缩包的子目录中
+// 使用名为 tests 的测试集（没有时使用第一个测试集）；标准特判程序转换为对应的输出比较方式，自定义特判程序和交互程序按源代码导入
+func ReadPolygon(zr *zip.Reader) (*Package, error) {
+	files := make(map[string]*zip.File)
+	root := ""
+	found := false
+	for _, f := range zipFiles(zr) {
+		files[f.Name] = f
+		if path.Base(f.Name) == "problem.xml" && (!found || len(f.Name) < len(root)) {
+			root, found = f.Name, true
+		}
+	}
+	if !found {
+		return nil, errors.New("问题包中没有 problem.xml")
+	}
+	var pp polygonProblem
+	if err := decodeZipXML(files[root], &pp); err != nil {
+		return nil, errors.New("problem.xml 格式错误：" + err.Error())
+	}
+	root = path.Dir(root)
+	file := func(name string) *zip.File { return files[path.Join(root, name)] }
+
+	if len(pp.Testsets) == 0 {
+		return nil, errors.New("problem.xml 中没有测试集")
+	}
+	ts := pp.Testsets[0]
+	for _, t := range pp.Testsets {
+		if t.Name == "tests" {
+			ts = t
+			break
+		}
+	}
+	if ts.TimeLimit <= 0 || ts.MemoryLimit <= 0 {
+		return nil, errors.New("测试集缺少时间或内存限制")
+	}
+	lang := polygonLanguage(pp)
+	p := &Package{Source: FormatPolygon, Manifest: &Manifest{
+		Title:      polygonTitle(pp, lang),
+		MaxRuntime: ts.TimeLimit,
+		MaxMem:     int((ts.MemoryLimit + 1023) / 1024),
+	}}
+	content, err := polygonContent(pp, lang, file)
+	if err != nil {
+		return nil, err
+	}
+	p.Manifest.Content = content
+	if p.Manifest.Title == "" {
+		return nil, errors.New("problem.xml 中没有问题名称")
+	}
+
+	count := ts.TestCount
+	if count == 0 {
+		count = len(ts.Tests)
+	}
+	if count == 0 {
+		return nil, errors.New("测试集中没有测试")
+	}
+	grouped := false
+	for i := 1; i <= count; i++ {
+		tc := &TestCase{Input: fmt.Sprintf(ts.InputPattern, i), Output: fmt.Sprintf(ts.AnswerPattern, i)}
+		for _, name := range []string{tc.Input, tc.Output} {
+			f := file(name)
+			if f == nil {
+				return nil, fmt.Errorf("测试 %d 缺少文件 %s，请导出包含测试数据的完整包", i, name)
+			}
+			p.addZipFile(name, f)
+		}
+		if i <= len(ts.Tests) {
+			tc.IsSample = ts.Tests[i-1].Sample
+			grouped = grouped || ts.Tests[i-1].Group != ""
+		}
+		p.Manifest.TestCases = append(p.Manifest.TestCases, tc)
+	}
+	if grouped {
+		p.Warnings = append(p.Warnings, "Polygon 的测试组没有转换为子任务，请导入后按需设置子任务")
+	}
+
+	if pp.Interactor != nil && pp.Interactor.Source.Path != "" {
+		p.Manifest.ProblemType = define.ProblemTypeInteractive
+		if err = polygonSource(p, pp.Interactor, file); err != nil {
+			return nil, err
+		}
+		p.Warnings = append(p.Warnings, "交互程序按源代码导入，Polygon 的特判程序不再使用，交互程序需要自行判定输出")
+		return p, nil
+	}
+	if pp.Checker == nil {
+		return p, nil
+	}
+	if std, ok := polygonStdCheckers[pp.Checker.Name]; ok {
+		p.Manifest.CompareMode, p.Manifest.FloatEpsilon = std.mode, std.epsilon
+		return p, nil
+	}
+	if strings.HasPrefix(pp.Checker.Name, "std::") {
+		p.Manifest.CompareMode = judge.CompareToken
+		p.Warnings = append(p.Warnings, fmt.Sprintf("标准特判程序 %s 没有对应的比较方式，已按单词比较", pp.Checker.Name))
+		return p, nil
+	}
+	if err = polygonSource(p, pp.Checker, file); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// polygonSource 把自定义特判程序或交互程序的源代码加入问题包
+func polygonSource(p *Package, asset *polygonAsset, file func(string) *zip.File) error {
+	name := asset.Source.Path
+	f := file(name)
+	if f == nil {
+		return fmt.Errorf("问题包中缺少源代码 %s", name)
+	}
+	lang := ""
+	for _, l := range polygonLanguages {
+		if strings.HasPrefix(asset.Source.Type, l.prefix) {
+			lang = l.lang
+			break
+		}
+	}
+	if lang == "" {
+		return fmt.Errorf("不支持源代码 %s 的类型 %q", name, asset.Source.Type)
+	}
+	p.addZipFile(name, f)
+	p.Manifest.Checker = &Checker{Language: lang, File: name}
+	if file(path.Join(path.Dir(name), "testlib.h")) != nil {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s 使用 testlib.h，判题机上需要能找到该头文件", name))
+	}
+	return nil
+}
+
+// polygonLanguage 选择题面和名称使用的语言
+func polygonLanguage(pp polygonProblem) string {
+	for _, lang := range polygonStatementLanguages {
+		for _, n := range pp.Names {
+			if n.Language == lang {
+				return lang
+			}
+		}
+	}
+	if len(pp.Names) > 0 {
+		return pp.Names[0].Language
+	}
+	return ""
+}
+
+// polygonTitle 返回指定语言的问题名称
+func polygonTitle(pp polygonProblem, lang string) string {
+	for _, n := range pp.Names {
+		if n.Language == lang {
+			return strings.TrimSpace(n.Value)
+		}
+	}
+	return ""
+}
+
+// polygonContent 生成题面：优先拼接 statement-sections 中的题面各部分（LaTeX），
+// 没有时使用 problem.xml 中列出的 HTML 或 LaTeX 题面文件
+func polygonContent(pp polygonProblem, lang string, file func(string) *zip.File) (string, error) {
+	dir := path.Join("statement-sections", lang)
+	var content strings.Builder
+	for _, section := range []struct{ file, name string }{
+		{"legend.tex", ""}, {"input.tex", "输入"}, {"output.tex", "输出"}, {"interaction.tex", "交互"}, {"notes.tex", "提示"},
+	} {
+		f := file(path.Join(dir, section.file))
+		if f == nil {
+			continue
+		}
+		b, err := readZipFile(f)
+		if err != nil {
+			return "", err
+		}
+		text := strings.TrimSpace(string(b))
+		if text == "" {
+			continue
+		}
+		if section.name != "" {
+			content.WriteString("\n\n### " + section.name + "\n\n")
+		}
+		content.WriteString(text)
+	}
+	if content.Len() > 0 {
+		return strings.TrimSpace(content.String()), nil
+	}
+	for _, types := range []string{"text/html", "application/x-tex"} {
+		for _, st := range pp.Statements {
+			if st.Language != lang || st.Type != types {
+				continue
+			}
+			if f := file(st.Path); f != nil {
+				b, err := readZipFile(f)
+				return strings.TrimSpace(string(b)), err
+			}
+		}
+	}
+	return "", errors.New("问题包中没有题面")
+}
+
+// decodeZipXML 解析 zip 中的 XML 文件
+func decodeZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// readZipFile 读取 zip 中文件的全部内容
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
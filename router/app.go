@@ -3,11 +3,13 @@ package router
 import (
 	_ "gin_gorm_oj/docs"
 	"gin_gorm_oj/middlewares"
+	"gin_gorm_oj/models"
 	"gin_gorm_oj/service"
 	"gin_gorm_oj/utils"
 	"github.com/gin-gonic/gin"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"time"
 )
 
 func Router() {
@@ -54,6 +56,9 @@ func Router() {
 	authAdmin.POST("/problem-create", service.ProblemCreate)
 	//// 问题修改
 	authAdmin.PUT("/problem-modify", service.ProblemModify)
+	//// 问题导入导出
+	authAdmin.GET("/problem-export", service.ProblemExport)
+	authAdmin.POST("/problem-import", service.ProblemImport)
 	// 分类创建
 	authAdmin.POST("/category-create", service.CategoryCreate)
 	//// 分类修改
@@ -62,6 +67,26 @@ func Router() {
 	authAdmin.DELETE("/category-delete", service.CategoryDelete)
 	//// 获取测试案例
 	authAdmin.GET("/test-case", service.GetTestCase)
+	authAdmin.POST("/test-case-upload", service.TestCaseUpload)
+	authAdmin.POST("/test-case-create", service.TestCaseCreate)
+	authAdmin.PUT("/test-case-modify", service.TestCaseModify)
+	authAdmin.PUT("/test-case-sort", service.TestCaseSort)
+	authAdmin.DELETE("/test-case-delete", service.TestCaseDelete)
+	//// 特判程序
+	authAdmin.POST("/problem-checker-create", service.ProblemCheckerCreate)
+	authAdmin.GET("/problem-checker-list", service.GetProblemCheckerList)
+	authAdmin.PUT("/problem-checker-use", service.ProblemCheckerUse)
+	//// 问题版本
+	authAdmin.GET("/problem-revision-list", service.GetProblemRevisionList)
+	authAdmin.GET("/problem-revision-diff", service.GetProblemRevisionDiff)
+	authAdmin.PUT("/problem-revision-restore", service.ProblemRevisionRestore)
+	//// 重判
+	authAdmin.POST("/rejudge-submit", service.RejudgeSubmit)
+	authAdmin.POST("/rejudge-problem", service.RejudgeProblem)
+	authAdmin.POST("/rejudge-contest", service.RejudgeContest)
+	authAdmin.GET("/rejudge-detail", service.GetRejudgeDetail)
+	//// 判题节点状态
+	authAdmin.GET("/judge-node-list", service.GetJudgeNodeList)
 	//
 	//// 竞赛创建
 	authAdmin.POST("/contest-create", service.ContestCreate)
@@ -72,7 +97,30 @@ func Router() {
 	authUser := r.Group("/user", middlewares.AuthUserCheck())
 	//// 代码提交
 	authUser.POST("/submit", service.Submit)
+	//// 自定义输入运行，按用户限流
+	runLimiter := middlewares.NewRateLimiter(models.RDB, "run", utils.RunRateLimit, time.Duration(utils.RunRateWindow)*time.Second)
+	authUser.POST("/run", middlewares.RateLimit(runLimiter), service.CodeRun)
+	//// 提交的测试用例结果
+	authUser.GET("/submit-case-result", service.GetSubmitCaseResult)
+	//// 提交详情，包含源代码
+	authUser.GET("/submit-detail", service.GetSubmitDetail)
 	authUser.POST("/contest-registration", service.ContestRegistration)
+	//// 实时事件流（判题进度和竞赛榜单），EventSource 不能设置请求头，允许通过查询参数传递 token
+	r.GET("/user/events", middlewares.QueryToken(), middlewares.AuthUserCheck(), service.SubmitEvents)
+
+	//// 独立判题节点（judged）协议，使用共享密钥认证
+	judgeNode := r.Group("/judge-node", middlewares.JudgeNodeAuth(utils.JudgeNodeToken))
+	judgeNode.POST("/register", service.JudgeNodeRegister)
+	judgeNode.POST("/heartbeat", service.JudgeNodeHeartbeat)
+	judgeNode.POST("/pull", service.JudgeNodePull)
+	judgeNode.GET("/test-data", service.JudgeNodeTestData)
+	judgeNode.POST("/progress", service.JudgeNodeProgress)
+	judgeNode.POST("/result", service.JudgeNodeResult)
+
+	// 启动实时事件中心和后台判题协程
+	service.StartEvents()
+	service.StartJudge(utils.JudgeWorkers)
+
 	err := r.Run(utils.HttpPort)
 	if err != nil {
 		return
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/middlewares"
+	"gin_gorm_oj/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 实时事件的类型，作为 SSE 的 event 字段
+const (
+	EventSubmit     = "submit"     // 提交记录的判题进度和最终结果，推送给提交者
+	EventScoreboard = "scoreboard" // 竞赛榜单变化，推送给订阅该竞赛的用户
+)
+
+// 提交记录的判题阶段，编译和运行阶段见 judge.Progress* 常量
+const submitStageFinished = "finished" // 判题结束
+
+// eventChannel 是 Redis 中发布实时事件的频道名
+const eventChannel = "oj:events"
+
+// eventHeartbeat 是 SSE 连接的心跳间隔，避免代理因长时间无数据断开连接
+const eventHeartbeat = 15 * time.Second
+
+// Event 表示推送给前端的实时事件
+type Event struct {
+	// Type 是事件类型，取值见 Event* 常量
+	Type string `json:"type"`
+	// UserIdentity 是接收事件的用户唯一标识，为空表示不按用户推送
+	UserIdentity string `json:"user_identity,omitempty"`
+	// ContestIdentity 是事件所属竞赛的唯一标识，为空表示不按竞赛推送
+	ContestIdentity string `json:"contest_identity,omitempty"`
+	// Data 是事件内容，作为 SSE 的 data 字段
+	Data interface{} `json:"data"`
+}
+
+// SubmitEvent 是 EventSubmit 事件的内容
+type SubmitEvent struct {
+	// SubmitIdentity 是提交记录的唯一标识
+	SubmitIdentity string `json:"submit_identity"`
+	// ProblemIdentity 是问题的唯一标识
+	ProblemIdentity string `json:"problem_identity"`
+	// Stage 是判题阶段：compiling、running、finished
+	Stage string `json:"stage"`
+	// Done 是已完成的测试用例个数，Total 是测试用例总数
+	Done  int `json:"done"`
+	Total int `json:"total"`
+	// 以下字段只在 finished 阶段有值
+	Status   int     `json:"status"`
+	Score    float64 `json:"score"`
+	TimeUsed int64   `json:"time_used"`
+	MemUsed  int64   `json:"mem_used"`
+	Msg      string  `json:"msg"`
+}
+
+// ScoreboardEvent 是 EventScoreboard 事件的内容，前端收到后重新获取榜单
+type ScoreboardEvent struct {
+	// ContestIdentity 是竞赛的唯一标识
+	ContestIdentity string `json:"contest_identity"`
+	// UserIdentity 是提交者的唯一标识
+	UserIdentity string `json:"user_identity"`
+	// ProblemIdentity 是问题的唯一标识
+	ProblemIdentity string `json:"problem_identity"`
+	// SubmitIdentity 是引起榜单变化的提交记录的唯一标识
+	SubmitIdentity string `json:"submit_identity"`
+	// Status 是提交的判题状态
+	Status int `json:"status"`
+	// Score 是提交的得分
+	Score float64 `json:"score"`
+}
+
+// eventSubscriber 是一个 SSE 连接的订阅
+type eventSubscriber struct {
+	userIdentity    string
+	contestIdentity string
+	ch              chan *Event
+}
+
+// eventHub 将实时事件分发给本进程的订阅者
+// Redis 可用时事件经 Redis 发布订阅广播到所有节点，判题节点和 HTTP 节点可以分开部署；否则只在进程内分发
+type eventHub struct {
+	rdb  *redis.Client
+	lock sync.Mutex
+	subs map[*eventSubscriber]struct{}
+}
+
+// events 是全局的实时事件中心
+var events = &eventHub{subs: make(map[*eventSubscriber]struct{})}
+
+// StartEvents 启动实时事件中心，Redis 可用时订阅事件频道
+func StartEvents() {
+	ctx := context.Background()
+	if err := models.RDB.Ping(ctx).Err(); err != nil {
+		log.Printf("Redis 不可用，实时事件只在进程内分发: %v", err)
+		return
+	}
+	events.rdb = models.RDB
+	go events.listen(ctx)
+}
+
+// listen 接收 Redis 频道中的事件并分发，连接断开时 go-redis 会自动重连
+func (h *eventHub) listen(ctx context.Context) {
+	pubsub := h.rdb.Subscribe(ctx, eventChannel)
+	defer pubsub.Close()
+	for msg := range pubsub.Channel() {
+		ev := new(Event)
+		if err := json.Unmarshal([]byte(msg.Payload), ev); err != nil {
+			log.Printf("Event Decode Error: %v", err)
+			continue
+		}
+		h.dispatch(ev)
+	}
+}
+
+// publish 发布事件，Redis 发布失败时退化为进程内分发
+func (h *eventHub) publish(ev *Event) {
+	if h.rdb != nil {
+		data, err := json.Marshal(ev)
+		if err == nil {
+			err = h.rdb.Publish(context.Background(), eventChannel, data).Err()
+		}
+		if err == nil {
+			return
+		}
+		log.Printf("Event Publish Error: %v", err)
+	}
+	h.dispatch(ev)
+}
+
+// dispatch 将事件发给匹配的订阅者，订阅者来不及接收时丢弃事件，不阻塞判题
+func (h *eventHub) dispatch(ev *Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for sub := range h.subs {
+		if (ev.UserIdentity == "" || ev.UserIdentity != sub.userIdentity) &&
+			(ev.ContestIdentity == "" || ev.ContestIdentity != sub.contestIdentity) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// subscribe 订阅用户自己的提交事件，contestIdentity 不为空时同时订阅该竞赛的榜单事件
+func (h *eventHub) subscribe(userIdentity, contestIdentity string) *eventSubscriber {
+	sub := &eventSubscriber{userIdentity: userIdentity, contestIdentity: contestIdentity, ch: make(chan *Event, 64)}
+	h.lock.Lock()
+	h.subs[sub] = struct{}{}
+	h.lock.Unlock()
+	return sub
+}
+
+// unsubscribe 取消订阅
+func (h *eventHub) unsubscribe(sub *eventSubscriber) {
+	h.lock.Lock()
+	delete(h.subs, sub)
+	h.lock.Unlock()
+}
+
+// publishSubmitProgress 发布提交记录的判题进度
+func publishSubmitProgress(sb *models.SubmitBasic, stage string, done, total int) {
+	events.publish(&Event{
+		Type:         EventSubmit,
+		UserIdentity: sb.UserIdentity,
+		Data: &SubmitEvent{
+			SubmitIdentity:  sb.Identity,
+			ProblemIdentity: sb.ProblemIdentity,
+			Stage:           stage,
+			Done:            done,
+			Total:           total,
+		},
+	})
+}
+
+// publishSubmitFinished 发布提交记录的最终结果，提交属于进行中的竞赛时同时发布榜单变化
+func publishSubmitFinished(sb *models.SubmitBasic, result *judge.Result) {
+	events.publish(&Event{
+		Type:         EventSubmit,
+		UserIdentity: sb.UserIdentity,
+		Data: &SubmitEvent{
+			SubmitIdentity:  sb.Identity,
+			ProblemIdentity: sb.ProblemIdentity,
+			Stage:           submitStageFinished,
+			Status:          result.Status,
+			Score:           result.Score,
+			TimeUsed:        result.TimeUsed,
+			MemUsed:         result.MemUsed,
+			Msg:             result.Msg,
+		},
+	})
+
+	// 查询包含该问题、提交者已报名且正在进行的竞赛
+	contests := make([]*models.ContestBasic, 0)
+	now := time.Now()
+	err := models.DB.Model(new(models.ContestBasic)).Select("contest_basic.identity").
+		Joins("JOIN contest_problem cp ON cp.contest_id = contest_basic.id AND cp.deleted_at IS NULL").
+		Joins("JOIN problem_basic pb ON pb.id = cp.problem_id").
+		Joins("JOIN contest_user cu ON cu.contest_id = contest_basic.id AND cu.deleted_at IS NULL").
+		Where("pb.identity = ? AND cu.user_identity = ? AND contest_basic.start_at <= ? AND contest_basic.end_at >= ?",
+			sb.ProblemIdentity, sb.UserIdentity, now, now).
+		Find(&contests).Error
+	if err != nil {
+		log.Printf("Find Submit Contests Error: %v, submit: %s", err, sb.Identity)
+		return
+	}
+	for _, cb := range contests {
+		events.publish(&Event{
+			Type:            EventScoreboard,
+			ContestIdentity: cb.Identity,
+			Data: &ScoreboardEvent{
+				ContestIdentity: cb.Identity,
+				UserIdentity:    sb.UserIdentity,
+				ProblemIdentity: sb.ProblemIdentity,
+				SubmitIdentity:  sb.Identity,
+				Status:          result.Status,
+				Score:           result.Score,
+			},
+		})
+	}
+}
+
+// SubmitEvents
+// @Tags 用户私有方法
+// @Summary 实时事件流
+// @Produce text/event-stream
+// @Param authorization header string false "authorization"
+// @Param token query string false "token，浏览器的 EventSource 不能设置请求头时使用"
+// @Param contest_identity query string false "同时订阅该竞赛的榜单变化"
+// @Success 200 {string} string "event: submit\ndata: {...}"
+// @Router /user/events [get]
+// SubmitEvents 函数以 Server-Sent Events 推送当前用户提交记录的判题进度（compiling、running k/N、finished），
+// 指定 contest_identity 时同时推送该竞赛的榜单变化
+func SubmitEvents(c *gin.Context) {
+	u, _ := c.Get("user_claims")
+	userClaim, ok := u.(*middlewares.UserClaims)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "用户认证信息解析失败",
+		})
+		return
+	}
+	contestIdentity := c.Query("contest_identity")
+	if contestIdentity != "" {
+		err := models.DB.Select("id").Where("identity = ?", contestIdentity).First(new(models.ContestBasic)).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusOK, gin.H{
+					"code": -1,
+					"msg":  "竞赛不存在",
+				})
+				return
+			}
+			log.Printf("Get Contest Error: %v, identity: %s", err, contestIdentity)
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "获取竞赛失败：" + err.Error(),
+			})
+			return
+		}
+	}
+
+	sub := events.subscribe(userClaim.Identity, contestIdentity)
+	defer events.unsubscribe(sub)
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 的响应缓冲
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case ev := <-sub.ch:
+			c.SSEvent(ev.Type, ev.Data)
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+		}
+		return true
+	})
+}
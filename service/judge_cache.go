@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/utils"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"time"
+)
+
+// judgeCacheKeyPrefix 是 Redis 中判题结果缓存的键前缀，后接 utils.SourceHash 的结果
+const judgeCacheKeyPrefix = "oj:judge:cache:"
+
+// setSourceHash 计算提交记录的判题结果缓存键并保存，问题的测试数据版本取判题时的版本
+// 没有在数据库中保存源代码的早期提交不计算
+func setSourceHash(sb *models.SubmitBasic, pb *models.ProblemBasic) error {
+	if sb.Code == "" {
+		return nil
+	}
+	sb.SourceHash = utils.SourceHash(sb.Language, sb.Code, pb.Identity, pb.TestDataVersion)
+	return models.DB.Model(new(models.SubmitBasic)).Where("identity = ?", sb.Identity).
+		Update("source_hash", sb.SourceHash).Error
+}
+
+// judgeFromCache 在相同代码之前判过时直接复用其判题结果，返回是否命中
+// 重判的目的就是重新运行，不使用缓存
+func judgeFromCache(job *JudgeJob, sb *models.SubmitBasic) (bool, error) {
+	if !utils.JudgeCache || job.RejudgeIdentity != "" || sb.SourceHash == "" {
+		return false, nil
+	}
+	result := loadJudgeCache(context.Background(), sb)
+	if result == nil {
+		return false, nil
+	}
+	sb.Cached = true
+	if err := finishSubmit(sb, result); err != nil {
+		sb.Cached = false
+		return false, err
+	}
+	removeCode(sb.Path)
+	return true, nil
+}
+
+// loadJudgeCache 查找相同缓存键的判题结果，先查 Redis，未命中时查找数据库中最近一次已完成的提交
+func loadJudgeCache(ctx context.Context, sb *models.SubmitBasic) *judge.Result {
+	key := judgeCacheKeyPrefix + sb.SourceHash
+	raw, err := models.RDB.Get(ctx, key).Result()
+	if err == nil {
+		result := new(judge.Result)
+		if err = json.Unmarshal([]byte(raw), result); err == nil {
+			return result
+		}
+		log.Printf("Judge Cache Decode Error: %v, key: %s", err, key)
+	} else if !errors.Is(err, redis.Nil) {
+		log.Printf("Judge Cache Get Error: %v, key: %s", err, key)
+	}
+
+	prev := new(models.SubmitBasic)
+	err = models.DB.Where("source_hash = ? AND identity <> ? AND status NOT IN ?", sb.SourceHash, sb.Identity,
+		[]int{define.SubmitStatusPending, define.SubmitStatusSystemError}).Order("id DESC").Limit(1).Find(prev).Error
+	if err != nil {
+		log.Printf("Judge Cache Query Error: %v, submit: %s", err, sb.Identity)
+		return nil
+	}
+	if prev.ID == 0 {
+		return nil
+	}
+	rows := make([]*models.SubmitCaseResult, 0)
+	if err = models.DB.Where("submit_identity = ?", prev.Identity).Order("id ASC").Find(&rows).Error; err != nil {
+		log.Printf("Judge Cache Query Error: %v, submit: %s", err, prev.Identity)
+		return nil
+	}
+	result := &judge.Result{
+		Status:   prev.Status,
+		Msg:      prev.Msg,
+		TimeUsed: prev.TimeUsed,
+		MemUsed:  prev.MemUsed,
+		Score:    prev.Score,
+	}
+	for _, row := range rows {
+		if row.Status == define.SubmitStatusAccepted {
+			result.PassCount++
+		}
+		result.Cases = append(result.Cases, &judge.CaseResult{
+			Identity: row.TestCaseIdentity,
+			Subtask:  row.Subtask,
+			Status:   row.Status,
+			TimeUsed: row.TimeUsed,
+			MemUsed:  row.MemUsed,
+			ExitCode: row.ExitCode,
+			Score:    row.Score,
+			Msg:      row.Msg,
+		})
+	}
+	storeJudgeCache(ctx, sb.SourceHash, result)
+	return result
+}
+
+// storeJudgeCache 把判题结果写入 Redis，判题系统错误与代码无关，不缓存
+func storeJudgeCache(ctx context.Context, hash string, result *judge.Result) {
+	if !utils.JudgeCache || hash == "" || result.Status == define.SubmitStatusSystemError {
+		return
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Judge Cache Encode Error: %v", err)
+		return
+	}
+	ttl := time.Duration(utils.JudgeCacheTTL) * time.Hour
+	if err = models.RDB.Set(ctx, judgeCacheKeyPrefix+hash, raw, ttl).Err(); err != nil {
+		log.Printf("Judge Cache Set Error: %v, hash: %s", err, hash)
+	}
+}
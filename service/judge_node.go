@@ -0,0 +1,598 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/judge/node"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/storage"
+	"gin_gorm_oj/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxPullWait 是判题节点拉取任务时最多等待的时间（秒）
+const maxPullWait = 30
+
+// nodeHeartbeat 返回判题节点的心跳间隔，节点超时时间内至少有三次心跳
+func nodeHeartbeat() time.Duration {
+	return max(time.Duration(utils.JudgeNodeTimeout)*time.Second/3, time.Second)
+}
+
+// nodeQueue 返回判题节点的队列，节点拉取的任务放在以节点标识命名的处理中列表里
+// 独立判题节点需要 Redis 判题队列，调用前应通过 remoteJudgeAvailable 检查
+func nodeQueue(identity string) *redisJudgeQueue {
+	return &redisJudgeQueue{rdb: models.RDB, processingKey: judgeProcessingKeyPrefix + identity}
+}
+
+// remoteJudgeAvailable 判断能否接受独立判题节点，进程内队列无法在节点之间共享
+func remoteJudgeAvailable() bool {
+	_, ok := judgeQueue.(*redisJudgeQueue)
+	return ok
+}
+
+// watchJudgeNodes 定时检查判题节点的心跳，把超时的节点标记为离线，并将其处理中的任务放回待判队列
+func watchJudgeNodes(ctx context.Context) {
+	ticker := time.NewTicker(nodeHeartbeat())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deadline := models.MyTime(time.Now().Add(-time.Duration(utils.JudgeNodeTimeout) * time.Second))
+		nodes := make([]*models.JudgeNode, 0)
+		err := models.DB.Where("status = ? AND heartbeat_at < ?", models.JudgeNodeOnline, deadline).Find(&nodes).Error
+		if err != nil {
+			log.Printf("Find Dead Judge Nodes Error: %v", err)
+			continue
+		}
+		for _, jn := range nodes {
+			// 多个 API 进程同时检查时只有一个能把节点标记为离线
+			res := models.DB.Model(new(models.JudgeNode)).
+				Where("identity = ? AND status = ? AND heartbeat_at < ?", jn.Identity, models.JudgeNodeOnline, deadline).
+				Update("status", models.JudgeNodeOffline)
+			if res.Error != nil {
+				log.Printf("Mark Judge Node Offline Error: %v, node: %s", res.Error, jn.Identity)
+				continue
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			log.Printf("Judge Node %s (%s) is offline, reassigning its jobs", jn.Name, jn.Identity)
+			if err = nodeQueue(jn.Identity).Recover(ctx); err != nil {
+				log.Printf("Recover Judge Node Jobs Error: %v, node: %s", err, jn.Identity)
+			}
+		}
+	}
+}
+
+// nodeOrphans 记录判题节点处理中列表里、但心跳没有报告正在判题的任务第一次被发现的时间
+// 节点拉取任务的响应丢失时任务会留在处理中列表里，节点仍然在线，需要靠心跳发现并重新分配
+var nodeOrphans = struct {
+	sync.Mutex
+	seen map[string]map[string]time.Time // 节点标识 -> 任务原始内容 -> 第一次发现的时间
+}{seen: make(map[string]map[string]time.Time)}
+
+// reconcileNodeJobs 把判题节点长时间没有报告的处理中任务放回待判队列
+func reconcileNodeJobs(ctx context.Context, identity string, running []string) {
+	q := nodeQueue(identity)
+	jobs, err := q.processingJobs(ctx)
+	if err != nil {
+		log.Printf("List Judge Node Jobs Error: %v, node: %s", err, identity)
+		return
+	}
+	isRunning := make(map[string]bool, len(running))
+	for _, submitIdentity := range running {
+		isRunning[submitIdentity] = true
+	}
+
+	nodeOrphans.Lock()
+	defer nodeOrphans.Unlock()
+	now := time.Now()
+	seen := nodeOrphans.seen[identity]
+	orphans := make(map[string]time.Time)
+	for _, job := range jobs {
+		if isRunning[job.SubmitIdentity] {
+			continue
+		}
+		first, ok := seen[job.raw]
+		if !ok {
+			orphans[job.raw] = now
+			continue
+		}
+		if now.Sub(first) < time.Duration(utils.JudgeNodeTimeout)*time.Second {
+			orphans[job.raw] = first
+			continue
+		}
+		log.Printf("Judge Node %s lost job of submit %s, reassigning", identity, job.SubmitIdentity)
+		if err = q.Retry(ctx, job); err != nil {
+			log.Printf("Reassign Judge Node Job Error: %v, submit: %s", err, job.SubmitIdentity)
+			orphans[job.raw] = first
+		}
+	}
+	nodeOrphans.seen[identity] = orphans
+}
+
+// buildNodeJob 根据判题任务构造下发给判题节点的任务，提交记录不存在、已经判过或命中判题结果缓存时返回 nil
+func buildNodeJob(job *JudgeJob) (*node.Job, error) {
+	sb, pb, err := loadJudgeSubmit(job.SubmitIdentity)
+	if err != nil || sb == nil {
+		return nil, err
+	}
+	if hit, err := judgeFromCache(job, sb); hit || err != nil {
+		return nil, err
+	}
+	code := sb.Code
+	if code == "" {
+		// 早期的提交没有在数据库中保存源代码，从代码文件读取
+		b, err := os.ReadFile(sb.Path)
+		if err != nil {
+			return nil, err
+		}
+		code = string(b)
+	}
+	nj := &node.Job{
+		Ticket:         job.raw,
+		SubmitIdentity: sb.Identity,
+		Language:       sb.Language,
+		Code:           code,
+		MaxRuntime:     pb.MaxRuntime,
+		MaxMem:         pb.MaxMem,
+		CompareMode:    pb.CompareMode,
+		FloatEpsilon:   pb.FloatEpsilon,
+		JudgeMode:      pb.JudgeMode,
+		Interactive:    pb.Interactive(),
+		Harness:        pb.Harness(sb.Language),
+		TestCases:      make([]*node.TestCase, 0, len(pb.TestCases)),
+	}
+	if policy := pb.CodePolicy(); policy != nil {
+		nj.AllowImports, nj.DenyImports = policy.AllowImports, policy.DenyImports
+	}
+	for _, tc := range pb.TestCases {
+		ntc := &node.TestCase{Identity: tc.Identity, Subtask: tc.Subtask, Stored: tc.Stored()}
+		if ntc.Stored {
+			ntc.InputHash, ntc.OutputHash = tc.InputHash, tc.OutputHash
+		} else {
+			ntc.InputHash, ntc.OutputHash = node.Checksum(tc.Input), node.Checksum(tc.Output)
+		}
+		nj.TestCases = append(nj.TestCases, ntc)
+	}
+	for _, st := range pb.Subtasks {
+		nj.Subtasks = append(nj.Subtasks, &define.Subtask{Number: st.Number, Score: st.Score, Depends: subtaskDepends(st)})
+	}
+	if pb.CheckerVersion > 0 {
+		pc := new(models.ProblemChecker)
+		err = models.DB.Where("problem_identity = ? AND version = ?", pb.Identity, pb.CheckerVersion).First(pc).Error
+		if err != nil {
+			return nil, err
+		}
+		nj.Checker = &node.Checker{Language: pc.Language, Code: pc.Code}
+	}
+	return nj, nil
+}
+
+// JudgeNodeRegister
+// @Tags 判题节点
+// @Summary 判题节点注册
+// @Param X-Judge-Token header string true "判题节点共享密钥"
+// @Param data body node.RegisterRequest true "RegisterRequest"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /judge-node/register [post]
+// JudgeNodeRegister 函数用于独立判题节点注册，同名节点重新注册时视为节点重启，其处理中的任务放回待判队列
+func JudgeNodeRegister(c *gin.Context) {
+	in := new(node.RegisterRequest)
+	if err := c.ShouldBindJSON(in); err != nil || in.Name == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if !remoteJudgeAvailable() {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "判题队列不可用，独立判题节点需要 Redis",
+		})
+		return
+	}
+
+	now := models.MyTime(time.Now())
+	jn := new(models.JudgeNode)
+	err := models.DB.Where("name = ?", in.Name).First(jn).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		jn = &models.JudgeNode{
+			Identity:    utils.GetUUID(),
+			Name:        in.Name,
+			Address:     c.ClientIP(),
+			Workers:     in.Workers,
+			Status:      models.JudgeNodeOnline,
+			HeartbeatAt: now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		err = models.DB.Create(jn).Error
+	case err == nil:
+		// 节点重启后之前拉取的任务都已丢失
+		if err = nodeQueue(jn.Identity).Recover(c.Request.Context()); err != nil {
+			break
+		}
+		err = models.DB.Model(new(models.JudgeNode)).Where("identity = ?", jn.Identity).Updates(map[string]interface{}{
+			"address":      c.ClientIP(),
+			"workers":      in.Workers,
+			"running":      0,
+			"status":       models.JudgeNodeOnline,
+			"heartbeat_at": now,
+			"updated_at":   now,
+		}).Error
+	}
+	if err != nil {
+		log.Printf("Judge Node Register Error: %v, name: %s", err, in.Name)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "判题节点注册失败：" + err.Error(),
+		})
+		return
+	}
+	log.Printf("Judge Node %s registered as %s from %s", in.Name, jn.Identity, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": &node.RegisterResponse{
+			NodeIdentity: jn.Identity,
+			Heartbeat:    int(nodeHeartbeat() / time.Second),
+		},
+	})
+}
+
+// JudgeNodeHeartbeat
+// @Tags 判题节点
+// @Summary 判题节点心跳
+// @Param X-Judge-Token header string true "判题节点共享密钥"
+// @Param data body node.HeartbeatRequest true "HeartbeatRequest"
+// @Success 200 {string} json "{"code":"200","msg":""}"
+// @Router /judge-node/heartbeat [post]
+// JudgeNodeHeartbeat 函数用于更新判题节点的心跳时间和负载，已被标记为离线的节点恢复在线
+func JudgeNodeHeartbeat(c *gin.Context) {
+	in := new(node.HeartbeatRequest)
+	if err := c.ShouldBindJSON(in); err != nil || in.NodeIdentity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	now := models.MyTime(time.Now())
+	res := models.DB.Model(new(models.JudgeNode)).Where("identity = ?", in.NodeIdentity).Updates(map[string]interface{}{
+		"address":      c.ClientIP(),
+		"running":      len(in.Running),
+		"status":       models.JudgeNodeOnline,
+		"heartbeat_at": now,
+		"updated_at":   now,
+	})
+	if res.Error != nil {
+		log.Printf("Judge Node Heartbeat Error: %v, node: %s", res.Error, in.NodeIdentity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "更新心跳失败：" + res.Error.Error(),
+		})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "判题节点未注册",
+		})
+		return
+	}
+	reconcileNodeJobs(c.Request.Context(), in.NodeIdentity, in.Running)
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "ok",
+	})
+}
+
+// JudgeNodePull
+// @Tags 判题节点
+// @Summary 判题节点拉取任务
+// @Param X-Judge-Token header string true "判题节点共享密钥"
+// @Param data body node.PullRequest true "PullRequest"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /judge-node/pull [post]
+// JudgeNodePull 函数用于判题节点拉取一个待判任务，没有任务时最多等待 wait 秒，超时返回的 data 为 null
+func JudgeNodePull(c *gin.Context) {
+	in := new(node.PullRequest)
+	if err := c.ShouldBindJSON(in); err != nil || in.NodeIdentity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if !remoteJudgeAvailable() {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "判题队列不可用，独立判题节点需要 Redis",
+		})
+		return
+	}
+	var count int64
+	if err := models.DB.Model(new(models.JudgeNode)).Where("identity = ?", in.NodeIdentity).Count(&count).Error; err != nil || count == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "判题节点未注册",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	q := nodeQueue(in.NodeIdentity)
+	deadline := time.Now().Add(time.Duration(min(max(in.Wait, 1), maxPullWait)) * time.Second)
+	for {
+		// Redis 的阻塞超时以秒为单位，0 表示一直阻塞
+		remaining := time.Until(deadline)
+		if remaining < time.Second {
+			break
+		}
+		job, err := q.Pop(ctx, remaining)
+		if err != nil {
+			log.Printf("Judge Node Pull Error: %v, node: %s", err, in.NodeIdentity)
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "拉取任务失败：" + err.Error(),
+			})
+			return
+		}
+		if job == nil {
+			break
+		}
+		nj, err := buildNodeJob(job)
+		if err != nil {
+			log.Printf("Build Judge Node Job Error: %v, submit: %s", err, job.SubmitIdentity)
+			job.Attempts++
+			completeJudgeJob(ctx, q, job, err)
+			continue
+		}
+		if nj == nil {
+			completeJudgeJob(ctx, q, job, nil) // 提交记录不存在、已经判过或命中判题结果缓存
+			continue
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"data": nj,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": nil,
+	})
+}
+
+// JudgeNodeTestData
+// @Tags 判题节点
+// @Summary 判题节点下载测试数据
+// @Param X-Judge-Token header string true "判题节点共享密钥"
+// @Param identity query string true "测试用例唯一标识"
+// @Param hash query string true "输入或预期输出的校验和"
+// @Produce octet-stream
+// @Success 200 {string} binary "测试数据的原始内容"
+// @Router /judge-node/test-data [get]
+// JudgeNodeTestData 函数用于判题节点按校验和下载测试用例的输入或预期输出，判题节点按校验和缓存
+// 响应体是测试数据的原始字节，保存为文件的测试数据从 storage 流式读取，不整体读入内存；出错时返回 JSON 格式的错误信息
+func JudgeNodeTestData(c *gin.Context) {
+	identity, hash := c.Query("identity"), c.Query("hash")
+	tc := new(models.TestCase)
+	err := models.DB.Select("input", "output", "input_hash", "output_hash").Where("identity = ?", identity).First(tc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "测试用例不存在",
+			})
+			return
+		}
+		log.Printf("Get Test Case Error: %v, identity: %s", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取测试用例失败：" + err.Error(),
+		})
+		return
+	}
+	if !tc.Stored() {
+		// 保存在数据库中的测试数据按内容计算校验和，与下发任务时一致
+		switch hash {
+		case node.Checksum(tc.Input):
+			c.Data(http.StatusOK, "application/octet-stream", []byte(tc.Input))
+		case node.Checksum(tc.Output):
+			c.Data(http.StatusOK, "application/octet-stream", []byte(tc.Output))
+		default:
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "测试数据不存在",
+			})
+		}
+		return
+	}
+	if hash != tc.InputHash && hash != tc.OutputHash {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "测试数据不存在",
+		})
+		return
+	}
+	rc, err := storage.Default().Open(c.Request.Context(), hash)
+	if err != nil {
+		log.Printf("Read Test Data Error: %v, identity: %s, hash: %s", err, identity, hash)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "读取测试数据失败：" + err.Error(),
+		})
+		return
+	}
+	defer rc.Close()
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", rc, nil)
+}
+
+// readStoredTestCase 从 storage 读取测试用例的输入和预期输出
+func readStoredTestCase(ctx context.Context, tc *models.TestCase) (input, output string, err error) {
+	read := func(hash string) (string, error) {
+		rc, err := storage.Default().Open(ctx, hash)
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		return string(b), err
+	}
+	if input, err = read(tc.InputHash); err != nil {
+		return "", "", err
+	}
+	if output, err = read(tc.OutputHash); err != nil {
+		return "", "", err
+	}
+	return input, output, nil
+}
+
+// JudgeNodeProgress
+// @Tags 判题节点
+// @Summary 判题节点报告进度
+// @Param X-Judge-Token header string true "判题节点共享密钥"
+// @Param data body node.ProgressRequest true "ProgressRequest"
+// @Success 200 {string} json "{"code":"200","msg":""}"
+// @Router /judge-node/progress [post]
+// JudgeNodeProgress 函数用于判题节点报告判题进度，进度通过实时事件推送给提交者
+func JudgeNodeProgress(c *gin.Context) {
+	in := new(node.ProgressRequest)
+	if err := c.ShouldBindJSON(in); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	job, err := parseJudgeJob(in.Ticket)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "任务凭据无效",
+		})
+		return
+	}
+	sb := new(models.SubmitBasic)
+	err = models.DB.Select("identity", "user_identity", "problem_identity").Where("identity = ?", job.SubmitIdentity).First(sb).Error
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "提交记录不存在",
+		})
+		return
+	}
+	publishSubmitProgress(sb, in.Stage, in.Done, in.Total)
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "ok",
+	})
+}
+
+// JudgeNodeResult
+// @Tags 判题节点
+// @Summary 判题节点上报结果
+// @Param X-Judge-Token header string true "判题节点共享密钥"
+// @Param data body node.ResultRequest true "ResultRequest"
+// @Success 200 {string} json "{"code":"200","msg":""}"
+// @Router /judge-node/result [post]
+// JudgeNodeResult 函数用于判题节点上报判题结果，判题节点自身出错时按重试规则放回待判队列或标记为系统错误
+func JudgeNodeResult(c *gin.Context) {
+	in := new(node.ResultRequest)
+	if err := c.ShouldBindJSON(in); err != nil || in.NodeIdentity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	job, err := parseJudgeJob(in.Ticket)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "任务凭据无效",
+		})
+		return
+	}
+
+	switch {
+	case in.Error != "":
+		err = errors.New("判题节点错误：" + in.Error)
+	case in.Result == nil:
+		err = errors.New("判题节点没有上报结果")
+	default:
+		err = finishNodeSubmit(job.SubmitIdentity, in.Result)
+	}
+	job.Attempts++
+	completeJudgeJob(c.Request.Context(), nodeQueue(in.NodeIdentity), job, err)
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "ok",
+	})
+}
+
+// finishNodeSubmit 保存判题节点上报的结果，提交记录不存在或已经判过时忽略
+func finishNodeSubmit(identity string, result *judge.Result) error {
+	sb := new(models.SubmitBasic)
+	err := models.DB.Where("identity = ?", identity).First(sb).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = finishSubmit(sb, result); err != nil {
+		return err
+	}
+	removeCode(sb.Path)
+	return nil
+}
+
+// GetJudgeNodeList
+// @Tags 管理员私有方法
+// @Summary 判题节点列表
+// @Param authorization header string true "authorization"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/judge-node-list [get]
+// GetJudgeNodeList 函数用于获取独立判题节点的状态和负载，以及待判队列的长度
+func GetJudgeNodeList(c *gin.Context) {
+	list := make([]*models.JudgeNode, 0)
+	if err := models.DB.Order("id ASC").Find(&list).Error; err != nil {
+		log.Printf("Get Judge Node List Error: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取判题节点列表失败：" + err.Error(),
+		})
+		return
+	}
+	var pending int64
+	if remoteJudgeAvailable() {
+		n, err := models.RDB.LLen(c.Request.Context(), judgePendingKey).Result()
+		if err != nil {
+			log.Printf("Get Judge Queue Length Error: %v", err)
+		}
+		pending = n
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"list":    list,
+			"pending": pending,
+		},
+	})
+}
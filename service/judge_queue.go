@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/models"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"time"
+)
+
+// JudgeJob 表示判题队列中的一个任务
+type JudgeJob struct {
+	// SubmitIdentity 是待判提交记录的唯一标识
+	SubmitIdentity string `json:"submit_identity"`
+	// Attempts 是该任务已经尝试判题的次数
+	Attempts int `json:"attempts"`
+	// RejudgeIdentity 是该任务所属重判任务的唯一标识，普通提交为空
+	RejudgeIdentity string `json:"rejudge_identity,omitempty"`
+	// raw 是任务在 Redis 中的原始内容，确认任务时需要按原值从处理中队列删除
+	raw string
+}
+
+// JudgeQueue 是判题队列的抽象
+// Redis 可用时使用 Redis 列表持久化任务，否则退化为进程内队列
+type JudgeQueue interface {
+	// Push 将任务放入待判队列
+	Push(ctx context.Context, job *JudgeJob) error
+	// Pop 阻塞获取一个任务，超时返回 nil
+	Pop(ctx context.Context, timeout time.Duration) (*JudgeJob, error)
+	// Ack 确认任务已处理完毕
+	Ack(ctx context.Context, job *JudgeJob) error
+	// Retry 将处理失败的任务重新放回待判队列
+	Retry(ctx context.Context, job *JudgeJob) error
+	// Recover 把本节点处理中的任务移回待判队列，用于进程重启或判题节点离线
+	Recover(ctx context.Context) error
+	// Requeue 把数据库中仍处于待判状态、但不在待判队列中的提交重新放入队列，只在进程启动时调用一次
+	Requeue(ctx context.Context) error
+}
+
+const (
+	// judgePendingKey 是 Redis 中待判任务列表的键名
+	judgePendingKey = "oj:judge:pending"
+	// judgeProcessingKeyPrefix 是 Redis 中各节点处理中任务列表的键名前缀
+	judgeProcessingKeyPrefix = "oj:judge:processing:"
+)
+
+// redisJudgeQueue 基于 Redis 列表实现的可靠队列
+// 取任务时使用 BRPOPLPUSH 原子地移入本节点的处理中列表，确认后再删除，
+// 进程崩溃后可从处理中列表恢复任务
+type redisJudgeQueue struct {
+	rdb           *redis.Client
+	processingKey string
+}
+
+// NewRedisJudgeQueue 创建基于 Redis 的判题队列，node 为当前判题节点名称
+func NewRedisJudgeQueue(rdb *redis.Client, node string) JudgeQueue {
+	return &redisJudgeQueue{rdb: rdb, processingKey: judgeProcessingKeyPrefix + node}
+}
+
+func (q *redisJudgeQueue) Push(ctx context.Context, job *JudgeJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.rdb.LPush(ctx, judgePendingKey, string(b)).Err()
+}
+
+func (q *redisJudgeQueue) Pop(ctx context.Context, timeout time.Duration) (*JudgeJob, error) {
+	raw, err := q.rdb.BRPopLPush(ctx, judgePendingKey, q.processingKey, timeout).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil // 超时，没有任务
+	}
+	if err != nil {
+		return nil, err
+	}
+	job, err := parseJudgeJob(raw)
+	if err != nil {
+		// 无法解析的任务直接丢弃，避免反复阻塞队列
+		log.Printf("Judge Queue Unmarshal Error: %v, raw: %s", err, raw)
+		q.rdb.LRem(ctx, q.processingKey, 1, raw)
+		return nil, nil
+	}
+	return job, nil
+}
+
+// processingJobs 返回本节点处理中列表里的全部任务，无法解析的任务被忽略
+func (q *redisJudgeQueue) processingJobs(ctx context.Context) ([]*JudgeJob, error) {
+	raws, err := q.rdb.LRange(ctx, q.processingKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*JudgeJob, 0, len(raws))
+	for _, raw := range raws {
+		if job, err := parseJudgeJob(raw); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// parseJudgeJob 解析任务在 Redis 中的原始内容，并保留原始内容用于确认任务
+func parseJudgeJob(raw string) (*JudgeJob, error) {
+	job := new(JudgeJob)
+	if err := json.Unmarshal([]byte(raw), job); err != nil {
+		return nil, err
+	}
+	job.raw = raw
+	return job, nil
+}
+
+func (q *redisJudgeQueue) Ack(ctx context.Context, job *JudgeJob) error {
+	return q.rdb.LRem(ctx, q.processingKey, 1, job.raw).Err()
+}
+
+func (q *redisJudgeQueue) Retry(ctx context.Context, job *JudgeJob) error {
+	// 先放回待判队列再从处理中队列删除，中途崩溃最多导致重复判题，不会丢失任务
+	if err := q.Push(ctx, job); err != nil {
+		return err
+	}
+	return q.Ack(ctx, job)
+}
+
+// Recover 把本节点处理中列表里的任务全部移回待判队列
+func (q *redisJudgeQueue) Recover(ctx context.Context) error {
+	n := 0
+	for {
+		err := q.rdb.RPopLPush(ctx, q.processingKey, judgePendingKey).Err()
+		if errors.Is(err, redis.Nil) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		log.Printf("Judge Queue Recovered %d jobs from %s", n, q.processingKey)
+	}
+	return nil
+}
+
+// Requeue 把数据库中仍处于待判状态、但不在待判队列中的提交重新放入队列
+// 提交记录保存后、放入队列前进程崩溃时，提交只存在于数据库中，需要靠这里找回；
+// 此时正被其他节点处理的提交可能被重复判题，finishSubmit 只保存第一次的结果
+func (q *redisJudgeQueue) Requeue(ctx context.Context) error {
+	// 先读取数据库再读取待判队列，查询期间放入队列的提交不会被重复放入
+	jobs, err := pendingJudgeJobs()
+	if err != nil {
+		return err
+	}
+	raws, err := q.rdb.LRange(ctx, judgePendingKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	queued := make(map[string]bool, len(raws))
+	for _, raw := range raws {
+		if job, err := parseJudgeJob(raw); err == nil {
+			queued[job.SubmitIdentity] = true
+		}
+	}
+	n := 0
+	for _, job := range jobs {
+		if queued[job.SubmitIdentity] {
+			continue
+		}
+		if err = q.Push(ctx, job); err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		log.Printf("Judge Queue Recovered %d pending submissions from database", n)
+	}
+	return nil
+}
+
+// memoryJudgeQueue 是 Redis 不可用时的进程内队列
+// 任务不做持久化，进程重启后通过数据库中待判的提交记录恢复
+type memoryJudgeQueue struct {
+	jobs chan *JudgeJob
+}
+
+// NewMemoryJudgeQueue 创建进程内判题队列，size 为队列容量
+func NewMemoryJudgeQueue(size int) JudgeQueue {
+	return &memoryJudgeQueue{jobs: make(chan *JudgeJob, size)}
+}
+
+func (q *memoryJudgeQueue) Push(ctx context.Context, job *JudgeJob) error {
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return errors.New("判题队列已满")
+	}
+}
+
+func (q *memoryJudgeQueue) Pop(ctx context.Context, timeout time.Duration) (*JudgeJob, error) {
+	select {
+	case job := <-q.jobs:
+		return job, nil
+	case <-time.After(timeout):
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *memoryJudgeQueue) Ack(ctx context.Context, job *JudgeJob) error {
+	return nil
+}
+
+func (q *memoryJudgeQueue) Retry(ctx context.Context, job *JudgeJob) error {
+	return q.Push(ctx, job)
+}
+
+func (q *memoryJudgeQueue) Recover(ctx context.Context) error {
+	// 进程内队列没有处理中列表，崩溃后丢失的任务由 Requeue 找回
+	return nil
+}
+
+func (q *memoryJudgeQueue) Requeue(ctx context.Context) error {
+	// 进程内队列在崩溃后会丢失，重新把数据库中仍处于待判状态的提交放入队列
+	jobs, err := pendingJudgeJobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err = q.Push(ctx, job); err != nil {
+			return err
+		}
+	}
+	if len(jobs) > 0 {
+		log.Printf("Judge Queue Recovered %d pending submissions from database", len(jobs))
+	}
+	return nil
+}
+
+// pendingJudgeJobs 按提交顺序返回数据库中仍处于待判状态的提交对应的判题任务，重判中的提交带上重判任务的标识
+func pendingJudgeJobs() ([]*JudgeJob, error) {
+	list := make([]*models.SubmitBasic, 0)
+	err := models.DB.Select("identity", "rejudge_identity").Where("status = ?", define.SubmitStatusPending).
+		Order("id ASC").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*JudgeJob, 0, len(list))
+	for _, sb := range list {
+		jobs = append(jobs, &JudgeJob{SubmitIdentity: sb.Identity, RejudgeIdentity: sb.RejudgeIdentity})
+	}
+	return jobs, nil
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/judge/sandbox"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/storage"
+	"gin_gorm_oj/utils"
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// judgeQueue 是全局的判题队列，由 StartJudge 初始化
+var judgeQueue JudgeQueue
+
+// StartJudge 初始化判题队列并启动 workers 个判题协程
+// Redis 可用时使用 Redis 队列，否则退化为进程内队列
+func StartJudge(workers int) {
+	ctx := context.Background()
+	setupExecutor()
+	if err := models.RDB.Ping(ctx).Err(); err == nil {
+		// 处理中列表以节点名称命名，名称变化后旧列表中的任务不会再被恢复，因此必须显式配置
+		if utils.JudgeNode == "" {
+			log.Fatal("使用 Redis 判题队列时必须配置 [judge] JudgeNode，且重启前后保持不变")
+		}
+		judgeQueue = NewRedisJudgeQueue(models.RDB, utils.JudgeNode)
+	} else {
+		log.Printf("Redis 不可用，判题队列退化为进程内队列: %v", err)
+		judgeQueue = NewMemoryJudgeQueue(4096)
+	}
+	// 恢复进程崩溃前未完成的判题任务，数据库只在启动时扫描一次，离线节点的任务只从其处理中列表恢复
+	if err := judgeQueue.Recover(ctx); err != nil {
+		log.Printf("Judge Queue Recover Error: %v", err)
+	}
+	if err := judgeQueue.Requeue(ctx); err != nil {
+		log.Printf("Judge Queue Requeue Error: %v", err)
+	}
+	// 继续未完成的重判任务，需要在判题队列可用之后进行
+	resumeRejudges()
+	// 启用独立判题节点时检查节点心跳，重新分配离线节点的任务
+	if utils.JudgeNodeToken != "" && remoteJudgeAvailable() {
+		go watchJudgeNodes(ctx)
+	}
+	for i := 0; i < workers; i++ {
+		go judgeWorker(ctx, i)
+	}
+}
+
+// setupExecutor 根据配置选择运行用户程序的执行后端
+func setupExecutor() {
+	limits := sandbox.DefaultLimits
+	limits.FileSize = uint64(utils.JudgeFileSize) << 20
+	limits.Processes = uint64(utils.JudgeNproc)
+	limits.OpenFiles = uint64(utils.JudgeNofile)
+	if err := judge.SetupSandbox(utils.JudgeSandbox, limits, utils.JudgeSandboxPaths); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// judgeWorker 循环从队列中获取任务并判题
+func judgeWorker(ctx context.Context, id int) {
+	for {
+		job, err := judgeQueue.Pop(ctx, 5*time.Second)
+		if err != nil {
+			log.Printf("Judge Worker %d Pop Error: %v", id, err)
+			time.Sleep(time.Second) // 队列异常时稍作等待，避免空转
+			continue
+		}
+		if job == nil {
+			continue
+		}
+		handleJudgeJob(ctx, job)
+	}
+}
+
+// handleJudgeJob 处理单个判题任务，失败时按次数重试，超过最大重试次数后标记为系统错误
+func handleJudgeJob(ctx context.Context, job *JudgeJob) {
+	job.Attempts++
+	completeJudgeJob(ctx, judgeQueue, job, judgeSubmit(job))
+}
+
+// completeJudgeJob 根据判题是否出错确认或重试任务，q 是任务所在的队列
+// 本地判题协程和独立判题节点上报结果时共用
+func completeJudgeJob(ctx context.Context, q JudgeQueue, job *JudgeJob, err error) {
+	if err == nil {
+		if err = q.Ack(ctx, job); err != nil {
+			log.Printf("Judge Queue Ack Error: %v, submit: %s", err, job.SubmitIdentity)
+		}
+		rejudgeProgress(job.RejudgeIdentity)
+		return
+	}
+	log.Printf("Judge Submit Error: %v, submit: %s, attempts: %d", err, job.SubmitIdentity, job.Attempts)
+	if job.Attempts < utils.JudgeMaxRetry {
+		time.Sleep(time.Duration(job.Attempts) * time.Second) // 按重试次数退避
+		if err = q.Retry(ctx, job); err != nil {
+			log.Printf("Judge Queue Retry Error: %v, submit: %s", err, job.SubmitIdentity)
+		}
+		return
+	}
+	// 超过最大重试次数，标记为系统错误并清理代码
+	sb := new(models.SubmitBasic)
+	if err = models.DB.Where("identity = ?", job.SubmitIdentity).First(sb).Error; err == nil {
+		if err = finishSubmit(sb, &judge.Result{Status: define.SubmitStatusSystemError, Msg: "判题系统错误"}); err != nil {
+			log.Printf("Judge Submit Finish Error: %v, submit: %s", err, job.SubmitIdentity)
+		}
+		removeCode(sb.Path)
+	}
+	if err = q.Ack(ctx, job); err != nil {
+		log.Printf("Judge Queue Ack Error: %v, submit: %s", err, job.SubmitIdentity)
+	}
+	rejudgeProgress(job.RejudgeIdentity)
+}
+
+// judgeSubmit 对一条提交记录进行判题并保存结果
+func judgeSubmit(job *JudgeJob) (err error) {
+	// 判题过程中的 panic 视为一次失败，交由重试机制处理
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("judge panic: %v", r)
+		}
+	}()
+
+	sb, pb, err := loadJudgeSubmit(job.SubmitIdentity)
+	if err != nil || sb == nil {
+		return err
+	}
+	if hit, err := judgeFromCache(job, sb); hit || err != nil {
+		return err // 相同的代码已经判过，直接复用结果
+	}
+	if err = restoreCode(sb); err != nil {
+		return err
+	}
+
+	task := &judge.Task{
+		Path:         sb.Path,
+		Language:     sb.Language,
+		MaxRuntime:   pb.MaxRuntime,
+		MaxMem:       pb.MaxMem,
+		CompareMode:  pb.CompareMode,
+		FloatEpsilon: pb.FloatEpsilon,
+		Mode:         pb.JudgeMode,
+		Interactive:  pb.Interactive(),
+		Harness:      pb.Harness(sb.Language),
+		Policy:       pb.CodePolicy(),
+		OutputLimit:  utils.JudgeOutputLimit << 20,
+		TestCases:    make([]*judge.Case, 0, len(pb.TestCases)),
+		Progress: func(stage string, done, total int) {
+			publishSubmitProgress(sb, stage, done, total) // 推送判题进度
+		},
+	}
+	for _, tc := range pb.TestCases {
+		jc, err := newJudgeCase(tc)
+		if err != nil {
+			return err
+		}
+		task.TestCases = append(task.TestCases, jc)
+	}
+	for _, st := range pb.Subtasks {
+		task.Subtasks = append(task.Subtasks, &judge.Subtask{Number: st.Number, Score: st.Score, Depends: subtaskDepends(st)})
+	}
+	if pb.CheckerVersion > 0 {
+		task.Checker, err = loadChecker(pb)
+		if err != nil {
+			return err
+		}
+		defer removeCode(task.Checker.Path)
+	}
+	result := judge.Run(task)
+	if err = finishSubmit(sb, result); err != nil {
+		return err
+	}
+	removeCode(sb.Path)
+	return nil
+}
+
+// loadJudgeSubmit 读取待判的提交记录和关联的问题，预加载测试用例和子任务，记录判题使用的问题版本并计算判题结果缓存键
+// 提交记录已不存在或已经判过（例如崩溃恢复导致的重复任务）时 sb 为 nil，无需判题也无需重试
+func loadJudgeSubmit(identity string) (sb *models.SubmitBasic, pb *models.ProblemBasic, err error) {
+	sb = new(models.SubmitBasic)
+	err = models.DB.Where("identity = ?", identity).First(sb).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Judge Submit Not Found: %s", identity)
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if sb.Status != define.SubmitStatusPending {
+		return nil, nil, nil
+	}
+
+	// 从数据库中查询关联的问题信息，并预加载测试用例。
+	pb = new(models.ProblemBasic)
+	err = models.DB.Where("identity = ?", sb.ProblemIdentity).Preload("TestCases", func(db *gorm.DB) *gorm.DB {
+		return db.Order(models.TestCaseOrder)
+	}).Preload("Templates").
+		Preload("Subtasks", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number ASC") // 子任务只依赖编号更小的子任务，按编号排序即为依赖顺序
+		}).First(pb).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	// 记录判题时问题的版本，重判时更新为重判时的版本
+	sb.ProblemRevision = pb.Revision
+	err = models.DB.Model(new(models.SubmitBasic)).Where("identity = ?", sb.Identity).
+		Update("problem_revision", pb.Revision).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = setSourceHash(sb, pb); err != nil {
+		return nil, nil, err
+	}
+	return sb, pb, nil
+}
+
+// newJudgeCase 把测试用例转换为判题引擎的测试用例，保存在 storage 中的测试数据以本地文件路径传递，判题时直接作为标准输入
+func newJudgeCase(tc *models.TestCase) (*judge.Case, error) {
+	jc := &judge.Case{Identity: tc.Identity, Input: tc.Input, Output: tc.Output, Subtask: tc.Subtask}
+	if !tc.Stored() {
+		return jc, nil
+	}
+	var err error
+	ctx := context.Background()
+	if jc.InputFile, err = storage.Default().Path(ctx, tc.InputHash); err != nil {
+		return nil, fmt.Errorf("load input of test case %s: %w", tc.Identity, err)
+	}
+	if jc.OutputFile, err = storage.Default().Path(ctx, tc.OutputHash); err != nil {
+		return nil, fmt.Errorf("load output of test case %s: %w", tc.Identity, err)
+	}
+	return jc, nil
+}
+
+// subtaskDepends 解析子任务以逗号分隔的依赖编号
+func subtaskDepends(st *models.ProblemSubtask) []int {
+	var depends []int
+	for _, dep := range strings.Split(st.Depends, ",") {
+		if n, err := strconv.Atoi(dep); err == nil {
+			depends = append(depends, n)
+		}
+	}
+	return depends
+}
+
+// restoreCode 在代码文件不存在时（例如重判）根据数据库中保存的源代码重新生成代码文件，并更新提交记录的路径
+func restoreCode(sb *models.SubmitBasic) error {
+	if _, err := os.Stat(sb.Path); err == nil || sb.Code == "" {
+		return nil // 没有保存代码的旧提交只能使用原有的代码文件
+	}
+	lang, ok := judge.GetLanguage(sb.Language)
+	if !ok {
+		return fmt.Errorf("unsupported language: %s", sb.Language)
+	}
+	path, err := utils.CodeSaveAs([]byte(sb.Code), lang.SourceFile())
+	if err != nil {
+		return err
+	}
+	err = models.DB.Model(new(models.SubmitBasic)).Where("identity = ?", sb.Identity).Update("path", path).Error
+	if err != nil {
+		removeCode(path)
+		return err
+	}
+	sb.Path = path
+	return nil
+}
+
+// loadChecker 读取问题当前版本的特判程序，并将代码保存到文件系统，判题完成后由调用方删除
+func loadChecker(pb *models.ProblemBasic) (*judge.Checker, error) {
+	pc := new(models.ProblemChecker)
+	err := models.DB.Where("problem_identity = ? AND version = ?", pb.Identity, pb.CheckerVersion).First(pc).Error
+	if err != nil {
+		return nil, fmt.Errorf("load checker version %d: %w", pb.CheckerVersion, err)
+	}
+	lang, ok := judge.GetLanguage(pc.Language)
+	if !ok {
+		return nil, fmt.Errorf("unsupported checker language: %s", pc.Language)
+	}
+	path, err := utils.CodeSaveAs([]byte(pc.Code), lang.SourceFile())
+	if err != nil {
+		return nil, err
+	}
+	return &judge.Checker{Path: path, Language: pc.Language}, nil
+}
+
+// finishSubmit 保存判题结果和各测试用例的结果，并在答案正确时更新用户和问题的通过数，保存后写入判题结果缓存并推送最终结果
+// 只更新仍处于待判状态的记录，保证重复的任务不会重复计数；同时清除重判标记
+func finishSubmit(sb *models.SubmitBasic, result *judge.Result) error {
+	updated := false
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(new(models.SubmitBasic)).
+			Where("identity = ? AND status = ?", sb.Identity, define.SubmitStatusPending).
+			Updates(map[string]interface{}{
+				"status":           result.Status,
+				"time_used":        result.TimeUsed,
+				"mem_used":         result.MemUsed,
+				"score":            result.Score,
+				"msg":              result.Msg,
+				"cached":           sb.Cached,
+				"path":             "", // 源代码保存在数据库中，代码文件判题完成后即被删除
+				"rejudge_identity": "", // 清除重判标记，计入重判任务的进度
+				"updated_at":       models.MyTime(time.Now()),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		updated = true
+		if err := saveCaseResults(tx, sb.Identity, result.Cases); err != nil {
+			return err
+		}
+		if result.Status != define.SubmitStatusAccepted {
+			return nil
+		}
+		m := map[string]interface{}{"pass_num": gorm.Expr("pass_num + ?", 1)}
+		if err := tx.Model(new(models.UserBasic)).Where("identity = ?", sb.UserIdentity).Updates(m).Error; err != nil {
+			return err
+		}
+		return tx.Model(new(models.ProblemBasic)).Where("identity = ?", sb.ProblemIdentity).Updates(m).Error
+	})
+	if err == nil && updated {
+		if !sb.Cached {
+			storeJudgeCache(context.Background(), sb.SourceHash, result)
+		}
+		publishSubmitFinished(sb, result)
+	}
+	return err
+}
+
+// saveCaseResults 保存一次提交在各测试用例上的结果，已有的结果会被替换
+func saveCaseResults(tx *gorm.DB, submitIdentity string, cases []*judge.CaseResult) error {
+	err := tx.Where("submit_identity = ?", submitIdentity).Delete(new(models.SubmitCaseResult)).Error
+	if err != nil || len(cases) == 0 {
+		return err
+	}
+	now := models.MyTime(time.Now())
+	rows := make([]*models.SubmitCaseResult, 0, len(cases))
+	for _, cr := range cases {
+		rows = append(rows, &models.SubmitCaseResult{
+			SubmitIdentity:   submitIdentity,
+			TestCaseIdentity: cr.Identity,
+			Subtask:          cr.Subtask,
+			Status:           cr.Status,
+			TimeUsed:         cr.TimeUsed,
+			MemUsed:          cr.MemUsed,
+			ExitCode:         cr.ExitCode,
+			Score:            cr.Score,
+			Msg:              cr.Msg,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		})
+	}
+	return tx.Create(&rows).Error
+}
+
+// removeCode 删除提交代码所在的目录
+func removeCode(path string) {
+	if path == "" {
+		return
+	}
+	dir := filepath.Dir(path)
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("Failed to delete directory %s: %v", dir, err)
+	}
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
 	"gin_gorm_oj/models"
 	"gin_gorm_oj/utils"
 	"github.com/gin-gonic/gin"
@@ -10,6 +12,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -98,7 +101,14 @@ func GetProblemDetail(c *gin.Context) { // 定义 GetProblemDetail 函数，它
 	// 使用 GORM 构建查询，查找 identity 字段与给定值匹配的问题。
 	// 预加载关联的 ProblemCategories 和 ProblemCategories 下的 CategoryBasic 信息。
 	// 执行查询，尝试获取第一条匹配的记录，并将结果填充到 data 中。
-	err := models.DB.Where("identity = ?", identity).Preload("ProblemCategories").Preload("ProblemCategories.CategoryBasic").First(&data).Error
+	err := models.DB.Where("identity = ?", identity).Preload("ProblemCategories").Preload("ProblemCategories.CategoryBasic").
+		Preload("Subtasks", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number ASC")
+		}).
+		Preload("Templates", func(db *gorm.DB) *gorm.DB {
+			// 只返回初始代码，驱动模板不对用户展示
+			return db.Select("id", "problem_identity", "language", "starter").Order("language ASC")
+		}).First(&data).Error
 	if err != nil { // 检查查询是否发生错误。
 		if errors.Is(err, gorm.ErrRecordNotFound) { // 如果错误是 GORM 的记录未找到错误。
 			c.JSON(http.StatusOK, gin.H{ // 返回 JSON 格式的响应。
@@ -114,6 +124,15 @@ func GetProblemDetail(c *gin.Context) { // 定义 GetProblemDetail 函数，它
 		})
 		return // 终止函数执行。
 	}
+	// 只返回样例的输入输出，其余测试用例对用户隐藏
+	if data.Samples, err = loadSamples(c.Request.Context(), identity); err != nil {
+		log.Printf("GetProblemDetail: 查询样例错误: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取问题样例失败：" + err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{ // 如果问题详情查询成功，返回 JSON 响应。
 		"code": 200,  // 设置响应状态码为 200，表示成功。
 		"data": data, // 返回问题详情数据。
@@ -140,24 +159,42 @@ func ProblemCreate(c *gin.Context) { // 定义 ProblemCreate 函数，它是一
 		return // 终止函数执行。
 	}
 
-	// 检查所有必填字段是否为空或零值。
-	if in.Title == "" || in.Content == "" || len(in.ProblemCategories) == 0 || len(in.TestCases) == 0 || in.MaxRuntime == 0 || in.MaxMem == 0 {
+	// 检查所有必填字段是否为空或零值，其余参数按创建、修改和导入共同的规则检查。
+	if len(in.ProblemCategories) == 0 || len(in.TestCases) == 0 {
 		c.JSON(http.StatusOK, gin.H{ // 返回 JSON 格式的响应。
 			"code": -1,            // 设置自定义错误码为 -1。
 			"msg":  "必填参数不能为空或零值", // 设置错误信息。
 		})
 		return // 终止函数执行。
 	}
+	if err = validateProblem(in); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	// 允许的语言和代码检查规则以逗号分隔保存
+	languages := strings.Join(in.Languages, ",")
+	allowImports, denyImports := strings.Join(in.AllowImports, ","), strings.Join(in.DenyImports, ",")
 
 	identity := utils.GetUUID()   // 调用 utils 包的 GetUUID 函数，生成一个唯一的 UUID 作为问题标识。
 	data := &models.ProblemBasic{ // 创建一个新的 models.ProblemBasic 实例。
-		Identity:   identity,                  // 设置问题的唯一标识。
-		Title:      in.Title,                  // 设置问题标题。
-		Content:    in.Content,                // 设置问题内容。
-		MaxRuntime: in.MaxRuntime,             // 设置最大运行时间。
-		MaxMem:     in.MaxMem,                 // 设置最大内存限制。
-		CreatedAt:  models.MyTime(time.Now()), // 设置创建时间为当前时间。
-		UpdatedAt:  models.MyTime(time.Now()), // 设置更新时间为当前时间。
+		Identity:          identity,                  // 设置问题的唯一标识。
+		Title:             in.Title,                  // 设置问题标题。
+		Content:           in.Content,                // 设置问题内容。
+		MaxRuntime:        in.MaxRuntime,             // 设置最大运行时间。
+		MaxMem:            in.MaxMem,                 // 设置最大内存限制。
+		Languages:         languages,                 // 设置允许提交的语言。
+		CompareMode:       in.CompareMode,            // 设置输出比较方式。
+		FloatEpsilon:      in.FloatEpsilon,           // 设置浮点数比较误差。
+		JudgeMode:         in.JudgeMode,              // 设置判题模式。
+		ProblemType:       in.ProblemType,            // 设置问题类型。
+		FunctionSignature: in.FunctionSignature,      // 设置函数签名。
+		AllowImports:      allowImports,              // 设置允许导入的包。
+		DenyImports:       denyImports,               // 设置禁止导入的包。
+		CreatedAt:         models.MyTime(time.Now()), // 设置创建时间为当前时间。
+		UpdatedAt:         models.MyTime(time.Now()), // 设置更新时间为当前时间。
 	}
 
 	// 处理分类
@@ -174,13 +211,17 @@ func ProblemCreate(c *gin.Context) { // 定义 ProblemCreate 函数，它是一
 
 	// 处理测试用例
 	testCaseBasics := make([]*models.TestCase, 0) // 初始化一个 TestCase 结构体指针的切片，用于存放测试用例。
-	for _, v := range in.TestCases {              // 遍历输入中提供的测试用例列表。
+	for i, v := range in.TestCases {              // 遍历输入中提供的测试用例列表。
 		// 举个例子 {"input":"1 2\n","output":"3\n"}
 		testCaseBasic := &models.TestCase{ // 创建一个新的 TestCase 实例。
 			Identity:        utils.GetUUID(),           // 为测试用例生成一个唯一的标识。
 			ProblemIdentity: identity,                  // 设置测试用例所属的问题标识。
 			Input:           v.Input,                   // 设置测试用例的输入。
 			Output:          v.Output,                  // 设置测试用例的输出。
+			Subtask:         v.Subtask,                 // 设置测试用例所属的子任务。
+			IsSample:        v.IsSample,                // 设置测试用例是否为样例。
+			Explanation:     v.Explanation,             // 设置样例说明。
+			Sort:            i + 1,                     // 按提交的顺序排列。
 			CreatedAt:       models.MyTime(time.Now()), // 设置创建时间。
 			UpdatedAt:       models.MyTime(time.Now()), // 设置更新时间。
 		}
@@ -188,9 +229,20 @@ func ProblemCreate(c *gin.Context) { // 定义 ProblemCreate 函数，它是一
 	}
 	data.TestCases = testCaseBasics // 将处理好的测试用例切片赋值给 data 结构体的 TestCases 字段。
 
+	// 处理子任务和代码模板
+	data.Subtasks = newProblemSubtasks(identity, in.Subtasks)
+	data.Templates = newProblemTemplates(identity, in.Templates)
+
 	// 创建问题
-	err = models.DB.Create(data).Error // 使用 GORM 的 Create 方法将 data（包含问题、分类和测试用例）保存到数据库中。
-	if err != nil {                    // 检查数据库创建操作是否发生错误。
+	// 使用 GORM 的 Create 方法将 data（包含问题、分类和测试用例）保存到数据库中，并保存第一个版本快照。
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(data).Error; err != nil {
+			return err
+		}
+		_, err := recordRevision(tx, identity, "创建问题")
+		return err
+	})
+	if err != nil { // 检查数据库创建操作是否发生错误。
 		log.Printf("ProblemCreate Error: %v\n", err) // 记录详细错误日志
 		c.JSON(http.StatusOK, gin.H{                 // 返回 JSON 格式的响应。
 			"code": -1,                      // 设置自定义错误码为 -1。
@@ -225,14 +277,26 @@ func ProblemModify(c *gin.Context) { // 定义 ProblemModify 函数，用于处
 		return // 终止函数执行
 	}
 
-	// 检查所有必填字段是否为空或零值
-	if in.Identity == "" || in.Title == "" || in.Content == "" || len(in.ProblemCategories) == 0 || len(in.TestCases) == 0 || in.MaxRuntime == 0 || in.MaxMem == 0 {
+	// 检查所有必填字段是否为空或零值，其余参数按创建、修改和导入共同的规则检查
+	// 测试用例为空时保留原有的测试数据（例如通过压缩包上传的测试数据），按原有的测试用例检查子任务划分
+	if in.Identity == "" || len(in.ProblemCategories) == 0 {
 		c.JSON(http.StatusOK, gin.H{ // 返回JSON格式错误响应
 			"code": -1,            // 设置自定义错误码
 			"msg":  "必填参数不能为空或零值", // 设置错误信息
 		})
 		return // 终止函数执行
 	}
+	if err = validateProblem(in); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	keepTestCases := len(in.TestCases) == 0
+	// 允许的语言和代码检查规则以逗号分隔保存
+	languages := strings.Join(in.Languages, ",")
+	allowImports, denyImports := strings.Join(in.AllowImports, ","), strings.Join(in.DenyImports, ",")
 
 	// 使用GORM事务确保所有数据库操作的原子性
 	if err := models.DB.Transaction(func(tx *gorm.DB) error {
@@ -251,6 +315,27 @@ func ProblemModify(c *gin.Context) { // 定义 ProblemModify 函数，用于处
 			log.Printf("ProblemModify: 更新问题基本信息错误: %v, identity: %s\n", err, in.Identity) // 记录详细错误日志
 			return err                                                                    // 返回错误，触发事务回滚
 		}
+		// 允许的语言、代码检查规则和判题模式可以被清空（表示使用默认值），浮点数误差可以被置 0，需要单独更新
+		// 测试用例和限制随修改一起提交，递增测试数据版本使之前的判题结果缓存失效
+		err = tx.Model(new(models.ProblemBasic)).Where("identity = ?", in.Identity).Updates(map[string]interface{}{
+			"languages":          languages,
+			"compare_mode":       in.CompareMode,
+			"float_epsilon":      in.FloatEpsilon,
+			"judge_mode":         in.JudgeMode,
+			"problem_type":       in.ProblemType,
+			"function_signature": in.FunctionSignature,
+			"allow_imports":      allowImports,
+			"deny_imports":       denyImports,
+			"test_data_version":  gorm.Expr("test_data_version + ?", 1),
+		}).Error
+		if err == nil && !keepTestCases {
+			// 测试用例随请求一起提交，之前上传的测试数据被替换
+			err = tx.Model(new(models.ProblemBasic)).Where("identity = ?", in.Identity).Update("test_data_checksum", "").Error
+		}
+		if err != nil {
+			log.Printf("ProblemModify: 更新判题设置错误: %v, identity: %s\n", err, in.Identity)
+			return err
+		}
 
 		// 查询问题详情，以便获取其ID用于关联表的更新
 		err = tx.Where("identity = ?", in.Identity).Find(problemBasic).Error
@@ -282,30 +367,69 @@ func ProblemModify(c *gin.Context) { // 定义 ProblemModify 函数，用于处
 			return err                                                                         // 返回错误，触发事务回滚
 		}
 
-		// 关联测试案例的更新
-		// 1、删除已存在的关联关系
-		err = tx.Where("problem_identity = ?", in.Identity).Delete(new(models.TestCase)).Error
-		if err != nil { // 检查删除是否出错
-			log.Printf("ProblemModify: 删除旧测试案例错误: %v, problem_identity: %s\n", err, in.Identity) // 记录详细错误日志
-			return err                                                                           // 返回错误，触发事务回滚
+		// 请求中没有测试用例时保留原有的测试数据
+		if !keepTestCases {
+			// 关联测试案例的更新
+			// 1、删除已存在的关联关系
+			err = tx.Where("problem_identity = ?", in.Identity).Delete(new(models.TestCase)).Error
+			if err != nil { // 检查删除是否出错
+				log.Printf("ProblemModify: 删除旧测试案例错误: %v, problem_identity: %s\n", err, in.Identity) // 记录详细错误日志
+				return err                                                                           // 返回错误，触发事务回滚
+			}
+			// 2、增加新的关联关系
+			tcs := make([]*models.TestCase, 0) // 创建TestCase切片
+			for i, v := range in.TestCases {   // 遍历新的测试案例
+				// 举个例子 {"input":"1 2\n","output":"3\n"}
+				tcs = append(tcs, &models.TestCase{ // 添加新的测试案例
+					Identity:        utils.GetUUID(),           // 测试案例唯一标识
+					ProblemIdentity: in.Identity,               // 所属问题标识
+					Input:           v.Input,                   // 输入数据
+					Output:          v.Output,                  // 输出数据
+					Subtask:         v.Subtask,                 // 所属子任务
+					IsSample:        v.IsSample,                // 是否为样例
+					Explanation:     v.Explanation,             // 样例说明
+					Sort:            i + 1,                     // 顺序
+					CreatedAt:       models.MyTime(time.Now()), // 创建时间
+					UpdatedAt:       models.MyTime(time.Now()), // 更新时间
+				})
+			}
+			err = tx.Create(tcs).Error // 批量创建新的测试案例
+			if err != nil {            // 检查创建是否出错
+				log.Printf("ProblemModify: 创建新测试案例错误: %v, problem_identity: %s\n", err, in.Identity) // 记录详细错误日志
+				return err                                                                           // 返回错误，触发事务回滚
+			}
 		}
-		// 2、增加新的关联关系
-		tcs := make([]*models.TestCase, 0) // 创建TestCase切片
-		for _, v := range in.TestCases {   // 遍历新的测试案例
-			// 举个例子 {"input":"1 2\n","output":"3\n"}
-			tcs = append(tcs, &models.TestCase{ // 添加新的测试案例
-				Identity:        utils.GetUUID(),           // 测试案例唯一标识
-				ProblemIdentity: in.Identity,               // 所属问题标识
-				Input:           v.Input,                   // 输入数据
-				Output:          v.Output,                  // 输出数据
-				CreatedAt:       models.MyTime(time.Now()), // 创建时间
-				UpdatedAt:       models.MyTime(time.Now()), // 更新时间
-			})
+
+		// 子任务的更新：删除旧的子任务后重新创建
+		err = tx.Where("problem_identity = ?", in.Identity).Delete(new(models.ProblemSubtask)).Error
+		if err != nil {
+			log.Printf("ProblemModify: 删除旧子任务错误: %v, problem_identity: %s\n", err, in.Identity)
+			return err
+		}
+		if subtasks := newProblemSubtasks(in.Identity, in.Subtasks); len(subtasks) > 0 {
+			if err = tx.Create(subtasks).Error; err != nil {
+				log.Printf("ProblemModify: 创建新子任务错误: %v, problem_identity: %s\n", err, in.Identity)
+				return err
+			}
+		}
+
+		// 代码模板的更新：删除旧的模板后重新创建
+		err = tx.Where("problem_identity = ?", in.Identity).Delete(new(models.ProblemTemplate)).Error
+		if err != nil {
+			log.Printf("ProblemModify: 删除旧代码模板错误: %v, problem_identity: %s\n", err, in.Identity)
+			return err
 		}
-		err = tx.Create(tcs).Error // 批量创建新的测试案例
-		if err != nil {            // 检查创建是否出错
-			log.Printf("ProblemModify: 创建新测试案例错误: %v, problem_identity: %s\n", err, in.Identity) // 记录详细错误日志
-			return err                                                                           // 返回错误，触发事务回滚
+		if templates := newProblemTemplates(in.Identity, in.Templates); len(templates) > 0 {
+			if err = tx.Create(templates).Error; err != nil {
+				log.Printf("ProblemModify: 创建新代码模板错误: %v, problem_identity: %s\n", err, in.Identity)
+				return err
+			}
+		}
+
+		// 保存修改后的版本快照
+		if _, err = recordRevision(tx, in.Identity, "修改问题"); err != nil {
+			log.Printf("ProblemModify: 保存版本快照错误: %v, identity: %s\n", err, in.Identity)
+			return err
 		}
 		return nil // 事务成功，返回nil
 	}); err != nil { // 检查事务是否出错
@@ -320,3 +444,203 @@ func ProblemModify(c *gin.Context) { // 定义 ProblemModify 函数，用于处
 		"msg":  "问题修改成功", // 设置成功信息
 	})
 }
+
+// validateProblem 检查创建、修改和导入问题时共同的参数，并把可省略的设置补全为默认值
+// 没有提交测试用例时按数据库中已有的测试用例检查子任务划分，各自特有的必填参数由调用方检查
+func validateProblem(in *define.ProblemBasic) error {
+	if in.Title == "" || in.Content == "" || in.MaxRuntime <= 0 || in.MaxMem <= 0 {
+		return errors.New("必填参数不能为空或零值")
+	}
+	if len(in.Identity) > 36 {
+		return errors.New("问题唯一标识过长：" + in.Identity)
+	}
+	if err := checkLanguages(in); err != nil {
+		return err
+	}
+	if err := checkCompareMode(in); err != nil {
+		return err
+	}
+	if !judge.ValidJudgeMode(in.JudgeMode) {
+		return errors.New("不支持的判题模式：" + in.JudgeMode)
+	}
+	if err := checkProblemType(in); err != nil {
+		return err
+	}
+	if err := checkTemplates(in); err != nil {
+		return err
+	}
+	if err := checkImports(in); err != nil {
+		return err
+	}
+	if len(in.TestCases) == 0 {
+		return checkStoredSubtasks(in)
+	}
+	return checkSubtasks(in)
+}
+
+// checkLanguages 检查允许提交的语言是否受支持，并去掉重复的语言
+func checkLanguages(in *define.ProblemBasic) error {
+	seen := make(map[string]struct{}, len(in.Languages))
+	res := make([]string, 0, len(in.Languages))
+	for _, name := range in.Languages {
+		if _, ok := judge.GetLanguage(name); !ok || name == "" {
+			return errors.New("不支持的语言：" + name + "，可选：" + strings.Join(judge.LanguageNames(), ","))
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		res = append(res, name)
+	}
+	in.Languages = res
+	return nil
+}
+
+// checkCompareMode 检查输出比较方式和浮点数误差，比较方式为空时使用完全一致
+func checkCompareMode(in *define.ProblemBasic) error {
+	if !judge.ValidCompareMode(in.CompareMode) {
+		return errors.New("不支持的输出比较方式：" + in.CompareMode)
+	}
+	if in.CompareMode == "" {
+		in.CompareMode = judge.CompareExact
+	}
+	if in.FloatEpsilon < 0 || in.FloatEpsilon >= 1 {
+		return errors.New("浮点数误差必须在 [0, 1) 范围内")
+	}
+	return nil
+}
+
+// checkProblemType 检查问题类型，为空时设为标准题
+func checkProblemType(in *define.ProblemBasic) error {
+	switch in.ProblemType {
+	case "":
+		in.ProblemType = define.ProblemTypeStandard
+	case define.ProblemTypeStandard, define.ProblemTypeInteractive:
+	default:
+		return errors.New("不支持的问题类型：" + in.ProblemType)
+	}
+	return nil
+}
+
+// checkSubtasks 检查子任务划分：编号从 1 开始连续，分值为正，只能依赖编号更小的子任务，
+// 划分了子任务时每个测试用例都必须属于某个子任务，每个子任务至少包含一个测试用例
+func checkSubtasks(in *define.ProblemBasic) error {
+	caseCount := make(map[int]int, len(in.Subtasks))
+	for i, st := range in.Subtasks {
+		if st.Number != i+1 {
+			return errors.New("子任务编号必须从 1 开始连续编号")
+		}
+		if st.Score <= 0 {
+			return fmt.Errorf("子任务 %d 的分值必须为正数", st.Number)
+		}
+		for _, dep := range st.Depends {
+			if dep < 1 || dep >= st.Number {
+				return fmt.Errorf("子任务 %d 只能依赖编号更小的子任务", st.Number)
+			}
+		}
+		caseCount[st.Number] = 0
+	}
+	for i, tc := range in.TestCases {
+		if len(in.Subtasks) == 0 {
+			if tc.Subtask != 0 {
+				return fmt.Errorf("第 %d 个测试用例所属的子任务 %d 不存在", i+1, tc.Subtask)
+			}
+			continue
+		}
+		if _, ok := caseCount[tc.Subtask]; !ok {
+			return fmt.Errorf("第 %d 个测试用例必须属于一个已定义的子任务", i+1)
+		}
+		caseCount[tc.Subtask]++
+	}
+	for _, st := range in.Subtasks {
+		if caseCount[st.Number] == 0 {
+			return fmt.Errorf("子任务 %d 没有测试用例", st.Number)
+		}
+	}
+	return nil
+}
+
+// checkStoredSubtasks 按数据库中已有的测试用例检查子任务划分，用于修改问题时保留原有的测试数据
+func checkStoredSubtasks(in *define.ProblemBasic) error {
+	tcs := make([]*models.TestCase, 0)
+	err := models.DB.Select("subtask").Where("problem_identity = ?", in.Identity).Order(models.TestCaseOrder).Find(&tcs).Error
+	if err != nil {
+		return err
+	}
+	check := *in
+	check.TestCases = make([]*define.TestCase, 0, len(tcs))
+	for _, tc := range tcs {
+		check.TestCases = append(check.TestCases, &define.TestCase{Subtask: tc.Subtask})
+	}
+	return checkSubtasks(&check)
+}
+
+// newProblemSubtasks 将请求中的子任务转换为数据库模型
+func newProblemSubtasks(problemIdentity string, subtasks []*define.Subtask) []*models.ProblemSubtask {
+	res := make([]*models.ProblemSubtask, 0, len(subtasks))
+	for _, st := range subtasks {
+		depends := make([]string, 0, len(st.Depends))
+		for _, dep := range st.Depends {
+			depends = append(depends, strconv.Itoa(dep))
+		}
+		res = append(res, &models.ProblemSubtask{
+			ProblemIdentity: problemIdentity,
+			Number:          st.Number,
+			Score:           st.Score,
+			Depends:         strings.Join(depends, ","),
+			CreatedAt:       models.MyTime(time.Now()),
+			UpdatedAt:       models.MyTime(time.Now()),
+		})
+	}
+	return res
+}
+
+// checkTemplates 检查函数式问题的代码模板：语言受支持且不重复，驱动模板包含用户代码的占位符
+func checkTemplates(in *define.ProblemBasic) error {
+	seen := make(map[string]struct{}, len(in.Templates))
+	for _, t := range in.Templates {
+		lang, ok := judge.GetLanguage(t.Language)
+		if !ok || t.Language == "" {
+			return errors.New("代码模板的语言不受支持：" + t.Language)
+		}
+		if _, ok = seen[lang.Name()]; ok {
+			return errors.New("代码模板的语言重复：" + t.Language)
+		}
+		seen[lang.Name()] = struct{}{}
+		if !strings.Contains(t.Harness, judge.HarnessPlaceholder) {
+			return errors.New(t.Language + " 的驱动模板缺少用户代码的占位符 " + judge.HarnessPlaceholder)
+		}
+	}
+	return nil
+}
+
+// newProblemTemplates 根据请求中的代码模板构造模型
+func newProblemTemplates(problemIdentity string, templates []*define.ProblemTemplate) []*models.ProblemTemplate {
+	res := make([]*models.ProblemTemplate, 0, len(templates))
+	for _, t := range templates {
+		res = append(res, &models.ProblemTemplate{
+			ProblemIdentity: problemIdentity,
+			Language:        t.Language,
+			Starter:         t.Starter,
+			Harness:         t.Harness,
+			CreatedAt:       models.MyTime(time.Now()),
+			UpdatedAt:       models.MyTime(time.Now()),
+		})
+	}
+	return res
+}
+
+// checkImports 检查代码检查规则中的包路径，unsafe 和 cgo 始终被禁止，不能加入允许列表
+func checkImports(in *define.ProblemBasic) error {
+	for _, p := range in.AllowImports {
+		if p == "unsafe" || p == "C" {
+			return errors.New("不能允许导入 " + p + "，该包始终被禁止")
+		}
+	}
+	for _, p := range append(append([]string{}, in.AllowImports...), in.DenyImports...) {
+		if p == "" || strings.ContainsAny(p, ", \t\n\"") {
+			return errors.New("无效的包路径：" + strconv.Quote(p))
+		}
+	}
+	return nil
+}
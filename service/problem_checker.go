@@ -0,0 +1,254 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ProblemCheckerCreate
+// @Tags 管理员私有方法
+// @Summary 上传特判程序
+// @Accept json
+// @Param authorization header string true "authorization"
+// @Param data body define.ProblemChecker true "ProblemChecker"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/problem-checker-create [post]
+// ProblemCheckerCreate 为问题上传一个新版本的特判程序，并切换为使用该版本，交互题上传的是交互程序
+func ProblemCheckerCreate(c *gin.Context) {
+	in := new(define.ProblemChecker)
+	if err := c.ShouldBindJSON(in); err != nil {
+		log.Printf("[ProblemCheckerCreate JsonBind Error] : %v\n", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数解析错误",
+		})
+		return
+	}
+	if in.ProblemIdentity == "" || in.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题标识和特判程序代码不能为空",
+		})
+		return
+	}
+	lang, ok := judge.GetLanguage(in.Language)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "不支持的语言：" + in.Language,
+		})
+		return
+	}
+
+	// 编译失败的特判程序会让该问题的所有提交判为系统错误，因此先编译，失败时不保存
+	if err := compileChecker(lang, in.Code); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	checker := &models.ProblemChecker{
+		ProblemIdentity: in.ProblemIdentity,
+		Language:        lang.Name(),
+		Code:            in.Code,
+		CreatedAt:       models.MyTime(time.Now()),
+		UpdatedAt:       models.MyTime(time.Now()),
+	}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := createCheckerVersion(tx, checker); err != nil {
+			return err
+		}
+		_, err := recordRevision(tx, in.ProblemIdentity, fmt.Sprintf("上传特判程序版本 %d", checker.Version))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "问题不存在",
+			})
+			return
+		}
+		log.Printf("ProblemCheckerCreate Error: %v, problem_identity: %s\n", err, in.ProblemIdentity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "上传特判程序失败：" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"version": checker.Version,
+		},
+		"msg": "上传成功",
+	})
+}
+
+// compileChecker 把特判程序代码保存到临时目录并编译，编译失败或编译环境异常时返回错误，错误信息可以直接返回给管理员
+func compileChecker(lang judge.Language, code string) error {
+	path, err := utils.CodeSaveAs([]byte(code), lang.SourceFile())
+	if err != nil {
+		return fmt.Errorf("保存特判程序失败：%w", err)
+	}
+	defer removeCode(path)
+	msg, err := judge.CompileChecker(&judge.Checker{Path: path, Language: lang.Name()})
+	if err != nil {
+		log.Printf("Checker Compile System Error: %v\n", err)
+		return fmt.Errorf("特判程序编译环境异常：%w", err)
+	}
+	if msg != "" {
+		return errors.New("特判程序编译错误：" + msg)
+	}
+	return nil
+}
+
+// GetProblemCheckerList
+// @Tags 管理员私有方法
+// @Summary 特判程序版本列表
+// @Param authorization header string true "authorization"
+// @Param identity query string true "问题唯一标识"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/problem-checker-list [get]
+// GetProblemCheckerList 获取问题的所有特判程序版本，按版本号降序排列
+func GetProblemCheckerList(c *gin.Context) {
+	identity := c.Query("identity")
+	if identity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题唯一标识不能为空",
+		})
+		return
+	}
+	pb := new(models.ProblemBasic)
+	err := models.DB.Select("identity", "checker_version").Where("identity = ?", identity).First(pb).Error
+	if err != nil {
+		log.Printf("GetProblemCheckerList Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取问题信息失败：" + err.Error(),
+		})
+		return
+	}
+	list := make([]*models.ProblemChecker, 0)
+	err = models.DB.Where("problem_identity = ?", identity).Order("version DESC").Find(&list).Error
+	if err != nil {
+		log.Printf("GetProblemCheckerList Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取特判程序列表失败：" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"checker_version": pb.CheckerVersion, // 当前使用的版本
+			"list":            list,
+		},
+	})
+}
+
+// ProblemCheckerUse
+// @Tags 管理员私有方法
+// @Summary 切换特判程序版本
+// @Param authorization header string true "authorization"
+// @Param identity formData string true "问题唯一标识"
+// @Param version formData int true "特判程序版本，0 表示不使用特判程序"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/problem-checker-use [put]
+// ProblemCheckerUse 切换问题使用的特判程序版本，可用于回滚到旧版本或关闭特判
+func ProblemCheckerUse(c *gin.Context) {
+	identity := c.PostForm("identity")
+	version, err := strconv.Atoi(c.PostForm("version"))
+	if identity == "" || err != nil || version < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数不正确，identity 不能为空，version 必须为非负整数",
+		})
+		return
+	}
+	var count int64
+	err = models.DB.Model(new(models.ProblemBasic)).Where("identity = ?", identity).Count(&count).Error
+	if err != nil || count == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题不存在",
+		})
+		return
+	}
+	if version > 0 {
+		err = models.DB.Model(new(models.ProblemChecker)).
+			Where("problem_identity = ? AND version = ?", identity, version).Count(&count).Error
+		if err != nil || count == 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "特判程序版本不存在",
+			})
+			return
+		}
+	}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(new(models.ProblemBasic)).Where("identity = ?", identity).Updates(map[string]interface{}{
+			"checker_version":   version,
+			"test_data_version": gorm.Expr("test_data_version + ?", 1),
+		}).Error
+		if err != nil {
+			return err
+		}
+		_, err = recordRevision(tx, identity, "切换特判程序版本")
+		return err
+	})
+	if err != nil {
+		log.Printf("ProblemCheckerUse Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "切换特判程序失败：" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "切换成功",
+	})
+}
+
+// createCheckerVersion 在事务中为问题新增一个特判程序版本并切换为使用该版本，版本号为已有的最大版本号加一
+// 问题不存在时返回 gorm.ErrRecordNotFound
+func createCheckerVersion(tx *gorm.DB, checker *models.ProblemChecker) error {
+	// 锁定问题记录，避免并发上传得到相同的版本号
+	pb := new(models.ProblemBasic)
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "identity").
+		Where("identity = ?", checker.ProblemIdentity).First(pb).Error
+	if err != nil {
+		return err
+	}
+	var maxVersion int
+	err = tx.Model(new(models.ProblemChecker)).Unscoped().Where("problem_identity = ?", checker.ProblemIdentity).
+		Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error
+	if err != nil {
+		return err
+	}
+	checker.Version = maxVersion + 1
+	if err = tx.Create(checker).Error; err != nil {
+		return err
+	}
+	// 特判程序变化后之前的判题结果不再可信，递增测试数据版本使判题结果缓存失效
+	return tx.Model(new(models.ProblemBasic)).Where("identity = ?", checker.ProblemIdentity).Updates(map[string]interface{}{
+		"checker_version":   checker.Version,
+		"test_data_version": gorm.Expr("test_data_version + ?", 1),
+	}).Error
+}
@@ -0,0 +1,721 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/problempkg"
+	"gin_gorm_oj/storage"
+	"gin_gorm_oj/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProblemImportReport 是导入一个问题的结果，预览（dry_run）时描述导入将会做出的修改
+type ProblemImportReport struct {
+	// Identity 是问题的唯一标识，预览新建的问题且问题包中没有标识时为空
+	Identity string `json:"identity"`
+	// Title 是问题标题
+	Title string `json:"title"`
+	// Source 是问题包的格式：native、fps、polygon
+	Source string `json:"source"`
+	// Action 是 create（新建问题）或 update（更新已有的问题）
+	Action string `json:"action"`
+	// Changes 是导入会做出的修改
+	Changes []string `json:"changes"`
+	// Warnings 是问题包中无法完整导入的内容
+	Warnings []string `json:"warnings"`
+}
+
+// importPlan 是检查通过、等待导入的问题
+type importPlan struct {
+	pkg          *problempkg.Package
+	in           *define.ProblemBasic
+	languages    string
+	allowImports string
+	denyImports  string
+	// checker 是问题包中的特判程序，nil 表示不使用特判程序；checkerChanged 表示与问题当前使用的特判程序不同
+	checker        *models.ProblemChecker
+	checkerChanged bool
+	// existing 是已有的同一标识的问题，nil 表示新建问题
+	existing *models.ProblemBasic
+	report   *ProblemImportReport
+}
+
+// ProblemExport
+// @Tags 管理员私有方法
+// @Summary 导出问题包
+// @Param authorization header string true "authorization"
+// @Param identity query string true "问题唯一标识"
+// @Success 200 {file} file "zip 格式的问题包"
+// @Router /admin/problem-export [get]
+// ProblemExport 把问题（题面、限制、分类、子任务、代码模板、测试数据和当前使用的特判程序）导出为问题包，可以在其他实例导入
+func ProblemExport(c *gin.Context) {
+	identity := c.Query("identity")
+	if identity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题唯一标识不能为空",
+		})
+		return
+	}
+	pb, checker, err := loadExportProblem(identity)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "问题不存在",
+			})
+			return
+		}
+		log.Printf("ProblemExport Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取问题失败：" + err.Error(),
+		})
+		return
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="problem-`+pb.Identity+`.zip"`)
+	if err = writeProblemPackage(c.Request.Context(), pb, checker, c.Writer); err != nil {
+		// 响应已经开始发送，只能中断下载
+		log.Printf("ProblemExport Write Error: %v, identity: %s\n", err, identity)
+		c.Abort()
+	}
+}
+
+// ProblemImport
+// @Tags 管理员私有方法
+// @Summary 导入问题包
+// @Param authorization header string true "authorization"
+// @Param file formData file true "问题包：本系统导出的 zip、FPS 的 XML 文件（或打包的 zip）、Polygon 导出的完整包"
+// @Param format formData string false "问题包格式：auto（默认）、native、fps、polygon"
+// @Param dry_run formData bool false "为 true 时只检查问题包并返回将会做出的修改，不写入数据"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/problem-import [post]
+// ProblemImport 导入问题包，问题包中的问题标识已存在时更新该问题，否则新建问题
+func ProblemImport(c *gin.Context) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题包不能为空",
+		})
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数 dry_run 错误：" + err.Error(),
+		})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		log.Printf("ProblemImport Open Error: %v\n", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "读取上传文件失败：" + err.Error(),
+		})
+		return
+	}
+	defer f.Close()
+	reports, err := ImportProblems(c.Request.Context(), f, fh.Size, c.DefaultPostForm("format", problempkg.FormatAuto), dryRun)
+	if err != nil {
+		log.Printf("ProblemImport Error: %v, file: %s\n", err, fh.Filename)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "导入失败：" + err.Error(),
+			"data": map[string]interface{}{
+				"list": reports, // 出错前已经导入的问题
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"dry_run": dryRun,
+			"list":    reports,
+		},
+	})
+}
+
+// ExportProblem 把问题导出为问题包写入 w
+func ExportProblem(ctx context.Context, identity string, w io.Writer) error {
+	pb, checker, err := loadExportProblem(identity)
+	if err != nil {
+		return err
+	}
+	return writeProblemPackage(ctx, pb, checker, w)
+}
+
+// ImportProblems 导入问题包中的全部问题，dryRun 为 true 时只检查问题包并返回将会做出的修改
+// 所有问题都检查通过后才开始导入，每个问题在单独的事务中导入，出错时返回已经导入的问题
+func ImportProblems(ctx context.Context, r io.ReaderAt, size int64, format string, dryRun bool) ([]*ProblemImportReport, error) {
+	pkgs, err := problempkg.Read(r, size, format)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]*importPlan, 0, len(pkgs))
+	for i, p := range pkgs {
+		plan, err := planImport(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个问题（%s）：%w", i+1, p.Manifest.Title, err)
+		}
+		plans = append(plans, plan)
+	}
+	reports := make([]*ProblemImportReport, 0, len(plans))
+	for _, plan := range plans {
+		if !dryRun {
+			if err = applyImport(ctx, plan); err != nil {
+				return reports, fmt.Errorf("%s：%w", plan.in.Title, err)
+			}
+		}
+		reports = append(reports, plan.report)
+	}
+	return reports, nil
+}
+
+// loadExportProblem 读取导出需要的问题信息和当前使用的特判程序
+func loadExportProblem(identity string) (*models.ProblemBasic, *models.ProblemChecker, error) {
+	pb := new(models.ProblemBasic)
+	err := models.DB.Where("identity = ?", identity).
+		Preload("ProblemCategories").Preload("ProblemCategories.CategoryBasic").
+		Preload("TestCases", func(db *gorm.DB) *gorm.DB { return db.Order(models.TestCaseOrder) }).
+		Preload("Subtasks", func(db *gorm.DB) *gorm.DB { return db.Order("number ASC") }).
+		Preload("Templates").First(pb).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if pb.CheckerVersion == 0 {
+		return pb, nil, nil
+	}
+	checker := new(models.ProblemChecker)
+	err = models.DB.Where("problem_identity = ? AND version = ?", identity, pb.CheckerVersion).First(checker).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb, checker, nil
+}
+
+// writeProblemPackage 把问题写为问题包，测试数据按顺序保存为 data/编号.in 和 data/编号.out
+func writeProblemPackage(ctx context.Context, pb *models.ProblemBasic, checker *models.ProblemChecker, w io.Writer) error {
+	m := &problempkg.Manifest{
+		Identity:          pb.Identity,
+		Title:             pb.Title,
+		Content:           pb.Content,
+		MaxRuntime:        pb.MaxRuntime,
+		MaxMem:            pb.MaxMem,
+		Languages:         splitList(pb.Languages),
+		CompareMode:       pb.CompareMode,
+		FloatEpsilon:      pb.FloatEpsilon,
+		JudgeMode:         pb.JudgeMode,
+		ProblemType:       pb.ProblemType,
+		AllowImports:      splitList(pb.AllowImports),
+		DenyImports:       splitList(pb.DenyImports),
+		FunctionSignature: pb.FunctionSignature,
+	}
+	for _, pc := range pb.ProblemCategories {
+		if pc.CategoryBasic != nil {
+			m.Categories = append(m.Categories, pc.CategoryBasic.Name)
+		}
+	}
+	for _, st := range pb.Subtasks {
+		m.Subtasks = append(m.Subtasks, &define.Subtask{Number: st.Number, Score: st.Score, Depends: subtaskDepends(st)})
+	}
+	for _, t := range pb.Templates {
+		m.Templates = append(m.Templates, &define.ProblemTemplate{Language: t.Language, Starter: t.Starter, Harness: t.Harness})
+	}
+
+	pw := problempkg.NewWriter(w)
+	for i, tc := range pb.TestCases {
+		mt := &problempkg.TestCase{
+			Input:       fmt.Sprintf("data/%d.in", i+1),
+			Output:      fmt.Sprintf("data/%d.out", i+1),
+			Subtask:     tc.Subtask,
+			IsSample:    tc.IsSample,
+			Explanation: tc.Explanation,
+		}
+		if err := writeTestCaseFiles(ctx, pw, tc, mt); err != nil {
+			return err
+		}
+		m.TestCases = append(m.TestCases, mt)
+	}
+	if checker != nil {
+		lang, ok := judge.GetLanguage(checker.Language)
+		if !ok {
+			return errors.New("不支持的特判程序语言：" + checker.Language)
+		}
+		m.Checker = &problempkg.Checker{Language: lang.Name(), File: "checker/" + lang.SourceFile()}
+		if err := writePackageFile(pw, m.Checker.File, strings.NewReader(checker.Code)); err != nil {
+			return err
+		}
+	}
+	return pw.Close(m)
+}
+
+// writeTestCaseFiles 把测试用例的输入和预期输出写入问题包，保存在 storage 中的测试数据从 storage 读取
+func writeTestCaseFiles(ctx context.Context, pw *problempkg.Writer, tc *models.TestCase, mt *problempkg.TestCase) error {
+	if !tc.Stored() {
+		if err := writePackageFile(pw, mt.Input, strings.NewReader(tc.Input)); err != nil {
+			return err
+		}
+		return writePackageFile(pw, mt.Output, strings.NewReader(tc.Output))
+	}
+	for _, f := range []struct{ name, hash string }{{mt.Input, tc.InputHash}, {mt.Output, tc.OutputHash}} {
+		rc, err := storage.Default().Open(ctx, f.hash)
+		if err != nil {
+			return err
+		}
+		err = writePackageFile(pw, f.name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePackageFile 把 r 的内容写入问题包中的文件
+func writePackageFile(pw *problempkg.Writer, name string, r io.Reader) error {
+	f, err := pw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// splitList 把以逗号分隔的字符串拆分为列表，空字符串返回 nil
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// planImport 按创建问题的规则检查问题包，读取同一标识的已有问题，并生成导入报告；测试数据只计算校验和，不保存
+func planImport(ctx context.Context, p *problempkg.Package) (*importPlan, error) {
+	m := p.Manifest
+	in := &define.ProblemBasic{
+		Identity:          m.Identity,
+		Title:             m.Title,
+		Content:           m.Content,
+		MaxRuntime:        m.MaxRuntime,
+		MaxMem:            m.MaxMem,
+		Languages:         m.Languages,
+		CompareMode:       m.CompareMode,
+		FloatEpsilon:      m.FloatEpsilon,
+		JudgeMode:         m.JudgeMode,
+		ProblemType:       m.ProblemType,
+		AllowImports:      m.AllowImports,
+		DenyImports:       m.DenyImports,
+		Subtasks:          m.Subtasks,
+		FunctionSignature: m.FunctionSignature,
+		Templates:         m.Templates,
+	}
+	for _, tc := range m.TestCases {
+		in.TestCases = append(in.TestCases, &define.TestCase{Subtask: tc.Subtask, IsSample: tc.IsSample, Explanation: tc.Explanation})
+	}
+	if len(in.TestCases) == 0 {
+		return nil, errors.New("测试用例不能为空")
+	}
+	if err := validateProblem(in); err != nil {
+		return nil, err
+	}
+	plan := &importPlan{
+		pkg:          p,
+		in:           in,
+		languages:    strings.Join(in.Languages, ","),
+		allowImports: strings.Join(in.AllowImports, ","),
+		denyImports:  strings.Join(in.DenyImports, ","),
+	}
+	if m.Checker != nil {
+		lang, ok := judge.GetLanguage(m.Checker.Language)
+		if !ok || m.Checker.Language == "" {
+			return nil, errors.New("不支持的特判程序语言：" + m.Checker.Language)
+		}
+		code, err := p.ReadFile(m.Checker.File)
+		if err != nil {
+			return nil, err
+		}
+		if err = compileChecker(lang, string(code)); err != nil {
+			return nil, err
+		}
+		plan.checker = &models.ProblemChecker{Language: lang.Name(), Code: string(code)}
+	}
+	tcs, err := readPackageTestData(ctx, p, false)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.report = &ProblemImportReport{Identity: in.Identity, Title: in.Title, Source: p.Source, Warnings: p.Warnings, Changes: []string{}}
+	if in.Identity != "" {
+		existing := new(models.ProblemBasic)
+		err = models.DB.Where("identity = ?", in.Identity).
+			Preload("ProblemCategories").Preload("ProblemCategories.CategoryBasic").
+			Preload("TestCases", func(db *gorm.DB) *gorm.DB { return db.Order(models.TestCaseOrder) }).
+			Preload("Subtasks", func(db *gorm.DB) *gorm.DB { return db.Order("number ASC") }).
+			Preload("Templates").First(existing).Error
+		if err == nil {
+			plan.existing = existing
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if err = describeImport(plan, tcs); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// describeImport 比较问题包与已有的问题，生成导入报告中的修改列表，同时确定特判程序是否变化
+func describeImport(plan *importPlan, tcs []*models.TestCase) error {
+	in, r := plan.in, plan.report
+	add := func(format string, args ...interface{}) { r.Changes = append(r.Changes, fmt.Sprintf(format, args...)) }
+
+	missing, err := missingCategories(models.DB, plan.pkg.Manifest.Categories)
+	if err != nil {
+		return err
+	}
+	for _, name := range missing {
+		add("新建分类：%s", name)
+	}
+
+	old := plan.existing
+	if old == nil {
+		r.Action = "create"
+		plan.checkerChanged = plan.checker != nil
+		add("新建问题：%s", in.Title)
+		add("测试用例：%d 个，其中样例 %d 个", len(tcs), countSamples(tcs))
+		if plan.checker != nil {
+			add("特判程序：%s", plan.checker.Language)
+		}
+		return nil
+	}
+	r.Action = "update"
+	diff := func(name string, from, to interface{}) {
+		if !reflect.DeepEqual(from, to) {
+			add("%s：%v → %v", name, from, to)
+		}
+	}
+	diff("标题", old.Title, in.Title)
+	if old.Content != in.Content {
+		add("题面有修改")
+	}
+	diff("时间限制（毫秒）", old.MaxRuntime, in.MaxRuntime)
+	diff("内存限制（KB）", old.MaxMem, in.MaxMem)
+	diff("允许的语言", old.Languages, plan.languages)
+	diff("输出比较方式", old.CompareMode, in.CompareMode)
+	diff("浮点数误差", old.FloatEpsilon, in.FloatEpsilon)
+	diff("判题模式", old.JudgeMode, in.JudgeMode)
+	diff("问题类型", old.ProblemType, in.ProblemType)
+	diff("函数签名", old.FunctionSignature, in.FunctionSignature)
+	diff("允许导入的包", old.AllowImports, plan.allowImports)
+	diff("禁止导入的包", old.DenyImports, plan.denyImports)
+
+	oldCategories := make([]string, 0, len(old.ProblemCategories))
+	for _, pc := range old.ProblemCategories {
+		if pc.CategoryBasic != nil {
+			oldCategories = append(oldCategories, pc.CategoryBasic.Name)
+		}
+	}
+	diff("分类", oldCategories, append([]string{}, plan.pkg.Manifest.Categories...))
+
+	oldSubtasks := make([]string, 0, len(old.Subtasks))
+	for _, st := range old.Subtasks {
+		oldSubtasks = append(oldSubtasks, fmt.Sprintf("%d:%d%v", st.Number, st.Score, subtaskDepends(st)))
+	}
+	newSubtasks := make([]string, 0, len(in.Subtasks))
+	for _, st := range in.Subtasks {
+		newSubtasks = append(newSubtasks, fmt.Sprintf("%d:%d%v", st.Number, st.Score, st.Depends))
+	}
+	if !reflect.DeepEqual(oldSubtasks, newSubtasks) {
+		add("子任务：%d 个 → %d 个", len(old.Subtasks), len(in.Subtasks))
+	}
+	oldTemplates := make(map[string]define.ProblemTemplate, len(old.Templates))
+	for _, t := range old.Templates {
+		oldTemplates[t.Language] = define.ProblemTemplate{Language: t.Language, Starter: t.Starter, Harness: t.Harness}
+	}
+	newTemplates := make(map[string]define.ProblemTemplate, len(in.Templates))
+	for _, t := range in.Templates {
+		newTemplates[t.Language] = *t
+	}
+	if !reflect.DeepEqual(oldTemplates, newTemplates) {
+		add("代码模板：%d 个 → %d 个", len(old.Templates), len(in.Templates))
+	}
+
+	changed := 0
+	for i, tc := range tcs {
+		if i >= len(old.TestCases) {
+			break
+		}
+		o := old.TestCases[i]
+		inHash, outHash := o.InputHash, o.OutputHash
+		if !o.Stored() {
+			inHash, outHash = storage.Checksum([]byte(o.Input)), storage.Checksum([]byte(o.Output))
+		}
+		if inHash != tc.InputHash || outHash != tc.OutputHash || o.Subtask != tc.Subtask ||
+			o.IsSample != tc.IsSample || o.Explanation != tc.Explanation {
+			changed++
+		}
+	}
+	if changed > 0 || len(old.TestCases) != len(tcs) {
+		add("测试用例：%d 个 → %d 个，其中 %d 个有修改", len(old.TestCases), len(tcs), changed)
+	}
+
+	var current *models.ProblemChecker
+	if old.CheckerVersion > 0 {
+		current = new(models.ProblemChecker)
+		err := models.DB.Where("problem_identity = ? AND version = ?", old.Identity, old.CheckerVersion).First(current).Error
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case current == nil && plan.checker != nil:
+		plan.checkerChanged = true
+		add("特判程序：新增（%s）", plan.checker.Language)
+	case current != nil && plan.checker == nil:
+		add("特判程序：不再使用版本 %d", current.Version)
+	case current != nil && (current.Language != plan.checker.Language || current.Code != plan.checker.Code):
+		plan.checkerChanged = true
+		add("特判程序：版本 %d → 新版本（%s）", current.Version, plan.checker.Language)
+	}
+	if len(r.Changes) == 0 {
+		add("没有修改")
+	}
+	return nil
+}
+
+// countSamples 返回样例的个数
+func countSamples(tcs []*models.TestCase) int {
+	n := 0
+	for _, tc := range tcs {
+		if tc.IsSample {
+			n++
+		}
+	}
+	return n
+}
+
+// readPackageTestData 读取问题包中的测试数据，save 为 true 时保存到 storage，否则只计算校验和
+// 测试数据的总大小不能超过 TestDataMaxSize，返回的测试用例没有设置所属问题和唯一标识
+func readPackageTestData(ctx context.Context, p *problempkg.Package, save bool) ([]*models.TestCase, error) {
+	remain := int64(utils.TestDataMaxSize) << 20
+	read := func(name string) (string, int64, error) {
+		rc, err := p.Open(name)
+		if err != nil {
+			return "", 0, err
+		}
+		defer rc.Close()
+		r := io.LimitReader(rc, remain+1)
+		var hash string
+		var size int64
+		if save {
+			hash, size, err = storage.Default().Save(ctx, r)
+		} else {
+			h := sha256.New()
+			size, err = io.Copy(h, r)
+			hash = hex.EncodeToString(h.Sum(nil))
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("读取文件 %s 失败：%v", name, err)
+		}
+		if remain -= size; remain < 0 {
+			return "", 0, errors.New("测试数据超过大小限制")
+		}
+		return hash, size, nil
+	}
+	tcs := make([]*models.TestCase, 0, len(p.Manifest.TestCases))
+	for i, mt := range p.Manifest.TestCases {
+		tc := &models.TestCase{Subtask: mt.Subtask, IsSample: mt.IsSample, Explanation: mt.Explanation, Sort: i + 1}
+		var err error
+		if tc.InputHash, tc.InputSize, err = read(mt.Input); err != nil {
+			return nil, err
+		}
+		if tc.OutputHash, tc.OutputSize, err = read(mt.Output); err != nil {
+			return nil, err
+		}
+		tcs = append(tcs, tc)
+	}
+	return tcs, nil
+}
+
+// missingCategories 返回按名称找不到的分类
+func missingCategories(tx *gorm.DB, names []string) ([]string, error) {
+	ids, err := categoryIDs(tx, names)
+	if err != nil {
+		return nil, err
+	}
+	missing := make([]string, 0)
+	for _, name := range names {
+		if _, ok := ids[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// categoryIDs 按名称查找分类，同名的分类取最早创建的
+func categoryIDs(tx *gorm.DB, names []string) (map[string]uint, error) {
+	ids := make(map[string]uint, len(names))
+	if len(names) == 0 {
+		return ids, nil
+	}
+	list := make([]*models.CategoryBasic, 0)
+	if err := tx.Where("name IN ?", names).Order("id ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, cb := range list {
+		if _, ok := ids[cb.Name]; !ok {
+			ids[cb.Name] = cb.ID
+		}
+	}
+	return ids, nil
+}
+
+// applyImport 把测试数据保存到 storage，并在一个事务中新建或更新问题及其分类、测试用例、子任务、代码模板和特判程序
+func applyImport(ctx context.Context, plan *importPlan) error {
+	tcs, err := readPackageTestData(ctx, plan.pkg, true)
+	if err != nil {
+		return err
+	}
+	in := plan.in
+	identity := in.Identity
+	if identity == "" {
+		identity = utils.GetUUID()
+	}
+	now := models.MyTime(time.Now())
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		var problemID uint
+		if plan.existing == nil {
+			pb := &models.ProblemBasic{
+				Identity:          identity,
+				Title:             in.Title,
+				Content:           in.Content,
+				MaxRuntime:        in.MaxRuntime,
+				MaxMem:            in.MaxMem,
+				Languages:         plan.languages,
+				CompareMode:       in.CompareMode,
+				FloatEpsilon:      in.FloatEpsilon,
+				JudgeMode:         in.JudgeMode,
+				ProblemType:       in.ProblemType,
+				FunctionSignature: in.FunctionSignature,
+				AllowImports:      plan.allowImports,
+				DenyImports:       plan.denyImports,
+				TestDataChecksum:  testDataChecksum(tcs),
+				CreatedAt:         now,
+				UpdatedAt:         now,
+			}
+			if err := tx.Create(pb).Error; err != nil {
+				return err
+			}
+			problemID = pb.ID
+		} else {
+			problemID = plan.existing.ID
+			err := tx.Model(new(models.ProblemBasic)).Where("identity = ?", identity).Updates(map[string]interface{}{
+				"title":              in.Title,
+				"content":            in.Content,
+				"max_runtime":        in.MaxRuntime,
+				"max_mem":            in.MaxMem,
+				"languages":          plan.languages,
+				"compare_mode":       in.CompareMode,
+				"float_epsilon":      in.FloatEpsilon,
+				"judge_mode":         in.JudgeMode,
+				"problem_type":       in.ProblemType,
+				"function_signature": in.FunctionSignature,
+				"allow_imports":      plan.allowImports,
+				"deny_imports":       plan.denyImports,
+				"test_data_checksum": testDataChecksum(tcs),
+				"test_data_version":  gorm.Expr("test_data_version + ?", 1), // 使判题结果缓存失效
+				"updated_at":         now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			for _, model := range []interface{}{new(models.TestCase), new(models.ProblemSubtask), new(models.ProblemTemplate)} {
+				if err = tx.Where("problem_identity = ?", identity).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if err = tx.Where("problem_id = ?", problemID).Delete(new(models.ProblemCategory)).Error; err != nil {
+				return err
+			}
+		}
+
+		// 分类按名称匹配，不存在的分类作为顶级分类新建
+		names := plan.pkg.Manifest.Categories
+		ids, err := categoryIDs(tx, names)
+		if err != nil {
+			return err
+		}
+		pcs := make([]*models.ProblemCategory, 0, len(names))
+		for _, name := range names {
+			id, ok := ids[name]
+			if !ok {
+				cb := &models.CategoryBasic{Identity: utils.GetUUID(), Name: name, CreatedAt: now, UpdatedAt: now}
+				if err = tx.Create(cb).Error; err != nil {
+					return err
+				}
+				id, ids[name] = cb.ID, cb.ID
+			}
+			pcs = append(pcs, &models.ProblemCategory{ProblemId: problemID, CategoryId: id, CreatedAt: now, UpdatedAt: now})
+		}
+		if len(pcs) > 0 {
+			if err = tx.Create(&pcs).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, tc := range tcs {
+			tc.Identity, tc.ProblemIdentity = utils.GetUUID(), identity
+			tc.CreatedAt, tc.UpdatedAt = now, now
+		}
+		if err = tx.Create(&tcs).Error; err != nil {
+			return err
+		}
+		if subtasks := newProblemSubtasks(identity, in.Subtasks); len(subtasks) > 0 {
+			if err = tx.Create(subtasks).Error; err != nil {
+				return err
+			}
+		}
+		if templates := newProblemTemplates(identity, in.Templates); len(templates) > 0 {
+			if err = tx.Create(templates).Error; err != nil {
+				return err
+			}
+		}
+
+		// 特判程序与当前使用的版本不同时新增一个版本，问题包中没有特判程序时不再使用特判程序
+		switch {
+		case plan.checkerChanged:
+			plan.checker.ProblemIdentity = identity
+			plan.checker.CreatedAt, plan.checker.UpdatedAt = now, now
+			err = createCheckerVersion(tx, plan.checker)
+		case plan.checker == nil && plan.existing != nil && plan.existing.CheckerVersion != 0:
+			err = tx.Model(new(models.ProblemBasic)).Where("identity = ?", identity).Update("checker_version", 0).Error
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = recordRevision(tx, identity, "导入问题包"); err != nil {
+			return err
+		}
+		plan.report.Identity = identity
+		return nil
+	})
+}
@@ -0,0 +1,598 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/storage"
+	"gin_gorm_oj/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// revisionTestCase 是版本快照中的一个测试用例，输入输出保存在 storage 中
+type revisionTestCase struct {
+	InputHash   string `json:"input_hash"`
+	InputSize   int64  `json:"input_size"`
+	OutputHash  string `json:"output_hash"`
+	OutputSize  int64  `json:"output_size"`
+	Subtask     int    `json:"subtask,omitempty"`
+	IsSample    bool   `json:"is_sample,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// revisionFieldDiff 是两个版本之间一个字段的差异
+type revisionFieldDiff struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// revisionCaseDiff 是两个版本之间同一位置的测试用例的差异
+type revisionCaseDiff struct {
+	// Index 是测试用例的序号，从 1 开始
+	Index int `json:"index"`
+	// Change 是 added、removed 或 modified
+	Change string `json:"change"`
+	// Fields 是 modified 时有变化的属性：input、output、subtask、is_sample、explanation
+	Fields []string `json:"fields,omitempty"`
+}
+
+// GetProblemRevisionList
+// @Tags 管理员私有方法
+// @Summary 问题版本列表
+// @Param authorization header string true "authorization"
+// @Param identity query string true "问题唯一标识"
+// @Param page query int false "page"
+// @Param size query int false "size"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/problem-revision-list [get]
+// GetProblemRevisionList 获取问题的版本列表，按版本号降序排列，不返回题面和测试数据
+func GetProblemRevisionList(c *gin.Context) {
+	size, _ := strconv.Atoi(c.DefaultQuery("size", define.DefaultSize))
+	page, err := strconv.Atoi(c.DefaultQuery("page", define.DefaultPage))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数错误：页码非法",
+		})
+		return
+	}
+	identity := c.Query("identity")
+	if identity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题唯一标识不能为空",
+		})
+		return
+	}
+	pb := new(models.ProblemBasic)
+	if err = models.DB.Select("identity", "revision").Where("identity = ?", identity).First(pb).Error; err != nil {
+		log.Printf("GetProblemRevisionList Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取问题信息失败：" + err.Error(),
+		})
+		return
+	}
+	var count int64
+	list := make([]*models.ProblemRevision, 0)
+	err = models.DB.Model(new(models.ProblemRevision)).Omit("content", "subtasks", "templates", "test_cases").
+		Where("problem_identity = ?", identity).Count(&count).
+		Order("revision DESC").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	if err != nil {
+		log.Printf("GetProblemRevisionList Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取版本列表失败：" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"revision": pb.Revision, // 当前版本
+			"list":     list,
+			"count":    count,
+		},
+	})
+}
+
+// GetProblemRevisionDiff
+// @Tags 管理员私有方法
+// @Summary 比较问题的两个版本
+// @Param authorization header string true "authorization"
+// @Param identity query string true "问题唯一标识"
+// @Param from query int true "旧版本号"
+// @Param to query int false "新版本号，为空时使用当前版本"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/problem-revision-diff [get]
+// GetProblemRevisionDiff 比较问题的两个版本：返回有变化的字段、题面的逐行差异和各测试用例的变化
+func GetProblemRevisionDiff(c *gin.Context) {
+	identity := c.Query("identity")
+	from, err := strconv.Atoi(c.Query("from"))
+	if identity == "" || err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数不正确，identity 和 from 不能为空",
+		})
+		return
+	}
+	to := 0
+	if v := c.Query("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "参数 to 错误：" + err.Error(),
+			})
+			return
+		}
+	} else {
+		pb := new(models.ProblemBasic)
+		if err = models.DB.Select("identity", "revision").Where("identity = ?", identity).First(pb).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "问题不存在",
+			})
+			return
+		}
+		to = pb.Revision
+	}
+	a, err := loadRevision(identity, from)
+	var b *models.ProblemRevision
+	if err == nil {
+		b, err = loadRevision(identity, to)
+	}
+	if err != nil {
+		revisionError(c, "GetProblemRevisionDiff", err)
+		return
+	}
+	fields, cases, err := diffRevisions(a, b)
+	if err != nil {
+		log.Printf("GetProblemRevisionDiff Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "比较版本失败：" + err.Error(),
+		})
+		return
+	}
+	var content []string
+	if a.Content != b.Content {
+		content = utils.DiffLines(a.Content, b.Content)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"from":       from,
+			"to":         to,
+			"fields":     fields,
+			"content":    content, // 题面没有变化时为空
+			"test_cases": cases,
+		},
+	})
+}
+
+// ProblemRevisionRestore
+// @Tags 管理员私有方法
+// @Summary 恢复问题的版本
+// @Param authorization header string true "authorization"
+// @Param identity formData string true "问题唯一标识"
+// @Param revision formData int true "要恢复的版本号"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/problem-revision-restore [put]
+// ProblemRevisionRestore 把问题的题面、限制、分类、子任务、代码模板、测试数据和特判程序版本恢复为指定版本的内容，
+// 恢复本身也会产生一个新版本，已删除的分类不再关联
+func ProblemRevisionRestore(c *gin.Context) {
+	identity := c.PostForm("identity")
+	revision, err := strconv.Atoi(c.PostForm("revision"))
+	if identity == "" || err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数不正确，identity 和 revision 不能为空",
+		})
+		return
+	}
+	rev, err := loadRevision(identity, revision)
+	if err != nil {
+		revisionError(c, "ProblemRevisionRestore", err)
+		return
+	}
+	snapshot, err := decodeRevision(rev)
+	if err != nil {
+		revisionError(c, "ProblemRevisionRestore", err)
+		return
+	}
+	// 测试数据只能从 storage 恢复，先确认文件都还在
+	for i, tc := range snapshot.cases {
+		for _, hash := range []string{tc.InputHash, tc.OutputHash} {
+			if _, err = storage.Default().Path(c.Request.Context(), hash); err != nil {
+				log.Printf("ProblemRevisionRestore Error: %v, identity: %s\n", err, identity)
+				c.JSON(http.StatusOK, gin.H{
+					"code": -1,
+					"msg":  fmt.Sprintf("第 %d 个测试用例的测试数据已不存在，无法恢复", i+1),
+				})
+				return
+			}
+		}
+	}
+
+	var restored *models.ProblemRevision
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		pb := new(models.ProblemBasic)
+		if err := tx.Select("id", "identity").Where("identity = ?", identity).First(pb).Error; err != nil {
+			return err
+		}
+		if rev.CheckerVersion > 0 {
+			var count int64
+			err := tx.Model(new(models.ProblemChecker)).
+				Where("problem_identity = ? AND version = ?", identity, rev.CheckerVersion).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				return invalidRevision{fmt.Errorf("特判程序版本 %d 已被删除，无法恢复", rev.CheckerVersion)}
+			}
+		}
+		now := models.MyTime(time.Now())
+		err := tx.Model(new(models.ProblemBasic)).Where("identity = ?", identity).Updates(map[string]interface{}{
+			"title":              rev.Title,
+			"content":            rev.Content,
+			"max_runtime":        rev.MaxRuntime,
+			"max_mem":            rev.MaxMem,
+			"languages":          rev.Languages,
+			"compare_mode":       rev.CompareMode,
+			"float_epsilon":      rev.FloatEpsilon,
+			"judge_mode":         rev.JudgeMode,
+			"problem_type":       rev.ProblemType,
+			"allow_imports":      rev.AllowImports,
+			"deny_imports":       rev.DenyImports,
+			"function_signature": rev.FunctionSignature,
+			"checker_version":    rev.CheckerVersion,
+			"test_data_checksum": rev.TestDataHash,
+			"test_data_version":  gorm.Expr("test_data_version + ?", 1), // 使判题结果缓存失效
+			"updated_at":         now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		for _, model := range []interface{}{new(models.TestCase), new(models.ProblemSubtask), new(models.ProblemTemplate)} {
+			if err = tx.Where("problem_identity = ?", identity).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err = tx.Where("problem_id = ?", pb.ID).Delete(new(models.ProblemCategory)).Error; err != nil {
+			return err
+		}
+
+		categories := make([]*models.CategoryBasic, 0)
+		if ids := splitList(rev.Categories); len(ids) > 0 {
+			if err = tx.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+				return err
+			}
+		}
+		pcs := make([]*models.ProblemCategory, 0, len(categories))
+		for _, cb := range categories {
+			pcs = append(pcs, &models.ProblemCategory{ProblemId: pb.ID, CategoryId: cb.ID, CreatedAt: now, UpdatedAt: now})
+		}
+		if len(pcs) > 0 {
+			if err = tx.Create(&pcs).Error; err != nil {
+				return err
+			}
+		}
+		tcs := make([]*models.TestCase, 0, len(snapshot.cases))
+		for i, tc := range snapshot.cases {
+			tcs = append(tcs, &models.TestCase{
+				Identity:        utils.GetUUID(),
+				ProblemIdentity: identity,
+				InputHash:       tc.InputHash,
+				InputSize:       tc.InputSize,
+				OutputHash:      tc.OutputHash,
+				OutputSize:      tc.OutputSize,
+				Subtask:         tc.Subtask,
+				IsSample:        tc.IsSample,
+				Explanation:     tc.Explanation,
+				Sort:            i + 1,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+			})
+		}
+		if len(tcs) > 0 {
+			if err = tx.Create(&tcs).Error; err != nil {
+				return err
+			}
+		}
+		if subtasks := newProblemSubtasks(identity, snapshot.subtasks); len(subtasks) > 0 {
+			if err = tx.Create(subtasks).Error; err != nil {
+				return err
+			}
+		}
+		if templates := newProblemTemplates(identity, snapshot.templates); len(templates) > 0 {
+			if err = tx.Create(templates).Error; err != nil {
+				return err
+			}
+		}
+		restored, err = recordRevision(tx, identity, fmt.Sprintf("恢复到版本 %d", rev.Revision))
+		return err
+	})
+	if err != nil {
+		revisionError(c, "ProblemRevisionRestore", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"revision": restored.Revision, // 恢复后产生的新版本
+		},
+		"msg": "恢复成功",
+	})
+}
+
+// invalidRevision 表示版本不存在或无法恢复，错误信息直接返回给管理员
+type invalidRevision struct {
+	error
+}
+
+// revisionError 返回版本操作的错误，版本不存在等原因直接返回，其余错误记录日志
+func revisionError(c *gin.Context, name string, err error) {
+	var invalid invalidRevision
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  invalid.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题或版本不存在",
+		})
+	default:
+		log.Printf("%s Error: %v\n", name, err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "操作失败：" + err.Error(),
+		})
+	}
+}
+
+// loadRevision 读取问题的指定版本
+func loadRevision(problemIdentity string, revision int) (*models.ProblemRevision, error) {
+	rev := new(models.ProblemRevision)
+	err := models.DB.Where("problem_identity = ? AND revision = ?", problemIdentity, revision).First(rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, invalidRevision{fmt.Errorf("版本 %d 不存在", revision)}
+	}
+	return rev, err
+}
+
+// revisionSnapshot 是版本快照中以 JSON 保存的部分
+type revisionSnapshot struct {
+	cases     []*revisionTestCase
+	subtasks  []*define.Subtask
+	templates []*define.ProblemTemplate
+}
+
+// decodeRevision 解析版本快照中以 JSON 保存的测试用例、子任务和代码模板
+func decodeRevision(rev *models.ProblemRevision) (*revisionSnapshot, error) {
+	s := new(revisionSnapshot)
+	for _, part := range []struct {
+		data string
+		v    interface{}
+	}{{rev.TestCases, &s.cases}, {rev.Subtasks, &s.subtasks}, {rev.Templates, &s.templates}} {
+		if part.data == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(part.data), part.v); err != nil {
+			return nil, fmt.Errorf("版本 %d 的快照已损坏：%v", rev.Revision, err)
+		}
+	}
+	return s, nil
+}
+
+// diffRevisions 比较两个版本，返回有变化的字段和各测试用例的变化，题面的差异由调用方单独计算
+func diffRevisions(a, b *models.ProblemRevision) ([]*revisionFieldDiff, []*revisionCaseDiff, error) {
+	sa, err := decodeRevision(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	sb, err := decodeRevision(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	names, err := revisionCategoryNames(a, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := make([]*revisionFieldDiff, 0)
+	diff := func(field string, from, to interface{}) {
+		if !reflect.DeepEqual(from, to) {
+			fields = append(fields, &revisionFieldDiff{Field: field, From: from, To: to})
+		}
+	}
+	diff("title", a.Title, b.Title)
+	diff("max_runtime", a.MaxRuntime, b.MaxRuntime)
+	diff("max_mem", a.MaxMem, b.MaxMem)
+	diff("languages", a.Languages, b.Languages)
+	diff("compare_mode", a.CompareMode, b.CompareMode)
+	diff("float_epsilon", a.FloatEpsilon, b.FloatEpsilon)
+	diff("judge_mode", a.JudgeMode, b.JudgeMode)
+	diff("problem_type", a.ProblemType, b.ProblemType)
+	diff("allow_imports", a.AllowImports, b.AllowImports)
+	diff("deny_imports", a.DenyImports, b.DenyImports)
+	diff("function_signature", a.FunctionSignature, b.FunctionSignature)
+	diff("categories", names(a.Categories), names(b.Categories))
+	diff("subtasks", sa.subtasks, sb.subtasks)
+	diff("templates", sa.templates, sb.templates)
+	diff("checker_version", a.CheckerVersion, b.CheckerVersion)
+	diff("test_data_hash", a.TestDataHash, b.TestDataHash)
+
+	cases := make([]*revisionCaseDiff, 0)
+	for i := 0; i < len(sa.cases) || i < len(sb.cases); i++ {
+		switch {
+		case i >= len(sa.cases):
+			cases = append(cases, &revisionCaseDiff{Index: i + 1, Change: "added"})
+		case i >= len(sb.cases):
+			cases = append(cases, &revisionCaseDiff{Index: i + 1, Change: "removed"})
+		default:
+			x, y := sa.cases[i], sb.cases[i]
+			var changed []string
+			for _, f := range []struct {
+				name  string
+				equal bool
+			}{
+				{"input", x.InputHash == y.InputHash},
+				{"output", x.OutputHash == y.OutputHash},
+				{"subtask", x.Subtask == y.Subtask},
+				{"is_sample", x.IsSample == y.IsSample},
+				{"explanation", x.Explanation == y.Explanation},
+			} {
+				if !f.equal {
+					changed = append(changed, f.name)
+				}
+			}
+			if len(changed) > 0 {
+				cases = append(cases, &revisionCaseDiff{Index: i + 1, Change: "modified", Fields: changed})
+			}
+		}
+	}
+	return fields, cases, nil
+}
+
+// revisionCategoryNames 读取两个版本涉及的分类名称，返回把分类 ID 列表转换为名称列表的函数，已删除的分类同样返回名称
+func revisionCategoryNames(a, b *models.ProblemRevision) (func(string) []string, error) {
+	ids := append(splitList(a.Categories), splitList(b.Categories)...)
+	names := make(map[string]string, len(ids))
+	if len(ids) > 0 {
+		list := make([]*models.CategoryBasic, 0)
+		if err := models.DB.Unscoped().Where("id IN ?", ids).Find(&list).Error; err != nil {
+			return nil, err
+		}
+		for _, cb := range list {
+			names[strconv.Itoa(int(cb.ID))] = cb.Name
+		}
+	}
+	return func(categories string) []string {
+		res := make([]string, 0)
+		for _, id := range splitList(categories) {
+			if name, ok := names[id]; ok {
+				res = append(res, name)
+			} else {
+				res = append(res, "#"+id)
+			}
+		}
+		return res
+	}, nil
+}
+
+// recordRevision 在事务中为问题的当前状态保存一个版本快照，并把问题的当前版本号更新为快照的版本号，版本号为已有的最大版本号加一
+// 保存在数据库中的测试数据在保存快照时写入 storage，快照中只保存校验和，数据库中的测试用例保持不变
+func recordRevision(tx *gorm.DB, problemIdentity, comment string) (*models.ProblemRevision, error) {
+	ctx := tx.Statement.Context
+	// 锁定问题记录，避免并发修改得到相同的版本号
+	pb := new(models.ProblemBasic)
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("identity = ?", problemIdentity).First(pb).Error; err != nil {
+		return nil, err
+	}
+	pcs := make([]*models.ProblemCategory, 0)
+	if err := tx.Where("problem_id = ?", pb.ID).Order("id ASC").Find(&pcs).Error; err != nil {
+		return nil, err
+	}
+	tcs := make([]*models.TestCase, 0)
+	if err := tx.Where("problem_identity = ?", problemIdentity).Order(models.TestCaseOrder).Find(&tcs).Error; err != nil {
+		return nil, err
+	}
+	subtasks := make([]*models.ProblemSubtask, 0)
+	if err := tx.Where("problem_identity = ?", problemIdentity).Order("number ASC").Find(&subtasks).Error; err != nil {
+		return nil, err
+	}
+	templates := make([]*models.ProblemTemplate, 0)
+	if err := tx.Where("problem_identity = ?", problemIdentity).Order("id ASC").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	categories := make([]string, 0, len(pcs))
+	for _, pc := range pcs {
+		categories = append(categories, strconv.Itoa(int(pc.CategoryId)))
+	}
+	cases := make([]*revisionTestCase, 0, len(tcs))
+	for _, tc := range tcs {
+		if !tc.Stored() {
+			var err error
+			if tc.InputHash, tc.InputSize, err = storage.Default().Save(ctx, strings.NewReader(tc.Input)); err != nil {
+				return nil, err
+			}
+			if tc.OutputHash, tc.OutputSize, err = storage.Default().Save(ctx, strings.NewReader(tc.Output)); err != nil {
+				return nil, err
+			}
+		}
+		cases = append(cases, &revisionTestCase{
+			InputHash:   tc.InputHash,
+			InputSize:   tc.InputSize,
+			OutputHash:  tc.OutputHash,
+			OutputSize:  tc.OutputSize,
+			Subtask:     tc.Subtask,
+			IsSample:    tc.IsSample,
+			Explanation: tc.Explanation,
+		})
+	}
+	subtaskList := make([]*define.Subtask, 0, len(subtasks))
+	for _, st := range subtasks {
+		subtaskList = append(subtaskList, &define.Subtask{Number: st.Number, Score: st.Score, Depends: subtaskDepends(st)})
+	}
+	templateList := make([]*define.ProblemTemplate, 0, len(templates))
+	for _, t := range templates {
+		templateList = append(templateList, &define.ProblemTemplate{Language: t.Language, Starter: t.Starter, Harness: t.Harness})
+	}
+	casesJSON, _ := json.Marshal(cases)
+	subtasksJSON, _ := json.Marshal(subtaskList)
+	templatesJSON, _ := json.Marshal(templateList)
+
+	rev := &models.ProblemRevision{
+		ProblemIdentity:   problemIdentity,
+		Comment:           comment,
+		Title:             pb.Title,
+		Content:           pb.Content,
+		MaxRuntime:        pb.MaxRuntime,
+		MaxMem:            pb.MaxMem,
+		Languages:         pb.Languages,
+		CompareMode:       pb.CompareMode,
+		FloatEpsilon:      pb.FloatEpsilon,
+		JudgeMode:         pb.JudgeMode,
+		ProblemType:       pb.ProblemType,
+		AllowImports:      pb.AllowImports,
+		DenyImports:       pb.DenyImports,
+		FunctionSignature: pb.FunctionSignature,
+		Categories:        strings.Join(categories, ","),
+		Subtasks:          string(subtasksJSON),
+		Templates:         string(templatesJSON),
+		TestCases:         string(casesJSON),
+		TestCaseCount:     len(cases),
+		TestDataHash:      testDataChecksum(tcs),
+		CheckerVersion:    pb.CheckerVersion,
+		CreatedAt:         models.MyTime(time.Now()),
+		UpdatedAt:         models.MyTime(time.Now()),
+	}
+	var maxRevision int
+	err := tx.Model(new(models.ProblemRevision)).Unscoped().Where("problem_identity = ?", problemIdentity).
+		Select("COALESCE(MAX(revision), 0)").Scan(&maxRevision).Error
+	if err != nil {
+		return nil, err
+	}
+	rev.Revision = maxRevision + 1
+	if err = tx.Create(rev).Error; err != nil {
+		return nil, err
+	}
+	if err = tx.Model(new(models.ProblemBasic)).Where("identity = ?", problemIdentity).Update("revision", rev.Revision).Error; err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/middlewares"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 重判的范围
+const (
+	rejudgeScopeSubmit  = "submit"  // 单条提交
+	rejudgeScopeProblem = "problem" // 问题的全部提交
+	rejudgeScopeContest = "contest" // 竞赛期间竞赛题目的全部提交
+)
+
+// errRejudgeSkip 表示提交不需要或无法重判
+var errRejudgeSkip = errors.New("rejudge skipped")
+
+// RejudgeSubmit
+// @Tags 管理员私有方法
+// @Summary 重判单条提交
+// @Param authorization header string true "authorization"
+// @Param identity formData string true "提交唯一标识"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/rejudge-submit [post]
+// RejudgeSubmit 重判单条提交
+func RejudgeSubmit(c *gin.Context) {
+	startRejudge(c, rejudgeScopeSubmit, c.PostForm("identity"))
+}
+
+// RejudgeProblem
+// @Tags 管理员私有方法
+// @Summary 重判问题的全部提交
+// @Param authorization header string true "authorization"
+// @Param identity formData string true "问题唯一标识"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/rejudge-problem [post]
+// RejudgeProblem 重判问题的全部提交，通常在修正测试用例后使用
+func RejudgeProblem(c *gin.Context) {
+	startRejudge(c, rejudgeScopeProblem, c.PostForm("identity"))
+}
+
+// RejudgeContest
+// @Tags 管理员私有方法
+// @Summary 重判竞赛的全部提交
+// @Param authorization header string true "authorization"
+// @Param identity formData string true "竞赛唯一标识"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/rejudge-contest [post]
+// RejudgeContest 重判竞赛开始到结束期间竞赛题目的全部提交
+func RejudgeContest(c *gin.Context) {
+	startRejudge(c, rejudgeScopeContest, c.PostForm("identity"))
+}
+
+// GetRejudgeDetail
+// @Tags 管理员私有方法
+// @Summary 重判进度
+// @Param authorization header string true "authorization"
+// @Param identity query string true "重判任务唯一标识"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/rejudge-detail [get]
+// GetRejudgeDetail 获取重判任务的进度
+func GetRejudgeDetail(c *gin.Context) {
+	identity := c.Query("identity")
+	if identity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "重判任务唯一标识不能为空",
+		})
+		return
+	}
+	task := new(models.RejudgeTask)
+	err := models.DB.Where("identity = ?", identity).First(task).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "重判任务不存在",
+			})
+			return
+		}
+		log.Printf("GetRejudgeDetail Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取重判任务失败：" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": task,
+	})
+}
+
+// startRejudge 创建重判任务并标记范围内需要重判的提交，之后在后台逐条重置并放入判题队列
+func startRejudge(c *gin.Context, scope, target string) {
+	if target == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "唯一标识不能为空",
+		})
+		return
+	}
+	task := &models.RejudgeTask{
+		Identity:       utils.GetUUID(),
+		Scope:          scope,
+		TargetIdentity: target,
+		CreatedAt:      models.MyTime(time.Now()),
+		UpdatedAt:      models.MyTime(time.Now()),
+	}
+	if u, ok := c.Get("user_claims"); ok {
+		if userClaim, ok := u.(*middlewares.UserClaims); ok {
+			task.UserIdentity = userClaim.Identity
+		}
+	}
+	// 创建任务的同时标记范围内需要重判的提交，进程重启后按标记继续处理
+	// 待判的提交本来就在判题队列中，已被其他重判任务标记的提交正在重判，都不需要标记
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		query, err := rejudgeQuery(tx, scope, target)
+		if err != nil {
+			return err
+		}
+		res := query.Where("status <> ? AND rejudge_identity = ?", define.SubmitStatusPending, "").
+			UpdateColumn("rejudge_identity", task.Identity)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errRejudgeSkip
+		}
+		task.Total = res.RowsAffected
+		return tx.Model(task).UpdateColumn("total", task.Total).Error
+	})
+	if err != nil {
+		msg := "创建重判任务失败：" + err.Error()
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			msg = "重判对象不存在"
+		case errors.Is(err, errRejudgeSkip):
+			msg = "没有需要重判的提交"
+		default:
+			log.Printf("RejudgeTask Create Error: %v, scope: %s, target: %s\n", err, scope, target)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+		return
+	}
+	go enqueueRejudge(task.Identity)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"identity": task.Identity,
+			"total":    task.Total,
+		},
+		"msg": "重判任务已创建",
+	})
+}
+
+// rejudgeQuery 返回重判范围内提交记录的查询，db 可以是事务
+func rejudgeQuery(db *gorm.DB, scope, target string) (*gorm.DB, error) {
+	tx := db.Model(new(models.SubmitBasic))
+	switch scope {
+	case rejudgeScopeSubmit:
+		return tx.Where("identity = ?", target), nil
+	case rejudgeScopeProblem:
+		var count int64
+		if err := db.Model(new(models.ProblemBasic)).Where("identity = ?", target).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return tx.Where("problem_identity = ?", target), nil
+	case rejudgeScopeContest:
+		cb := new(models.ContestBasic)
+		err := db.Where("identity = ?", target).Preload("ContestProblems.ProblemBasic", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "identity")
+		}).First(cb).Error
+		if err != nil {
+			return nil, err
+		}
+		problems := make([]string, 0, len(cb.ContestProblems))
+		for _, cp := range cb.ContestProblems {
+			if cp.ProblemBasic != nil {
+				problems = append(problems, cp.ProblemBasic.Identity)
+			}
+		}
+		return tx.Where("problem_identity IN ? AND created_at BETWEEN ? AND ?", problems, cb.StartAt, cb.EndAt), nil
+	}
+	return nil, errors.New("unknown rejudge scope: " + scope)
+}
+
+// resumeRejudges 在启动时继续进程退出前未完成的重判任务
+// 已经重置为待判的提交由判题队列恢复，这里只重置仍带有标记、尚未重置的提交
+func resumeRejudges() {
+	var identities []string
+	err := models.DB.Model(new(models.RejudgeTask)).Where("done = ?", false).Pluck("identity", &identities).Error
+	if err != nil {
+		log.Printf("Rejudge Resume Error: %v", err)
+		return
+	}
+	for _, identity := range identities {
+		go enqueueRejudge(identity)
+	}
+	if len(identities) > 0 {
+		log.Printf("Resumed %d unfinished rejudge tasks", len(identities))
+	}
+}
+
+// enqueueRejudge 逐条重置重判任务标记的、尚未重置的提交并放入判题队列，无法重判的提交清除标记后计入跳过数
+// 多个进程同时处理同一个任务时，每条提交只会被其中一个进程重置
+func enqueueRejudge(rejudgeIdentity string) {
+	ctx := context.Background()
+	var identities []string
+	err := models.DB.Model(new(models.SubmitBasic)).
+		Where("rejudge_identity = ? AND status <> ?", rejudgeIdentity, define.SubmitStatusPending).
+		Order("id ASC").Pluck("identity", &identities).Error
+	if err != nil {
+		log.Printf("Rejudge Query Error: %v, rejudge: %s", err, rejudgeIdentity)
+		return
+	}
+	for _, identity := range identities {
+		reset, err := resetSubmit(rejudgeIdentity, identity)
+		if err != nil {
+			if !errors.Is(err, errRejudgeSkip) {
+				log.Printf("Rejudge Reset Error: %v, submit: %s", err, identity)
+			}
+			skipRejudge(rejudgeIdentity, identity)
+			continue
+		}
+		if !reset {
+			continue // 已被其他进程重置
+		}
+		err = judgeQueue.Push(ctx, &JudgeJob{SubmitIdentity: identity, RejudgeIdentity: rejudgeIdentity})
+		if err != nil {
+			log.Printf("Judge Queue Push Error: %v, submit: %s", err, identity)
+			sb := &models.SubmitBasic{Identity: identity}
+			if err = finishSubmit(sb, &judge.Result{Status: define.SubmitStatusSystemError, Msg: "判题系统错误"}); err != nil {
+				log.Printf("Judge Submit Finish Error: %v, submit: %s", err, identity)
+			}
+			rejudgeProgress(rejudgeIdentity)
+		}
+	}
+	// 范围内的提交都已跳过或判完时在这里把任务标记为完成
+	rejudgeProgress(rejudgeIdentity)
+}
+
+// resetSubmit 将重判任务标记的提交记录重置为待判状态并清空判题结果，返回是否由本次调用重置
+// 原结果为答案正确时同时减少用户和问题的通过数，重新判题通过后由 finishSubmit 加回
+func resetSubmit(rejudgeIdentity, identity string) (bool, error) {
+	reset := false
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		sb := new(models.SubmitBasic)
+		err := tx.Select("identity", "problem_identity", "user_identity", "path", "code", "status").
+			Where("identity = ? AND rejudge_identity = ?", identity, rejudgeIdentity).First(sb).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // 已被删除或已经判完
+			}
+			return err
+		}
+		if sb.Status == define.SubmitStatusPending {
+			return nil
+		}
+		if sb.Code == "" {
+			// 没有保存代码的旧提交，代码文件在判题完成后已被删除，无法重判
+			if _, err = os.Stat(sb.Path); err != nil {
+				return errRejudgeSkip
+			}
+		}
+		res := tx.Model(new(models.SubmitBasic)).
+			Where("identity = ? AND status = ? AND rejudge_identity = ?", identity, sb.Status, rejudgeIdentity).
+			Updates(map[string]interface{}{
+				"status":     define.SubmitStatusPending,
+				"time_used":  0,
+				"mem_used":   0,
+				"score":      0,
+				"msg":        "",
+				"updated_at": models.MyTime(time.Now()),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil // 状态已被其他进程修改
+		}
+		reset = true
+		err = tx.Where("submit_identity = ?", identity).Delete(new(models.SubmitCaseResult)).Error
+		if err != nil || sb.Status != define.SubmitStatusAccepted {
+			return err
+		}
+		m := map[string]interface{}{"pass_num": gorm.Expr("pass_num - ?", 1)}
+		if err = tx.Model(new(models.UserBasic)).Where("identity = ?", sb.UserIdentity).Updates(m).Error; err != nil {
+			return err
+		}
+		return tx.Model(new(models.ProblemBasic)).Where("identity = ?", sb.ProblemIdentity).Updates(m).Error
+	})
+	return reset && err == nil, err
+}
+
+// skipRejudge 清除无法重判的提交上的重判标记并计入跳过数，标记已被清除时不重复计数
+func skipRejudge(rejudgeIdentity, identity string) {
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(new(models.SubmitBasic)).
+			Where("identity = ? AND rejudge_identity = ? AND status <> ?", identity, rejudgeIdentity, define.SubmitStatusPending).
+			UpdateColumn("rejudge_identity", "")
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Model(new(models.RejudgeTask)).Where("identity = ?", rejudgeIdentity).
+			UpdateColumn("skipped", gorm.Expr("skipped + ?", 1)).Error
+	})
+	if err != nil {
+		log.Printf("Rejudge Skip Error: %v, submit: %s", err, identity)
+		return
+	}
+	rejudgeProgress(rejudgeIdentity)
+}
+
+// rejudgeProgress 按仍带有重判标记的提交数更新重判任务的进度，重复调用不会重复计数
+// 全部处理完毕后按提交记录重新统计相关用户和问题的通过数和提交数
+func rejudgeProgress(rejudgeIdentity string) {
+	if rejudgeIdentity == "" {
+		return
+	}
+	var remaining int64
+	err := models.DB.Model(new(models.SubmitBasic)).Where("rejudge_identity = ?", rejudgeIdentity).Count(&remaining).Error
+	if err == nil {
+		// 并发调用时先查询的调用可能后写入，进度只增不减
+		err = models.DB.Model(new(models.RejudgeTask)).Where("identity = ?", rejudgeIdentity).Updates(map[string]interface{}{
+			"finished":   gorm.Expr("GREATEST(finished, total - ?)", remaining),
+			"updated_at": models.MyTime(time.Now()),
+		}).Error
+	}
+	if err != nil {
+		log.Printf("Rejudge Progress Error: %v, rejudge: %s", err, rejudgeIdentity)
+		return
+	}
+	if remaining > 0 {
+		return
+	}
+	// 只有把任务标记为完成的那次调用负责重新统计
+	res := models.DB.Model(new(models.RejudgeTask)).
+		Where("identity = ? AND done = ?", rejudgeIdentity, false).
+		Update("done", true)
+	if res.Error != nil {
+		log.Printf("Rejudge Progress Error: %v, rejudge: %s", res.Error, rejudgeIdentity)
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	task := new(models.RejudgeTask)
+	if err = models.DB.Where("identity = ?", rejudgeIdentity).First(task).Error; err == nil {
+		err = recountRejudge(task)
+	}
+	if err != nil {
+		log.Printf("Rejudge Recount Error: %v, rejudge: %s", err, rejudgeIdentity)
+	}
+}
+
+// recountRejudge 按提交记录重新统计重判范围内用户和问题的通过数和提交数，修正历史上累计的误差
+func recountRejudge(task *models.RejudgeTask) error {
+	query, err := rejudgeQuery(models.DB, task.Scope, task.TargetIdentity)
+	if err != nil {
+		return err
+	}
+	var problems, users []string
+	if err = query.Session(&gorm.Session{}).Distinct().Pluck("problem_identity", &problems).Error; err != nil {
+		return err
+	}
+	if err = query.Session(&gorm.Session{}).Distinct().Pluck("user_identity", &users).Error; err != nil {
+		return err
+	}
+	for _, identity := range problems {
+		if err = recountCounters(new(models.ProblemBasic), "problem_identity", identity); err != nil {
+			return err
+		}
+	}
+	for _, identity := range users {
+		if err = recountCounters(new(models.UserBasic), "user_identity", identity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recountCounters 按提交记录重新统计一个用户或问题的通过数和提交数
+func recountCounters(model interface{}, column, identity string) error {
+	submits := models.DB.Model(new(models.SubmitBasic)).Where(column+" = ?", identity)
+	return models.DB.Model(model).Where("identity = ?", identity).Updates(map[string]interface{}{
+		"pass_num":   submits.Session(&gorm.Session{}).Select("COUNT(*)").Where("status = ?", define.SubmitStatusAccepted),
+		"submit_num": submits.Session(&gorm.Session{}).Select("COUNT(*)"),
+	}).Error
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+)
+
+// maxRunInput 是自定义输入的最大字节数
+const maxRunInput = 1 << 20
+
+// runSlots 限制同时进行的自定义输入运行数，运行在 HTTP 请求中同步执行
+var runSlots = make(chan struct{}, max(utils.RunConcurrency, 1))
+
+// CodeRun
+// @Tags 用户私有方法
+// @Summary 自定义输入运行
+// @Accept json
+// @Param authorization header string true "authorization"
+// @Param data body define.RunCode true "RunCode"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /user/run [post]
+// CodeRun 函数使用用户提供的输入编译运行代码，没有提供输入时使用问题的样例输入，时间和内存限制与问题相同
+// 运行结果直接返回，不保存提交记录，也不更新提交数和通过数
+func CodeRun(c *gin.Context) {
+	in := new(define.RunCode)
+	if err := c.ShouldBindJSON(in); err != nil {
+		log.Printf("[CodeRun JsonBind Error] : %v\n", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数解析错误",
+		})
+		return
+	}
+	if in.ProblemIdentity == "" || in.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题标识和代码不能为空",
+		})
+		return
+	}
+	if len(in.Input) > maxRunInput {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "输入不能超过 1MB",
+		})
+		return
+	}
+	lang, ok := judge.GetLanguage(in.Language)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "不支持的语言：" + in.Language,
+		})
+		return
+	}
+
+	// 使用问题的时间和内存限制以及代码检查规则，并检查是否允许使用该语言。
+	pb := new(models.ProblemBasic)
+	err := models.DB.Select("identity", "languages", "max_runtime", "max_mem", "allow_imports", "deny_imports").
+		Where("identity = ?", in.ProblemIdentity).Preload("Templates").First(pb).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "问题不存在",
+			})
+			return
+		}
+		log.Printf("Get Problem Error: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取问题信息失败：" + err.Error(),
+		})
+		return
+	}
+	if !pb.AllowLanguage(lang.Name()) {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "该问题不允许使用 " + lang.Name() + " 提交",
+		})
+		return
+	}
+
+	// 没有提供输入时使用问题的样例输入，并返回样例输出以便对照。
+	var sample *define.ProblemSample
+	if in.Input == "" {
+		samples, err := loadSamples(c.Request.Context(), in.ProblemIdentity)
+		if err != nil {
+			log.Printf("Get Samples Error: %v", err)
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "获取问题样例失败：" + err.Error(),
+			})
+			return
+		}
+		if in.Sample < 0 || in.Sample > len(samples) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "样例不存在",
+			})
+			return
+		}
+		if len(samples) > 0 {
+			sample = samples[max(in.Sample, 1)-1]
+			in.Input = sample.Input
+		}
+	}
+
+	select {
+	case runSlots <- struct{}{}:
+		defer func() { <-runSlots }()
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "运行繁忙，请稍后重试",
+		})
+		return
+	}
+
+	path, err := utils.CodeSaveAs([]byte(in.Code), lang.SourceFile())
+	if err != nil {
+		log.Printf("Code Save Error: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "代码保存失败：" + err.Error(),
+		})
+		return
+	}
+	defer removeCode(path)
+
+	result := judge.RunCode(&judge.RunTask{
+		Path:        path,
+		Language:    lang.Name(),
+		MaxRuntime:  pb.MaxRuntime,
+		MaxMem:      pb.MaxMem,
+		Input:       in.Input,
+		Policy:      pb.CodePolicy(),
+		OutputLimit: utils.RunOutputLimit << 10,
+		Harness:     pb.Harness(lang.Name()),
+	})
+	data := map[string]interface{}{
+		"status":    result.Status,
+		"msg":       result.Msg,
+		"stdout":    result.Stdout,
+		"stderr":    result.Stderr,
+		"time_used": result.TimeUsed,
+		"mem_used":  result.MemUsed,
+		"exit_code": result.ExitCode,
+	}
+	if sample != nil {
+		data["input"], data["expected"] = sample.Input, sample.Output // 使用的样例输入和样例输出
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": data,
+	})
+}
@@ -1,12 +1,20 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"gin_gorm_oj/define"
 	"gin_gorm_oj/models"
+	"gin_gorm_oj/storage"
+	"gin_gorm_oj/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // GetTestCase
@@ -14,6 +22,7 @@ import (
 // @Summary 测试案例列表
 // @Param authorization header string true "authorization"
 // @Param identity query string true "问题唯一标识"
+// @Param is_sample query bool false "只返回样例（true）或只返回隐藏的测试用例（false）"
 // @Param page query int false "page"
 // @Param size query int false "size"
 // @Success 200 {string} json "{"code":"200","data":""}"
@@ -64,7 +73,19 @@ func GetTestCase(c *gin.Context) {
 	data := make([]*models.TestCase, 0)
 
 	// 构建数据库查询，首先筛选出特定problem_identity的测试案例，并计算总数
-	tx := models.DB.Model(new(models.TestCase)).Where("problem_identity = ?", problemIdentity).Count(&count)
+	tx := models.DB.Model(new(models.TestCase)).Where("problem_identity = ?", problemIdentity)
+	if v := c.Query("is_sample"); v != "" {
+		isSample, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "参数 is_sample 错误：" + err.Error(),
+			})
+			return
+		}
+		tx = tx.Where("is_sample = ?", isSample) // 按样例标记筛选
+	}
+	tx = tx.Count(&count)
 
 	// 如果查询参数中提供了size（意味着需要分页），则应用分页逻辑
 	// 修正：Limit参数应该使用size，而不是page
@@ -72,8 +93,8 @@ func GetTestCase(c *gin.Context) {
 		tx = tx.Offset(page).Limit(size) // 应用偏移量和限制数量
 	}
 
-	// 执行查询，将结果存储到data切片中
-	err = tx.Find(&data).Error
+	// 执行查询，将结果存储到data切片中，按判题顺序排列
+	err = tx.Order(models.TestCaseOrder).Find(&data).Error
 	// 检查数据库查询是否出错
 	if err != nil {
 		log.Printf("获取测试案例列表失败: %v, problemIdentity: %s\n", err, problemIdentity) // 记录详细错误日志
@@ -93,3 +114,489 @@ func GetTestCase(c *gin.Context) {
 		},
 	})
 }
+
+// TestCaseUpload
+// @Tags 管理员私有方法
+// @Summary 上传测试数据压缩包
+// @Param authorization header string true "authorization"
+// @Param problem_identity formData string true "问题唯一标识"
+// @Param file formData file true "由 N.in 和 N.out 文件对组成的 zip 压缩包"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/test-case-upload [post]
+// TestCaseUpload 函数用于上传问题的测试数据：检查压缩包中的文件配对，把文件保存到测试数据存储，
+// 用按编号排序的测试用例替换问题原有的测试用例，并保存测试数据的校验和、递增测试数据版本
+// 问题划分了子任务时测试用例个数必须与原来一致，各测试用例按顺序沿用原来所属的子任务和样例标记
+func TestCaseUpload(c *gin.Context) {
+	problemIdentity := c.PostForm("problem_identity")
+	fh, err := c.FormFile("file")
+	if problemIdentity == "" || err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题唯一标识和测试数据压缩包不能为空",
+		})
+		return
+	}
+	pb := new(models.ProblemBasic)
+	err = models.DB.Where("identity = ?", problemIdentity).Preload("TestCases", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "problem_identity", "subtask", "is_sample").Order(models.TestCaseOrder)
+	}).Preload("Subtasks").First(pb).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "问题不存在",
+			})
+			return
+		}
+		log.Printf("TestCaseUpload Error: %v, problem_identity: %s\n", err, problemIdentity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取问题失败：" + err.Error(),
+		})
+		return
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		log.Printf("TestCaseUpload Open Error: %v\n", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "读取上传文件失败：" + err.Error(),
+		})
+		return
+	}
+	defer f.Close()
+	pairs, err := storage.SaveZip(c.Request.Context(), storage.Default(), f, fh.Size, int64(utils.TestDataMaxSize)<<20)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if len(pb.Subtasks) > 0 && len(pairs) != len(pb.TestCases) {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  fmt.Sprintf("问题划分了子任务，测试用例个数必须与原来一致：原有 %d 个，上传 %d 个", len(pb.TestCases), len(pairs)),
+		})
+		return
+	}
+
+	tcs := make([]*models.TestCase, 0, len(pairs))
+	now := models.MyTime(time.Now())
+	for i, p := range pairs {
+		tc := &models.TestCase{
+			Identity:        utils.GetUUID(),
+			ProblemIdentity: problemIdentity,
+			InputHash:       p.InputHash,
+			InputSize:       p.InputSize,
+			OutputHash:      p.OutputHash,
+			OutputSize:      p.OutputSize,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if len(pb.Subtasks) > 0 {
+			tc.Subtask = pb.TestCases[i].Subtask
+		}
+		if i < len(pb.TestCases) {
+			tc.IsSample = pb.TestCases[i].IsSample // 样例标记同样按顺序沿用
+		}
+		tc.Sort = i + 1
+		tcs = append(tcs, tc)
+	}
+	checksum := testDataChecksum(tcs)
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("problem_identity = ?", problemIdentity).Delete(new(models.TestCase)).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&tcs).Error; err != nil {
+			return err
+		}
+		err := tx.Model(new(models.ProblemBasic)).Where("identity = ?", problemIdentity).Updates(map[string]interface{}{
+			"test_data_checksum": checksum,
+			"test_data_version":  gorm.Expr("test_data_version + ?", 1), // 使判题结果缓存失效
+		}).Error
+		if err != nil {
+			return err
+		}
+		_, err = recordRevision(tx, problemIdentity, "上传测试数据")
+		return err
+	})
+	if err != nil {
+		log.Printf("TestCaseUpload Save Error: %v, problem_identity: %s\n", err, problemIdentity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "保存测试用例失败：" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": map[string]interface{}{
+			"test_data_checksum": checksum,
+			"test_data_version":  pb.TestDataVersion + 1,
+			"list":               tcs,
+		},
+	})
+}
+
+// TestCaseCreate
+// @Tags 管理员私有方法
+// @Summary 添加测试用例
+// @Param authorization header string true "authorization"
+// @Param data body define.TestCaseCreate true "TestCaseCreate"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/test-case-create [post]
+// TestCaseCreate 函数用于给问题添加一个测试用例，新的测试用例排在最后
+func TestCaseCreate(c *gin.Context) {
+	in := new(define.TestCaseCreate)
+	if err := c.ShouldBindJSON(in); err != nil {
+		log.Printf("[TestCaseCreate JsonBind Error] : %v\n", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数解析错误",
+		})
+		return
+	}
+	if in.ProblemIdentity == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题唯一标识不能为空",
+		})
+		return
+	}
+	var cnt int64
+	if err := models.DB.Model(new(models.ProblemBasic)).Where("identity = ?", in.ProblemIdentity).Count(&cnt).Error; err != nil || cnt == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题不存在",
+		})
+		return
+	}
+
+	tc := &models.TestCase{
+		Identity:        utils.GetUUID(),
+		ProblemIdentity: in.ProblemIdentity,
+		Input:           in.Input,
+		Output:          in.Output,
+		Subtask:         in.Subtask,
+		IsSample:        in.IsSample,
+		Explanation:     in.Explanation,
+		CreatedAt:       models.MyTime(time.Now()),
+		UpdatedAt:       models.MyTime(time.Now()),
+	}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var maxSort int
+		err := tx.Model(new(models.TestCase)).Where("problem_identity = ?", in.ProblemIdentity).
+			Select("COALESCE(MAX(sort), 0)").Scan(&maxSort).Error
+		if err != nil {
+			return err
+		}
+		tc.Sort = maxSort + 1
+		if err = tx.Create(tc).Error; err != nil {
+			return err
+		}
+		return finishTestCaseChange(tx, in.ProblemIdentity, "添加测试用例")
+	})
+	if err != nil {
+		testCaseChangeError(c, "TestCaseCreate", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": tc,
+	})
+}
+
+// TestCaseModify
+// @Tags 管理员私有方法
+// @Summary 修改测试用例
+// @Param authorization header string true "authorization"
+// @Param data body define.TestCaseModify true "TestCaseModify"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/test-case-modify [put]
+// TestCaseModify 函数用于修改单个测试用例的输入、预期输出、所属子任务、样例标记或样例说明，测试用例的唯一标识不变
+// 测试数据保存在 storage 中的测试用例，新的输入输出同样保存到 storage 中
+func TestCaseModify(c *gin.Context) {
+	in := new(define.TestCaseModify)
+	if err := c.ShouldBindJSON(in); err != nil {
+		log.Printf("[TestCaseModify JsonBind Error] : %v\n", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数解析错误",
+		})
+		return
+	}
+	tc := new(models.TestCase)
+	if err := models.DB.Where("identity = ?", in.Identity).First(tc).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "测试用例不存在",
+			})
+			return
+		}
+		log.Printf("TestCaseModify Error: %v, identity: %s\n", err, in.Identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取测试用例失败：" + err.Error(),
+		})
+		return
+	}
+
+	m := make(map[string]interface{})
+	for _, f := range []struct {
+		column  string
+		content *string
+	}{
+		{"input", in.Input},
+		{"output", in.Output},
+	} {
+		if f.content == nil {
+			continue
+		}
+		if !tc.Stored() {
+			m[f.column] = *f.content
+			continue
+		}
+		hash, size, err := storage.Default().Save(c.Request.Context(), strings.NewReader(*f.content))
+		if err != nil {
+			log.Printf("TestCaseModify Save Error: %v, identity: %s\n", err, in.Identity)
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "保存测试数据失败：" + err.Error(),
+			})
+			return
+		}
+		m[f.column+"_hash"], m[f.column+"_size"] = hash, size
+	}
+	if in.Subtask != nil {
+		m["subtask"] = *in.Subtask
+	}
+	if in.IsSample != nil {
+		m["is_sample"] = *in.IsSample
+	}
+	if in.Explanation != nil {
+		m["explanation"] = *in.Explanation
+	}
+	if len(m) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "没有需要修改的字段",
+		})
+		return
+	}
+	m["updated_at"] = models.MyTime(time.Now())
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(new(models.TestCase)).Where("identity = ?", in.Identity).Updates(m).Error; err != nil {
+			return err
+		}
+		return finishTestCaseChange(tx, tc.ProblemIdentity, "修改测试用例")
+	})
+	if err != nil {
+		testCaseChangeError(c, "TestCaseModify", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "修改成功",
+	})
+}
+
+// TestCaseSort
+// @Tags 管理员私有方法
+// @Summary 调整测试用例顺序
+// @Param authorization header string true "authorization"
+// @Param data body define.TestCaseSort true "TestCaseSort"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/test-case-sort [put]
+// TestCaseSort 函数用于按给出的顺序重新排列问题的全部测试用例，判题按该顺序执行
+func TestCaseSort(c *gin.Context) {
+	in := new(define.TestCaseSort)
+	if err := c.ShouldBindJSON(in); err != nil {
+		log.Printf("[TestCaseSort JsonBind Error] : %v\n", err)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "参数解析错误",
+		})
+		return
+	}
+	if in.ProblemIdentity == "" || len(in.Identities) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "问题唯一标识和测试用例顺序不能为空",
+		})
+		return
+	}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var identities []string
+		err := tx.Model(new(models.TestCase)).Where("problem_identity = ?", in.ProblemIdentity).Pluck("identity", &identities).Error
+		if err != nil {
+			return err
+		}
+		if err = checkTestCaseOrder(identities, in.Identities); err != nil {
+			return err
+		}
+		for i, identity := range in.Identities {
+			err = tx.Model(new(models.TestCase)).Where("identity = ?", identity).Update("sort", i+1).Error
+			if err != nil {
+				return err
+			}
+		}
+		return finishTestCaseChange(tx, in.ProblemIdentity, "调整测试用例顺序")
+	})
+	if err != nil {
+		testCaseChangeError(c, "TestCaseSort", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "调整成功",
+	})
+}
+
+// TestCaseDelete
+// @Tags 管理员私有方法
+// @Summary 删除测试用例
+// @Param authorization header string true "authorization"
+// @Param identity query string true "测试用例唯一标识"
+// @Success 200 {string} json "{"code":"200","data":""}"
+// @Router /admin/test-case-delete [delete]
+// TestCaseDelete 函数用于删除单个测试用例，删除后子任务不能为空
+func TestCaseDelete(c *gin.Context) {
+	identity := c.Query("identity")
+	tc := new(models.TestCase)
+	if err := models.DB.Select("problem_identity").Where("identity = ?", identity).First(tc).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": -1,
+				"msg":  "测试用例不存在",
+			})
+			return
+		}
+		log.Printf("TestCaseDelete Error: %v, identity: %s\n", err, identity)
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "获取测试用例失败：" + err.Error(),
+		})
+		return
+	}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("identity = ?", identity).Delete(new(models.TestCase)).Error; err != nil {
+			return err
+		}
+		return finishTestCaseChange(tx, tc.ProblemIdentity, "删除测试用例")
+	})
+	if err != nil {
+		testCaseChangeError(c, "TestCaseDelete", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "删除成功",
+	})
+}
+
+// invalidTestCaseChange 表示修改后的测试用例不合法，错误信息直接返回给管理员
+type invalidTestCaseChange struct {
+	error
+}
+
+// finishTestCaseChange 在修改测试用例的事务中按修改后的测试用例重新检查子任务划分，并递增问题的测试数据版本使判题结果缓存失效；
+// 单独修改后的测试数据不再与上传的压缩包一致，同时清空测试数据校验和，最后以 comment 保存问题的版本快照
+func finishTestCaseChange(tx *gorm.DB, problemIdentity, comment string) error {
+	subtasks := make([]*models.ProblemSubtask, 0)
+	if err := tx.Where("problem_identity = ?", problemIdentity).Order("number ASC").Find(&subtasks).Error; err != nil {
+		return err
+	}
+	tcs := make([]*models.TestCase, 0)
+	if err := tx.Select("subtask").Where("problem_identity = ?", problemIdentity).Order(models.TestCaseOrder).Find(&tcs).Error; err != nil {
+		return err
+	}
+	check := &define.ProblemBasic{}
+	for _, st := range subtasks {
+		check.Subtasks = append(check.Subtasks, &define.Subtask{Number: st.Number, Score: st.Score, Depends: subtaskDepends(st)})
+	}
+	for _, tc := range tcs {
+		check.TestCases = append(check.TestCases, &define.TestCase{Subtask: tc.Subtask})
+	}
+	if err := checkSubtasks(check); err != nil {
+		return invalidTestCaseChange{err}
+	}
+	err := tx.Model(new(models.ProblemBasic)).Where("identity = ?", problemIdentity).Updates(map[string]interface{}{
+		"test_data_version":  gorm.Expr("test_data_version + ?", 1),
+		"test_data_checksum": "",
+	}).Error
+	if err != nil {
+		return err
+	}
+	_, err = recordRevision(tx, problemIdentity, comment)
+	return err
+}
+
+// checkTestCaseOrder 检查新的顺序恰好包含问题的全部测试用例各一次
+func checkTestCaseOrder(identities, order []string) error {
+	if len(order) != len(identities) {
+		return invalidTestCaseChange{fmt.Errorf("需要给出全部 %d 个测试用例的顺序", len(identities))}
+	}
+	remain := make(map[string]struct{}, len(identities))
+	for _, identity := range identities {
+		remain[identity] = struct{}{}
+	}
+	for _, identity := range order {
+		if _, ok := remain[identity]; !ok {
+			return invalidTestCaseChange{errors.New("测试用例不存在或重复：" + identity)}
+		}
+		delete(remain, identity)
+	}
+	return nil
+}
+
+// testCaseChangeError 返回修改测试用例的错误，不合法的修改返回原因，其余错误记录日志
+func testCaseChangeError(c *gin.Context, name string, err error) {
+	var invalid invalidTestCaseChange
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  invalid.Error(),
+		})
+		return
+	}
+	log.Printf("%s Error: %v\n", name, err)
+	c.JSON(http.StatusOK, gin.H{
+		"code": -1,
+		"msg":  "保存测试用例失败：" + err.Error(),
+	})
+}
+
+// testDataChecksum 计算测试数据的校验和：由各测试用例输入输出文件的校验和按顺序计算，内容相同的测试数据校验和相同
+func testDataChecksum(tcs []*models.TestCase) string {
+	var manifest strings.Builder
+	for _, tc := range tcs {
+		fmt.Fprintf(&manifest, "%s %s\n", tc.InputHash, tc.OutputHash)
+	}
+	return storage.Checksum([]byte(manifest.String()))
+}
+
+// loadSamples 按顺序读取问题的样例，保存在 storage 中的样例从 storage 读取
+func loadSamples(ctx context.Context, problemIdentity string) ([]*define.ProblemSample, error) {
+	tcs := make([]*models.TestCase, 0)
+	err := models.DB.Where("problem_identity = ? AND is_sample = ?", problemIdentity, true).
+		Order(models.TestCaseOrder).Find(&tcs).Error
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]*define.ProblemSample, 0, len(tcs))
+	for _, tc := range tcs {
+		sample := &define.ProblemSample{Input: tc.Input, Output: tc.Output, Explanation: tc.Explanation}
+		if tc.Stored() {
+			if sample.Input, sample.Output, err = readStoredTestCase(ctx, tc); err != nil {
+				return nil, err
+			}
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
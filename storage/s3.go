@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emptyPayloadHash 是空请求体的 SHA-256 校验和，用于签名没有请求体的请求
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 把文件保存在兼容 S3 的对象存储中（AWS S3、MinIO 等），对象键为 Prefix 加校验和，使用路径风格的地址和 SigV4 签名
+// 上传的文件和下载过的文件都保存在本地缓存中，判题时从缓存读取
+type S3 struct {
+	// Endpoint 是对象存储的地址，例如 https://s3.us-east-1.amazonaws.com 或 http://127.0.0.1:9000
+	Endpoint string
+	// Region 是签名使用的区域，MinIO 通常为 us-east-1
+	Region string
+	// Bucket 是存储桶名称
+	Bucket string
+	// Prefix 是对象键的前缀
+	Prefix string
+	// AccessKey 和 SecretKey 是访问凭证
+	AccessKey string
+	SecretKey string
+	// Cache 是本地缓存
+	Cache *Local
+	// Client 是发送请求的 HTTP 客户端，为空时使用 http.DefaultClient
+	Client *http.Client
+
+	lock sync.Mutex // 串行化下载，避免多个判题协程重复下载同一个文件
+}
+
+// Save 先把内容写入本地缓存的临时文件并计算校验和，上传成功后再放入缓存
+// 上传请求的 x-amz-content-sha256 就是文件的校验和，对象存储会校验上传内容的完整性
+func (s *S3) Save(ctx context.Context, r io.Reader) (string, int64, error) {
+	tmp, hash, size, err := s.Cache.spool(r)
+	if err != nil {
+		return "", 0, err
+	}
+	f, err := os.Open(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return "", 0, err
+	}
+	err = s.put(ctx, hash, f, size)
+	f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return "", 0, err
+	}
+	if err = s.Cache.commit(tmp, hash); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// Open 打开校验和为 hash 的文件，本地缓存中没有时先下载
+func (s *S3) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	path, err := s.Path(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Path 返回校验和为 hash 的文件在本地缓存中的路径，缓存中没有时下载并校验
+func (s *S3) Path(ctx context.Context, hash string) (string, error) {
+	if path, err := s.Cache.Path(ctx, hash); err == nil {
+		return path, nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// 拿到锁后再检查一次，其他协程可能已经下载过
+	if path, err := s.Cache.Path(ctx, hash); err == nil {
+		return path, nil
+	}
+	body, err := s.get(ctx, hash)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	tmp, got, _, err := s.Cache.spool(body)
+	if err != nil {
+		return "", err
+	}
+	if got != hash {
+		os.Remove(tmp)
+		return "", fmt.Errorf("storage: object %s checksum mismatch", hash)
+	}
+	if err = s.Cache.commit(tmp, hash); err != nil {
+		return "", err
+	}
+	return s.Cache.path(hash), nil
+}
+
+// objectURL 返回对象的路径风格地址
+func (s *S3) objectURL(hash string) string {
+	return strings.TrimRight(s.Endpoint, "/") + "/" + s.Bucket + "/" + s.Prefix + hash
+}
+
+// put 上传对象
+func (s *S3) put(ctx context.Context, hash string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(hash), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := s.do(req, hash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// get 下载对象，返回响应体
+func (s *S3) get(ctx context.Context, hash string) (io.ReadCloser, error) {
+	if !ValidChecksum(hash) {
+		return nil, fmt.Errorf("storage: invalid checksum %q", hash)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(hash), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do 签名并发送请求，状态码不是 2xx 时返回错误
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	SignV4(req, payloadHash, s.Region, s.AccessKey, s.SecretKey, time.Now())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+	return nil, fmt.Errorf("storage: %s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// SignV4 按 AWS Signature Version 4 为发往 S3 的请求签名，设置 X-Amz-Date、X-Amz-Content-Sha256 和 Authorization 请求头
+// payloadHash 是请求体的 SHA-256 校验和（十六进制）；参与签名的请求头为 host、range 和所有 x-amz-* 请求头
+func SignV4(req *http.Request, payloadHash, region, accessKey, secretKey string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery 按参数名排序并编码查询参数，空格编码为 %20
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 按 SigV4 的要求编码：只保留非保留字符，其余字节编码为 %XX
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// hmacSHA256 计算 HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
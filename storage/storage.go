@@ -0,0 +1,153 @@
+// Package storage 实现按内容寻址的测试数据存储
+// 文件以内容的 SHA-256 校验和（十六进制）为键，内容相同的文件只保存一份；默认保存在本地文件系统，
+// 也可以保存在兼容 S3 的对象存储中，此时本地目录作为下载缓存，判题时测试数据总是从本地文件读取
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin_gorm_oj/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound 表示存储中没有该校验和的文件
+var ErrNotFound = errors.New("storage: file not found")
+
+// Store 是按内容寻址的文件存储
+type Store interface {
+	// Save 保存 r 的全部内容，返回内容的校验和与字节数
+	Save(ctx context.Context, r io.Reader) (hash string, size int64, err error)
+	// Open 打开校验和为 hash 的文件
+	Open(ctx context.Context, hash string) (io.ReadCloser, error)
+	// Path 返回校验和为 hash 的文件在本地文件系统中的路径，判题时直接作为程序的标准输入，调用方不能修改或删除该文件
+	Path(ctx context.Context, hash string) (string, error)
+}
+
+// Checksum 返回数据的 SHA-256 校验和（十六进制），与判题节点的 node.Checksum 相同
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidChecksum 判断校验和是否为 64 位十六进制字符串，校验和会被用作文件名和对象键
+func ValidChecksum(hash string) bool {
+	b, err := hex.DecodeString(hash)
+	return err == nil && len(b) == sha256.Size
+}
+
+var (
+	defaultStore Store
+	defaultOnce  sync.Once
+)
+
+// Default 返回按配置创建的存储，配置见 utils.LoadStorage
+func Default() Store {
+	defaultOnce.Do(func() {
+		local := &Local{Dir: utils.StorageDir}
+		if utils.StorageBackend != "s3" {
+			defaultStore = local
+			return
+		}
+		defaultStore = &S3{
+			Endpoint:  utils.S3Endpoint,
+			Region:    utils.S3Region,
+			Bucket:    utils.S3Bucket,
+			Prefix:    utils.S3Prefix,
+			AccessKey: utils.S3AccessKey,
+			SecretKey: utils.S3SecretKey,
+			Cache:     local,
+		}
+	})
+	return defaultStore
+}
+
+// Local 把文件保存在本地目录中，文件路径为 Dir/校验和前两位/校验和
+type Local struct {
+	// Dir 是存储目录
+	Dir string
+}
+
+// path 返回校验和对应的文件路径
+func (l *Local) path(hash string) string {
+	return filepath.Join(l.Dir, hash[:2], hash)
+}
+
+// Save 把内容写入临时文件并计算校验和，再移动到校验和对应的路径，文件已存在时直接丢弃临时文件
+func (l *Local) Save(ctx context.Context, r io.Reader) (string, int64, error) {
+	tmp, hash, size, err := l.spool(r)
+	if err != nil {
+		return "", 0, err
+	}
+	if err = l.commit(tmp, hash); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// Open 打开校验和为 hash 的文件
+func (l *Local) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	path, err := l.Path(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Path 返回校验和为 hash 的文件路径
+func (l *Local) Path(ctx context.Context, hash string) (string, error) {
+	if !ValidChecksum(hash) {
+		return "", fmt.Errorf("storage: invalid checksum %q", hash)
+	}
+	path := l.path(hash)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, hash)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// spool 把 r 的内容写入存储目录下的临时文件，同时计算校验和，出错时删除临时文件
+func (l *Local) spool(r io.Reader) (tmp, hash string, size int64, err error) {
+	if err = os.MkdirAll(l.Dir, 0755); err != nil {
+		return "", "", 0, err
+	}
+	f, err := os.CreateTemp(l.Dir, ".tmp-")
+	if err != nil {
+		return "", "", 0, err
+	}
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(f, h), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", "", 0, err
+	}
+	return f.Name(), hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// commit 把 spool 写好的临时文件移动到校验和对应的路径，重命名是原子的，读取方不会看到写了一半的文件
+func (l *Local) commit(tmp, hash string) error {
+	path := l.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return os.Remove(tmp) // 内容相同的文件已经存在
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// testDataName 匹配测试数据文件名：编号.in 为输入，编号.out 为预期输出
+var testDataName = regexp.MustCompile(`^([0-9]+)\.(in|out)$`)
+
+// Pair 是压缩包中一对输入输出文件保存后的结果
+type Pair struct {
+	// Number 是文件名中的编号
+	Number int
+	// InputHash 和 InputSize 是输入文件的校验和与字节数
+	InputHash string
+	InputSize int64
+	// OutputHash 和 OutputSize 是预期输出文件的校验和与字节数
+	OutputHash string
+	OutputSize int64
+}
+
+// SaveZip 读取由 N.in 和 N.out 文件对组成的压缩包，检查文件配对后把各文件保存到 store，结果按编号从小到大排列
+// 文件可以位于压缩包中的任意目录，但编号不能重复；目录、以 . 开头的文件和 __MACOSX 目录会被忽略，其他文件视为错误；
+// 解压后的总大小超过 maxSize 字节时返回错误，大小以实际解压的字节数为准，不信任压缩包中记录的大小
+func SaveZip(ctx context.Context, store Store, r io.ReaderAt, size, maxSize int64) ([]*Pair, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("无法读取压缩包：" + err.Error())
+	}
+	type entry struct{ in, out *zip.File }
+	entries := make(map[int]*entry)
+	for _, f := range zr.File {
+		name := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		m := testDataName.FindStringSubmatch(name)
+		if m == nil {
+			return nil, fmt.Errorf("文件 %s 的名称不符合 编号.in 或 编号.out 的格式", f.Name)
+		}
+		number, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("文件 %s 的编号无效", f.Name)
+		}
+		e := entries[number]
+		if e == nil {
+			e = &entry{}
+			entries[number] = e
+		}
+		slot := &e.in
+		if m[2] == "out" {
+			slot = &e.out
+		}
+		if *slot != nil {
+			return nil, fmt.Errorf("编号 %d 的 .%s 文件重复：%s 和 %s", number, m[2], (*slot).Name, f.Name)
+		}
+		*slot = f
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("压缩包中没有测试数据")
+	}
+	numbers := make([]int, 0, len(entries))
+	for number, e := range entries {
+		if e.in == nil {
+			return nil, fmt.Errorf("编号 %d 缺少输入文件 %d.in", number, number)
+		}
+		if e.out == nil {
+			return nil, fmt.Errorf("编号 %d 缺少输出文件 %d.out", number, number)
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	budget := &sizeBudget{remain: maxSize}
+	pairs := make([]*Pair, 0, len(numbers))
+	for _, number := range numbers {
+		e := entries[number]
+		p := &Pair{Number: number}
+		if p.InputHash, p.InputSize, err = saveZipFile(ctx, store, e.in, budget); err != nil {
+			return nil, err
+		}
+		if p.OutputHash, p.OutputSize, err = saveZipFile(ctx, store, e.out, budget); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, nil
+}
+
+// errTooLarge 表示解压后的测试数据超过大小限制
+var errTooLarge = errors.New("测试数据解压后超过大小限制")
+
+// sizeBudget 记录整个压缩包还能解压的字节数
+type sizeBudget struct {
+	remain int64
+}
+
+// saveZipFile 解压一个文件并保存到 store
+func saveZipFile(ctx context.Context, store Store, f *zip.File, budget *sizeBudget) (string, int64, error) {
+	if f.UncompressedSize64 > uint64(budget.remain) {
+		return "", 0, errTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", 0, fmt.Errorf("无法解压文件 %s：%v", f.Name, err)
+	}
+	defer rc.Close()
+	hash, size, err := store.Save(ctx, &budgetReader{r: rc, budget: budget})
+	if errors.Is(err, errTooLarge) {
+		return "", 0, err
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("保存文件 %s 失败：%v", f.Name, err)
+	}
+	return hash, size, nil
+}
+
+// budgetReader 在读取的字节数超出 budget 时返回 errTooLarge
+type budgetReader struct {
+	r      io.Reader
+	budget *sizeBudget
+}
+
+func (br *budgetReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	br.budget.remain -= int64(n)
+	if br.budget.remain < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
@@ -167,3 +167,83 @@ func TestCodeSaveAndCheckValidIntegration(t *testing.T) {
 		})
 	}
 }
+
+// TestCheckGoCode 验证基于语法树的代码检查能正确识别导入的包和编译指令，并报告违反的规则
+func TestCheckGoCode(t *testing.T) {
+	testCases := []struct {
+		name   string
+		code   string
+		policy *utils.CodePolicy
+		rule   string // 期望违反的规则，为空表示代码合法
+	}{
+		{
+			name: "Import In Comment And Raw String",
+			code: "package main\n\nimport \"fmt\"\n\n// import \"os\"\nfunc main() { fmt.Println(`import \"os\"`) }\n",
+		},
+		{
+			name: "Aliased Allowed Import",
+			code: "package main\n\nimport f \"fmt\"\n\nfunc main() { f.Println() }\n",
+		},
+		{
+			name: "Aliased Forbidden Import",
+			code: "package main\n\nimport o \"os\"\n\nfunc main() { o.Exit(0) }\n",
+			rule: utils.RuleImportNotAllowed,
+		},
+		{
+			name: "Dot Import",
+			code: "package main\n\nimport . \"os\"\n\nfunc main() { Exit(0) }\n",
+			rule: utils.RuleImportNotAllowed,
+		},
+		{
+			name:   "Unsafe Always Denied",
+			code:   "package main\n\nimport \"unsafe\"\n\nfunc main() { _ = unsafe.Sizeof(0) }\n",
+			policy: &utils.CodePolicy{AllowImports: []string{"unsafe"}},
+			rule:   utils.RuleUnsafe,
+		},
+		{
+			name: "Cgo",
+			code: "package main\n\n// #include <stdlib.h>\nimport \"C\"\n\nfunc main() {}\n",
+			rule: utils.RuleCgo,
+		},
+		{
+			name: "Linkname Directive",
+			code: "package main\n\nimport \"fmt\"\n\n//go:linkname now time.now\nfunc now() (int64, int32, int64)\n\nfunc main() { fmt.Println(now()) }\n",
+			rule: utils.RuleDirective,
+		},
+		{
+			name:   "Problem Allow List",
+			code:   "package main\n\nimport \"os\"\n\nfunc main() { os.Exit(0) }\n",
+			policy: &utils.CodePolicy{AllowImports: []string{"os"}},
+		},
+		{
+			name:   "Problem Deny List",
+			code:   "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n",
+			policy: &utils.CodePolicy{DenyImports: []string{"fmt"}},
+			rule:   utils.RuleImportDenied,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.go")
+			if err := os.WriteFile(path, []byte(tc.code), 0644); err != nil {
+				t.Fatalf("Failed to write code: %v", err)
+			}
+			violation, err := utils.CheckGoCode(path, tc.policy)
+			if err != nil {
+				t.Fatalf("CheckGoCode() failed: %v", err)
+			}
+			if tc.rule == "" {
+				if violation != nil {
+					t.Fatalf("CheckGoCode() = %v; want no violation", violation)
+				}
+				return
+			}
+			if violation == nil || violation.Rule != tc.rule {
+				t.Fatalf("CheckGoCode() = %v; want rule %s", violation, tc.rule)
+			}
+			if violation.Line == 0 || violation.Detail == "" {
+				t.Errorf("CheckGoCode() violation %+v lacks line or detail", violation)
+			}
+		})
+	}
+}
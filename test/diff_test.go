@@ -0,0 +1,27 @@
+package test
+
+import (
+	"gin_gorm_oj/utils"
+	"reflect"
+	"testing"
+)
+
+// TestDiffLines 验证逐行比较的结果：相同的行保留，删除的行排在同一位置新增的行之前，换行符统一处理
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want []string
+	}{
+		{"", "", []string{}},
+		{"a\nb\n", "a\r\nb", []string{"  a", "  b"}},
+		{"", "a\n", []string{"+ a"}},
+		{"a\nb\nc\n", "a\nx\nc\n", []string{"  a", "- b", "+ x", "  c"}},
+		{"a\nb\nc\nd", "a\nc\nd\ne", []string{"  a", "- b", "  c", "  d", "+ e"}},
+		{"x\na\ny\nb", "a\nz\nb", []string{"- x", "  a", "- y", "+ z", "  b"}},
+	}
+	for _, c := range cases {
+		if got := utils.DiffLines(c.a, c.b); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("DiffLines(%q, %q) = %q, want %q", c.a, c.b, got, c.want)
+		}
+	}
+}
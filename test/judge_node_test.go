@@ -0,0 +1,237 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge/node"
+	"gin_gorm_oj/middlewares"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJudgeAPI 在内存中实现判题节点协议的 API 端，用于测试判题节点
+type fakeJudgeAPI struct {
+	lock      sync.Mutex
+	jobs      []*node.Job
+	cases     map[string][2]string           // 测试用例标识 -> 输入和预期输出
+	nodes     map[string]string              // 节点标识 -> 节点名称
+	results   map[string]*node.ResultRequest // 任务凭据 -> 上报的结果
+	downloads map[string]int                 // 节点名称 -> 下载测试数据的次数
+	done      chan struct{}
+	total     int
+}
+
+func (api *fakeJudgeAPI) router(token string) *gin.Engine {
+	r := gin.New()
+	// 每个判题节点使用不同的地址前缀，以便统计各节点下载测试数据的次数
+	g := r.Group("/:node", middlewares.JudgeNodeAuth(token))
+	g.POST(node.PathRegister, func(c *gin.Context) {
+		in := new(node.RegisterRequest)
+		c.ShouldBindJSON(in)
+		api.lock.Lock()
+		identity := fmt.Sprintf("node-%d", len(api.nodes)+1)
+		api.nodes[identity] = in.Name
+		api.lock.Unlock()
+		c.JSON(http.StatusOK, gin.H{"code": 200, "data": &node.RegisterResponse{NodeIdentity: identity, Heartbeat: 1}})
+	})
+	g.POST(node.PathHeartbeat, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "ok"})
+	})
+	g.POST(node.PathPull, func(c *gin.Context) {
+		api.lock.Lock()
+		var job *node.Job
+		if len(api.jobs) > 0 {
+			job, api.jobs = api.jobs[0], api.jobs[1:]
+		}
+		api.lock.Unlock()
+		if job == nil {
+			time.Sleep(20 * time.Millisecond) // 模拟长轮询
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 200, "data": job})
+	})
+	g.GET(node.PathTestData, func(c *gin.Context) {
+		api.lock.Lock()
+		api.downloads[c.Param("node")]++
+		data, ok := api.cases[c.Query("identity")]
+		api.lock.Unlock()
+		for _, content := range data {
+			if ok && node.Checksum(content) == c.Query("hash") {
+				c.Data(http.StatusOK, "application/octet-stream", []byte(content))
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"code": -1, "msg": "测试数据不存在"})
+	})
+	g.POST(node.PathProgress, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "ok"})
+	})
+	g.POST(node.PathResult, func(c *gin.Context) {
+		in := new(node.ResultRequest)
+		if err := c.ShouldBindJSON(in); err != nil {
+			c.JSON(http.StatusOK, gin.H{"code": -1, "msg": "参数错误"})
+			return
+		}
+		api.lock.Lock()
+		api.results[in.Ticket] = in
+		if len(api.results) == api.total {
+			close(api.done)
+		}
+		api.lock.Unlock()
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "ok"})
+	})
+	return r
+}
+
+// TestJudgeNodes 验证多个判题节点并发拉取任务、按校验和缓存测试数据并上报结果
+func TestJudgeNodes(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	gin.SetMode(gin.TestMode)
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+
+	const token = "secret"
+	codes := map[int]string{
+		define.SubmitStatusAccepted:    "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); fmt.Println(a + b) }\n",
+		define.SubmitStatusWrongAnswer: "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); fmt.Println(a - b) }\n",
+	}
+	api := &fakeJudgeAPI{
+		cases: map[string][2]string{
+			"case_1": {"1 2", "3\n"},
+			"case_2": {"5 7", "12\n"},
+		},
+		nodes:     make(map[string]string),
+		results:   make(map[string]*node.ResultRequest),
+		downloads: make(map[string]int),
+		done:      make(chan struct{}),
+	}
+	want := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		status := define.SubmitStatusAccepted
+		if i%3 == 2 {
+			status = define.SubmitStatusWrongAnswer
+		}
+		job := &node.Job{
+			Ticket:         fmt.Sprintf("ticket-%d", i),
+			SubmitIdentity: fmt.Sprintf("submit-%d", i),
+			Language:       "go",
+			Code:           codes[status],
+			MaxRuntime:     2000,
+			MaxMem:         256 * 1024,
+		}
+		for _, identity := range []string{"case_1", "case_2"} {
+			data := api.cases[identity]
+			job.TestCases = append(job.TestCases, &node.TestCase{
+				Identity:   identity,
+				InputHash:  node.Checksum(data[0]),
+				OutputHash: node.Checksum(data[1]),
+			})
+		}
+		api.jobs = append(api.jobs, job)
+		want[job.Ticket] = status
+	}
+	api.total = len(api.jobs)
+	server := httptest.NewServer(api.router(token))
+	defer server.Close()
+
+	// 密钥错误的节点无法注册
+	bad := &node.Client{Server: server.URL + "/bad", Token: "wrong"}
+	if _, err := bad.Register(context.Background(), &node.RegisterRequest{Name: "bad"}); err == nil {
+		t.Errorf("Register with wrong token succeeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	names := []string{"n1", "n2"}
+	for _, name := range names {
+		n := &node.Node{
+			Client:  &node.Client{Server: server.URL + "/" + name, Token: token},
+			Name:    name,
+			Workers: 2,
+			Cache:   &node.Cache{Dir: filepath.Join("cache", name)},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.Run(ctx)
+		}()
+	}
+	select {
+	case <-api.done:
+	case <-time.After(2 * time.Minute):
+		t.Fatalf("Timed out waiting for results, got %d of %d", len(api.results), api.total)
+	}
+	cancel()
+	wg.Wait()
+
+	for ticket, status := range want {
+		res := api.results[ticket]
+		if res.Error != "" || res.Result == nil {
+			t.Errorf("%s: judge node error %q", ticket, res.Error)
+			continue
+		}
+		if res.Result.Status != status {
+			t.Errorf("%s: expected status %d, got %d (%s)", ticket, status, res.Result.Status, res.Result.Msg)
+		}
+	}
+	if len(api.nodes) != len(names) {
+		t.Errorf("Expected %d registered nodes, got %d", len(names), len(api.nodes))
+	}
+	// 每个节点对每份测试数据最多下载一次，之后都从缓存读取
+	for _, name := range names {
+		if api.downloads[name] > 2*len(api.cases) {
+			t.Errorf("%s downloaded test data %d times, expected at most %d", name, api.downloads[name], 2*len(api.cases))
+		}
+	}
+}
+
+// TestJudgeNodeCacheBinary 验证判题节点按原始字节下载测试数据，不是合法 UTF-8 的数据也能通过校验并原样写入缓存
+func TestJudgeNodeCacheBinary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	input, output := "\x00\xff\xfe binary \x80", "\xc3\x28\n"
+	api := &fakeJudgeAPI{
+		cases:     map[string][2]string{"case_1": {input, output}},
+		downloads: make(map[string]int),
+	}
+	server := httptest.NewServer(api.router("secret"))
+	defer server.Close()
+
+	client := &node.Client{Server: server.URL + "/n1", Token: "secret"}
+	cache := &node.Cache{Dir: t.TempDir()}
+	tc := &node.TestCase{Identity: "case_1", InputHash: node.Checksum(input), OutputHash: node.Checksum(output)}
+	inputFile, outputFile, err := cache.Load(context.Background(), client, tc)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	for file, want := range map[string]string{inputFile: input, outputFile: output} {
+		b, err := os.ReadFile(file)
+		if err != nil || string(b) != want {
+			t.Errorf("cached %s = %q, %v; want %q", file, b, err, want)
+		}
+	}
+
+	// 下载失败时不会留下缓存文件
+	bad := &node.TestCase{Identity: "case_1", InputHash: node.Checksum(input), OutputHash: node.Checksum("other")}
+	if _, _, err = cache.Load(context.Background(), client, bad); err == nil {
+		t.Errorf("Load() with unknown checksum succeeded")
+	}
+	if _, err = os.Stat(filepath.Join(cache.Dir, bad.OutputHash)); !os.IsNotExist(err) {
+		t.Errorf("unknown test data was cached: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"gin_gorm_oj/service"
+	"testing"
+	"time"
+)
+
+// TestMemoryJudgeQueue 验证进程内判题队列的入队、出队和重试行为
+func TestMemoryJudgeQueue(t *testing.T) {
+	q := service.NewMemoryJudgeQueue(2)
+	background := context.Background()
+
+	// 空队列出队应在超时后返回 nil
+	job, err := q.Pop(background, 10*time.Millisecond)
+	if err != nil || job != nil {
+		t.Fatalf("Pop() on empty queue = %v, %v; want nil, nil", job, err)
+	}
+
+	// 入队后按先进先出的顺序出队
+	for _, identity := range []string{"submit_1", "submit_2"} {
+		if err = q.Push(background, &service.JudgeJob{SubmitIdentity: identity}); err != nil {
+			t.Fatalf("Push(%s) failed: %v", identity, err)
+		}
+	}
+	// 队列已满时入队应返回错误，而不是阻塞请求
+	if err = q.Push(background, &service.JudgeJob{SubmitIdentity: "submit_3"}); err == nil {
+		t.Errorf("Push() on full queue should fail")
+	}
+
+	job, err = q.Pop(background, time.Second)
+	if err != nil || job == nil || job.SubmitIdentity != "submit_1" {
+		t.Fatalf("Pop() = %v, %v; want submit_1", job, err)
+	}
+
+	// 重试的任务会保留已尝试次数并重新排队
+	job.Attempts = 1
+	if err = q.Retry(background, job); err != nil {
+		t.Fatalf("Retry() failed: %v", err)
+	}
+	job, _ = q.Pop(background, time.Second)
+	if job == nil || job.SubmitIdentity != "submit_2" {
+		t.Fatalf("Pop() = %v; want submit_2", job)
+	}
+	job, _ = q.Pop(background, time.Second)
+	if job == nil || job.SubmitIdentity != "submit_1" || job.Attempts != 1 {
+		t.Fatalf("Pop() = %+v; want retried submit_1 with 1 attempt", job)
+	}
+}
+
+// TestRedisJudgeQueueRecover 验证 Recover 只把处理中列表里的任务移回待判队列，不会重新放入数据库中待判的提交
+func TestRedisJudgeQueueRecover(t *testing.T) {
+	background := context.Background()
+	if err := rdb.Ping(background).Err(); err != nil {
+		t.Skipf("Redis 不可用: %v", err)
+	}
+	const pendingKey = "oj:judge:pending"
+	q := service.NewRedisJudgeQueue(rdb, "test-recover-"+time.Now().Format("150405.000000"))
+
+	job := &service.JudgeJob{SubmitIdentity: "submit_recover_" + time.Now().Format("150405.000000")}
+	raw, _ := json.Marshal(job)
+	defer rdb.LRem(background, pendingKey, 0, string(raw))
+	if err := q.Push(background, job); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+	// 其他任务可能同时在待判队列中，取到别的任务时放回去
+	var popped *service.JudgeJob
+	for i := 0; i < 100 && popped == nil; i++ {
+		got, err := q.Pop(background, time.Second)
+		if err != nil || got == nil {
+			t.Fatalf("Pop() = %v, %v", got, err)
+		}
+		if got.SubmitIdentity == job.SubmitIdentity {
+			popped = got
+			break
+		}
+		q.Retry(background, got)
+	}
+	if popped == nil {
+		t.Fatalf("Pop() never returned %s", job.SubmitIdentity)
+	}
+
+	if err := q.Recover(background); err != nil {
+		t.Fatalf("Recover() failed: %v", err)
+	}
+	raws, err := rdb.LRange(background, pendingKey, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, r := range raws {
+		if r == string(raw) {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("job %s in pending queue %d times after Recover(), want 1", job.SubmitIdentity, n)
+	}
+}
@@ -0,0 +1,958 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/judge/sandbox"
+	"gin_gorm_oj/storage"
+	"gin_gorm_oj/utils"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestJudgeRun 验证判题引擎的整体结果和每个测试用例的结果
+func TestJudgeRun(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	// CodeSave 把代码保存在当前目录的 code 目录下，切换到临时目录避免污染仓库
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+
+	cases := []*judge.Case{
+		{Identity: "case_1", Input: "1 2", Output: "3\n"},
+		{Identity: "case_2", Input: "5 7", Output: "12\n"},
+	}
+	testCases := []struct {
+		name        string
+		code        string
+		status      int
+		caseStatus  []int
+		failureInfo bool // 未通过的测试用例是否带有错误信息
+	}{
+		{
+			name:       "Accepted",
+			code:       "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); fmt.Println(a + b) }\n",
+			status:     define.SubmitStatusAccepted,
+			caseStatus: []int{define.SubmitStatusAccepted, define.SubmitStatusAccepted},
+		},
+		{
+			name:        "Wrong Answer On Second Case",
+			code:        "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); if a == 5 { b = 0 }; fmt.Println(a + b) }\n",
+			status:      define.SubmitStatusWrongAnswer,
+			caseStatus:  []int{define.SubmitStatusAccepted, define.SubmitStatusWrongAnswer},
+			failureInfo: true,
+		},
+		{
+			name:        "Runtime Error On Second Case",
+			code:        "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); s := []int{a}; fmt.Println(s[a-1] + b) }\n",
+			status:      define.SubmitStatusRuntimeError,
+			caseStatus:  []int{define.SubmitStatusAccepted, define.SubmitStatusRuntimeError},
+			failureInfo: true,
+		},
+		{
+			name:   "Compile Error",
+			code:   "package main\nfunc main() { undefined() }\n",
+			status: define.SubmitStatusCompileError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := utils.CodeSave([]byte(tc.code))
+			if err != nil {
+				t.Fatalf("CodeSave() failed: %v", err)
+			}
+			result := judge.Run(&judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases})
+			if result.Status != tc.status {
+				t.Fatalf("Run() status = %d (%s); want %d", result.Status, result.Msg, tc.status)
+			}
+			if len(result.Cases) != len(tc.caseStatus) {
+				t.Fatalf("Run() returned %d case results; want %d", len(result.Cases), len(tc.caseStatus))
+			}
+			for i, cr := range result.Cases {
+				if cr.Identity != cases[i].Identity || cr.Status != tc.caseStatus[i] {
+					t.Errorf("case %d = %s/%d; want %s/%d", i, cr.Identity, cr.Status, cases[i].Identity, tc.caseStatus[i])
+				}
+				if tc.failureInfo && cr.Status != define.SubmitStatusAccepted && cr.Msg == "" {
+					t.Errorf("case %s failed without a message", cr.Identity)
+				}
+			}
+		})
+	}
+}
+
+// checkerCode 是测试用的特判程序：忽略首尾空白比较答案，不一致时给 25% 的部分分
+const checkerCode = `package main
+
+import (
+	"os"
+	"strings"
+)
+
+func main() {
+	output, _ := os.ReadFile(os.Args[2])
+	answer, _ := os.ReadFile(os.Args[3])
+	if strings.TrimSpace(string(output)) == strings.TrimSpace(string(answer)) {
+		os.Exit(0)
+	}
+	os.Stderr.WriteString("partially correct")
+	os.Exit(50 + 25)
+}
+`
+
+// TestJudgeRunWithChecker 验证特判程序的判定和部分分
+func TestJudgeRunWithChecker(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	checkerPath, err := utils.CodeSave([]byte(checkerCode))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	checker := &judge.Checker{Path: checkerPath, Language: "go"}
+	cases := []*judge.Case{
+		{Identity: "case_1", Input: "1 2", Output: "3\n"},
+		{Identity: "case_2", Input: "5 7", Output: "12\n"},
+	}
+
+	testCases := []struct {
+		name   string
+		code   string
+		status int
+		score  float64
+	}{
+		{
+			name:   "Trailing Spaces Accepted",
+			code:   "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); fmt.Print(a+b, \"   \") }\n",
+			status: define.SubmitStatusAccepted,
+			score:  100,
+		},
+		{
+			name:   "Partial Score",
+			code:   "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); if a == 5 { b = 0 }; fmt.Println(a + b) }\n",
+			status: define.SubmitStatusWrongAnswer,
+			score:  62.5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := utils.CodeSave([]byte(tc.code))
+			if err != nil {
+				t.Fatalf("CodeSave() failed: %v", err)
+			}
+			result := judge.Run(&judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases, Checker: checker})
+			if result.Status != tc.status || result.Score != tc.score {
+				t.Fatalf("Run() = %d/%v (%s); want %d/%v", result.Status, result.Score, result.Msg, tc.status, tc.score)
+			}
+		})
+	}
+}
+
+// TestCompileChecker 验证保存特判程序之前的编译检查：能编译的代码通过，有语法错误的代码返回编译器输出
+func TestCompileChecker(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{name: "Valid", code: checkerCode, ok: true},
+		{name: "Syntax Error", code: "package main\nfunc main() {\n", ok: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := utils.CodeSave([]byte(tc.code))
+			if err != nil {
+				t.Fatalf("CodeSave() failed: %v", err)
+			}
+			msg, err := judge.CompileChecker(&judge.Checker{Path: path, Language: "go"})
+			if err != nil {
+				t.Fatalf("CompileChecker() error: %v", err)
+			}
+			if (msg == "") != tc.ok {
+				t.Fatalf("CompileChecker() msg = %q; want ok = %v", msg, tc.ok)
+			}
+		})
+	}
+}
+
+// interactorCode 是测试用的交互程序：从输入文件读取要猜的数，按用户的猜测回答 <、> 或 =，最多允许猜 10 次
+const interactorCode = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	var n int
+	input, _ := os.Open(os.Args[1])
+	fmt.Fscan(input, &n)
+	for i := 0; i < 10; i++ {
+		var guess int
+		if _, err := fmt.Scan(&guess); err != nil {
+			os.Stderr.WriteString("unexpected end of interaction")
+			os.Exit(1)
+		}
+		switch {
+		case guess < n:
+			fmt.Println("<")
+		case guess > n:
+			fmt.Println(">")
+		default:
+			fmt.Println("=")
+			os.Exit(0)
+		}
+	}
+	os.Stderr.WriteString("too many guesses")
+	os.Exit(1)
+}
+`
+
+// TestJudgeInteractive 验证交互题：用户程序与交互程序交叉连接，由交互程序给出判定
+func TestJudgeInteractive(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	interactorPath, err := utils.CodeSave([]byte(interactorCode))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	interactor := &judge.Checker{Path: interactorPath, Language: "go"}
+	// 保存在文件中的测试数据需要复制到交互程序能看到的目录中
+	if err = os.WriteFile("case_3.in", []byte("512"), 0644); err != nil {
+		t.Fatalf("Failed to write input file: %v", err)
+	}
+	cases := []*judge.Case{
+		{Identity: "case_1", Input: "37"},
+		{Identity: "case_2", Input: "1000"},
+		{Identity: "case_3", InputFile: "case_3.in"},
+	}
+
+	testCases := []struct {
+		name   string
+		code   string
+		status int
+	}{
+		{
+			name:   "Binary Search Accepted",
+			code:   "package main\nimport \"fmt\"\nfunc main() { lo, hi := 1, 1000; for { m := (lo + hi) / 2; fmt.Println(m); var r string; fmt.Scan(&r); switch r { case \"<\": lo = m + 1; case \">\": hi = m - 1; default: return } } }\n",
+			status: define.SubmitStatusAccepted,
+		},
+		{
+			name:   "Linear Search Wrong Answer",
+			code:   "package main\nimport \"fmt\"\nfunc main() { for i := 1; ; i++ { fmt.Println(i); var r string; fmt.Scan(&r); if r == \"=\" { return } } }\n",
+			status: define.SubmitStatusWrongAnswer,
+		},
+		{
+			name:   "Crash Runtime Error",
+			code:   "package main\nfunc main() { panic(\"boom\") }\n",
+			status: define.SubmitStatusRuntimeError,
+		},
+		{
+			name:   "Idle Time Limit Exceeded",
+			code:   "package main\nimport \"fmt\"\nfunc main() { var r string; fmt.Scan(&r) }\n", // 双方都在等待对方输出
+			status: define.SubmitStatusTimeLimitExceeded,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := utils.CodeSave([]byte(tc.code))
+			if err != nil {
+				t.Fatalf("CodeSave() failed: %v", err)
+			}
+			result := judge.Run(&judge.Task{Path: path, MaxRuntime: 500, MaxMem: 256 * 1024, TestCases: cases, Checker: interactor, Interactive: true})
+			if result.Status != tc.status {
+				t.Fatalf("Run() status = %d (%s); want %d", result.Status, result.Msg, tc.status)
+			}
+		})
+	}
+
+	// 交互题必须有交互程序。
+	path, err := utils.CodeSave([]byte("package main\nfunc main() {}\n"))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	result := judge.Run(&judge.Task{Path: path, MaxRuntime: 500, MaxMem: 256 * 1024, TestCases: cases, Interactive: true})
+	if result.Status != define.SubmitStatusSystemError {
+		t.Fatalf("Run() without interactor status = %d (%s); want %d", result.Status, result.Msg, define.SubmitStatusSystemError)
+	}
+
+	// 交互程序同样受内存限制，超出时判为系统错误，而不是按其退出码给出判定。
+	greedyPath, err := utils.CodeSave([]byte(greedyInteractorCode))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	greedy := &judge.Checker{Path: greedyPath, Language: "go"}
+	path, err = utils.CodeSave([]byte(testCases[0].code))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	result = judge.Run(&judge.Task{Path: path, MaxRuntime: 500, MaxMem: 256 * 1024, TestCases: cases[:1], Checker: greedy, Interactive: true})
+	if result.Status != define.SubmitStatusSystemError || len(result.Cases) != 1 || !strings.Contains(result.Cases[0].Msg, "内存") {
+		t.Fatalf("Run() with greedy interactor = %+v; want system error for exceeding memory limit", result)
+	}
+}
+
+// greedyInteractorCode 是测试用的交互程序：不断申请并写入内存，直到超出交互程序的内存限制
+const greedyInteractorCode = `package main
+
+import "os"
+
+func main() {
+	var blocks [][]byte
+	for i := 0; i < 64; i++ {
+		b := make([]byte, 32<<20)
+		for j := range b {
+			b[j] = 1
+		}
+		blocks = append(blocks, b)
+	}
+	os.Exit(len(blocks) - 64)
+}
+`
+
+// twoSumHarness 是测试用的 Go 驱动模板：读取数组和目标值，调用用户实现的 twoSum 并输出结果
+const twoSumHarness = `package main
+
+import "fmt"
+
+{{USER_CODE}}
+
+func main() {
+	var n, target int
+	fmt.Scan(&n)
+	nums := make([]int, n)
+	for i := range nums {
+		fmt.Scan(&nums[i])
+	}
+	fmt.Scan(&target)
+	fmt.Println(twoSum(nums, target))
+}
+`
+
+// TestJudgeHarness 验证函数式问题：用户代码填入驱动模板后编译，用户的代码文件保持不变
+func TestJudgeHarness(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	cases := []*judge.Case{
+		{Identity: "case_1", Input: "4\n2 7 11 15\n9", Output: "[0 1]\n"},
+		{Identity: "case_2", Input: "3\n3 2 4\n6", Output: "[1 2]\n"},
+	}
+
+	testCases := []struct {
+		name   string
+		code   string
+		status int
+	}{
+		{
+			name:   "Accepted",
+			code:   "func twoSum(nums []int, target int) []int {\n\tseen := map[int]int{}\n\tfor i, x := range nums {\n\t\tif j, ok := seen[target-x]; ok {\n\t\t\treturn []int{j, i}\n\t\t}\n\t\tseen[x] = i\n\t}\n\treturn nil\n}\n",
+			status: define.SubmitStatusAccepted,
+		},
+		{
+			name:   "Wrong Answer",
+			code:   "func twoSum(nums []int, target int) []int { return []int{0, 1} }\n",
+			status: define.SubmitStatusWrongAnswer,
+		},
+		{
+			name:   "Whole Program Compile Error",
+			code:   "package main\nfunc main() {}\n",
+			status: define.SubmitStatusCompileError,
+		},
+		{
+			name:   "Import Not Allowed",
+			code:   "import \"os\"\nfunc twoSum(nums []int, target int) []int { os.Exit(0); return nil }\n",
+			status: define.SubmitStatusInvalidCode,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := utils.CodeSave([]byte(tc.code))
+			if err != nil {
+				t.Fatalf("CodeSave() failed: %v", err)
+			}
+			task := &judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases, Harness: twoSumHarness}
+			result := judge.Run(task)
+			if result.Status != tc.status {
+				t.Fatalf("Run() status = %d (%s); want %d", result.Status, result.Msg, tc.status)
+			}
+			// 重试时需要再次合并，用户的代码文件不能被改写。
+			if code, _ := os.ReadFile(path); string(code) != tc.code {
+				t.Fatalf("user code file was modified: %q", code)
+			}
+		})
+	}
+
+	path, err := utils.CodeSave([]byte(testCases[0].code))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	res := judge.RunCode(&judge.RunTask{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, Input: cases[0].Input, Harness: twoSumHarness})
+	if res.Status != define.SubmitStatusAccepted || res.Stdout != cases[0].Output {
+		t.Fatalf("RunCode() = %d %q (%s); want %d %q", res.Status, res.Stdout, res.Msg, define.SubmitStatusAccepted, cases[0].Output)
+	}
+}
+
+// TestJudgeCompareModes 验证各种输出比较方式的判定
+func TestJudgeCompareModes(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	path, err := utils.CodeSave([]byte("package main\nimport \"fmt\"\nfunc main() { fmt.Print(\"Yes 3.0000001 \\n\") }\n"))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+
+	testCases := []struct {
+		mode   string
+		output string
+		status int
+	}{
+		{judge.CompareExact, "Yes 3.0000001\n", define.SubmitStatusPresentationError},
+		{judge.CompareTrailing, "Yes 3.0000001\n", define.SubmitStatusAccepted},
+		{judge.CompareToken, "yes 3.0000001", define.SubmitStatusWrongAnswer},
+		{judge.CompareTokenCI, "yes 3.0000001", define.SubmitStatusAccepted},
+		{judge.CompareFloat, "Yes 3", define.SubmitStatusAccepted},
+		{judge.CompareFloat, "Yes 3.1", define.SubmitStatusWrongAnswer},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.mode+"/"+tc.output, func(t *testing.T) {
+			cases := []*judge.Case{{Identity: "case_1", Output: tc.output}}
+			result := judge.Run(&judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases, CompareMode: tc.mode})
+			if result.Status != tc.status {
+				t.Fatalf("Run() status = %d (%s); want %d", result.Status, result.Msg, tc.status)
+			}
+		})
+	}
+}
+
+// TestJudgeModes 验证最终结果取测试用例顺序上第一个未通过的判定，以及 first_fail 模式取消其余测试用例
+func TestJudgeModes(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	// 第一个测试用例较慢且答案错误，第二个测试用例立即运行错误，第三个测试用例很慢但答案正确
+	path, err := utils.CodeSave([]byte(`package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	var a, b int
+	fmt.Scan(&a, &b)
+	switch a {
+	case 1:
+		time.Sleep(300 * time.Millisecond)
+		b = 0
+	case 2:
+		panic("boom")
+	case 3:
+		time.Sleep(1500 * time.Millisecond)
+	}
+	fmt.Println(a + b)
+}
+`))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	cases := []*judge.Case{
+		{Identity: "case_1", Input: "1 2", Output: "3\n"},
+		{Identity: "case_2", Input: "2 3", Output: "5\n"},
+		{Identity: "case_3", Input: "3 4", Output: "7\n"},
+	}
+	policy := &utils.CodePolicy{AllowImports: []string{"fmt", "time"}}
+
+	testCases := []struct {
+		mode      string
+		cases     map[string]int // 应当包含的测试用例结果
+		cancelled string         // 应当被取消的测试用例
+		score     float64
+	}{
+		{
+			// 第三个测试用例在第一个测试用例未通过时还在运行或尚未启动
+			mode:      judge.JudgeModeFirstFail,
+			cases:     map[string]int{"case_1": define.SubmitStatusWrongAnswer},
+			cancelled: "case_3",
+		},
+		{
+			mode: judge.JudgeModeRunAll,
+			cases: map[string]int{
+				"case_1": define.SubmitStatusWrongAnswer,
+				"case_2": define.SubmitStatusRuntimeError,
+				"case_3": define.SubmitStatusAccepted,
+			},
+			score: 100.0 / 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			result := judge.Run(&judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases, Policy: policy, Mode: tc.mode})
+			if result.Status != define.SubmitStatusWrongAnswer || result.Msg != "答案错误" {
+				t.Fatalf("Run() = %d (%s); want %d", result.Status, result.Msg, define.SubmitStatusWrongAnswer)
+			}
+			got := make(map[string]int)
+			for _, cr := range result.Cases {
+				got[cr.Identity] = cr.Status
+			}
+			for id, status := range tc.cases {
+				if got[id] != status {
+					t.Errorf("case %s status = %d; want %d", id, got[id], status)
+				}
+			}
+			if _, ok := got[tc.cancelled]; ok {
+				t.Errorf("case %s was not cancelled: %v", tc.cancelled, got)
+			}
+			if tc.cancelled == "" && result.Score != tc.score {
+				t.Errorf("Run() score = %v; want %v", result.Score, tc.score)
+			}
+		})
+	}
+}
+
+// TestJudgeProgress 验证判题进度按编译、运行的顺序报告，已完成的测试用例个数单调递增
+func TestJudgeProgress(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	path, err := utils.CodeSave([]byte("package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); fmt.Println(a + b) }\n"))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	cases := []*judge.Case{
+		{Identity: "case_1", Input: "1 2", Output: "3\n"},
+		{Identity: "case_2", Input: "5 7", Output: "12\n"},
+		{Identity: "case_3", Input: "0 0", Output: "0\n"},
+	}
+	var stages []string
+	var dones []int
+	result := judge.Run(&judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases,
+		Progress: func(stage string, done, total int) {
+			if total != len(cases) {
+				t.Errorf("progress total = %d; want %d", total, len(cases))
+			}
+			stages = append(stages, stage)
+			dones = append(dones, done)
+		}})
+	if result.Status != define.SubmitStatusAccepted {
+		t.Fatalf("Run() status = %d (%s); want %d", result.Status, result.Msg, define.SubmitStatusAccepted)
+	}
+	wantStages := []string{judge.ProgressCompiling, judge.ProgressRunning, judge.ProgressRunning, judge.ProgressRunning, judge.ProgressRunning}
+	wantDones := []int{0, 0, 1, 2, 3}
+	if fmt.Sprint(stages) != fmt.Sprint(wantStages) || fmt.Sprint(dones) != fmt.Sprint(wantDones) {
+		t.Errorf("progress = %v %v; want %v %v", stages, dones, wantStages, wantDones)
+	}
+}
+
+// TestJudgeSubtasks 验证按子任务计分和子任务之间的依赖
+func TestJudgeSubtasks(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	// 输入的第一个数为 5 时输出错误答案
+	path, err := utils.CodeSave([]byte("package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); if a == 5 { b = 0 }; fmt.Println(a + b) }\n"))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	cases := []*judge.Case{
+		{Identity: "case_1", Input: "1 2", Output: "3\n", Subtask: 1},
+		{Identity: "case_2", Input: "2 2", Output: "4\n", Subtask: 1},
+		{Identity: "case_3", Input: "5 7", Output: "12\n", Subtask: 2},
+		{Identity: "case_4", Input: "3 4", Output: "7\n", Subtask: 3},
+		{Identity: "case_5", Input: "6 4", Output: "10\n", Subtask: 4},
+	}
+	subtasks := []*judge.Subtask{
+		{Number: 1, Score: 20},
+		{Number: 2, Score: 30, Depends: []int{1}},
+		{Number: 3, Score: 10, Depends: []int{2}},
+		{Number: 4, Score: 40, Depends: []int{1}},
+	}
+	result := judge.Run(&judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases, Subtasks: subtasks})
+	if result.Status != define.SubmitStatusWrongAnswer || result.Score != 60 {
+		t.Fatalf("Run() = %d/%v (%s); want %d/60", result.Status, result.Score, result.Msg, define.SubmitStatusWrongAnswer)
+	}
+	// 子任务 3 的测试用例全部通过，但依赖的子任务 2 未通过，因此不得分
+	want := []float64{20, 0, 0, 40}
+	if len(result.Subtasks) != len(want) {
+		t.Fatalf("Run() returned %d subtask results; want %d", len(result.Subtasks), len(want))
+	}
+	for i, sr := range result.Subtasks {
+		if sr.Number != subtasks[i].Number || sr.Score != want[i] {
+			t.Errorf("subtask %d score = %v; want %v", sr.Number, sr.Score, want[i])
+		}
+	}
+}
+
+// TestJudgeRunCode 验证自定义输入运行返回程序的输出和退出码
+func TestJudgeRunCode(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		code     string
+		status   int
+		msg      string // 非空时检查提示信息
+		stdout   string
+		exitCode int
+	}{
+		{
+			name:   "Normal Exit",
+			code:   "package main\nimport \"fmt\"\nfunc main() { var a, b int; fmt.Scan(&a, &b); fmt.Println(a + b) }\n",
+			status: define.SubmitStatusAccepted,
+			stdout: "7\n",
+		},
+		{
+			name:     "Runtime Error",
+			code:     "package main\nimport \"fmt\"\nfunc main() { fmt.Print(\"partial\"); panic(\"boom\") }\n",
+			status:   define.SubmitStatusRuntimeError,
+			msg:      "运行错误：panic: boom",
+			stdout:   "partial",
+			exitCode: 2,
+		},
+		{
+			name:     "Output Limit Exceeded",
+			code:     "package main\nimport \"fmt\"\nfunc main() { for { fmt.Println(\"y\") } }\n",
+			status:   define.SubmitStatusOutputLimitExceeded,
+			stdout:   strings.Repeat("y\n", 512),
+			exitCode: -1,
+		},
+		{
+			name:     "Compile Error",
+			code:     "package main\nfunc main() { undefined() }\n",
+			status:   define.SubmitStatusCompileError,
+			exitCode: -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := utils.CodeSave([]byte(tc.code))
+			if err != nil {
+				t.Fatalf("CodeSave() failed: %v", err)
+			}
+			result := judge.RunCode(&judge.RunTask{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, Input: "3 4", OutputLimit: 1024})
+			if result.Status != tc.status || result.Stdout != tc.stdout || result.ExitCode != tc.exitCode {
+				t.Fatalf("RunCode() = %d/%q/%d (%s); want %d/%q/%d",
+					result.Status, result.Stdout, result.ExitCode, result.Msg, tc.status, tc.stdout, tc.exitCode)
+			}
+			if tc.msg != "" && result.Msg != tc.msg {
+				t.Errorf("RunCode() msg = %q; want %q", result.Msg, tc.msg)
+			}
+		})
+	}
+}
+
+// TestJudgeCaseFiles 验证保存在文件中的测试数据：输入文件直接作为标准输入，特判程序直接读取输入和答案文件
+func TestJudgeCaseFiles(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+
+	// 输入是 2 万个整数，输出是它们的和
+	var input strings.Builder
+	n, sum := 20000, 0
+	fmt.Fprintln(&input, n)
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&input, "%d ", i)
+		sum += i
+	}
+	input.WriteString("\n")
+	store := &storage.Local{Dir: "data"}
+	ctx := context.Background()
+	inHash, _, err := store.Save(ctx, strings.NewReader(input.String()))
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	outHash, _, err := store.Save(ctx, strings.NewReader(fmt.Sprintln(sum)))
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	inPath, _ := store.Path(ctx, inHash)
+	outPath, _ := store.Path(ctx, outHash)
+	cases := []*judge.Case{{Identity: "case_1", InputFile: inPath, OutputFile: outPath}}
+
+	code := "package main\nimport \"fmt\"\nfunc main() { var n, s, x int; fmt.Scan(&n); for i := 0; i < n; i++ { fmt.Scan(&x); s += x }; fmt.Println(s) }\n"
+	wrong := "package main\nimport \"fmt\"\nfunc main() { var n int; fmt.Scan(&n); fmt.Println(n) }\n"
+	checkerPath, err := utils.CodeSave([]byte(checkerCode))
+	if err != nil {
+		t.Fatalf("CodeSave() failed: %v", err)
+	}
+	checker := &judge.Checker{Path: checkerPath, Language: "go"}
+
+	testCases := []struct {
+		name    string
+		code    string
+		checker *judge.Checker
+		status  int
+	}{
+		{"Accepted", code, nil, define.SubmitStatusAccepted},
+		{"Wrong Answer", wrong, nil, define.SubmitStatusWrongAnswer},
+		{"Checker Accepted", code, checker, define.SubmitStatusAccepted},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := utils.CodeSave([]byte(tc.code))
+			if err != nil {
+				t.Fatalf("CodeSave() failed: %v", err)
+			}
+			result := judge.Run(&judge.Task{Path: path, MaxRuntime: 2000, MaxMem: 256 * 1024, TestCases: cases, Checker: tc.checker})
+			if result.Status != tc.status {
+				t.Fatalf("Run() = %d (%s); want %d", result.Status, result.Msg, tc.status)
+			}
+		})
+	}
+	if b, _ := os.ReadFile(inPath); string(b) != input.String() {
+		t.Errorf("input file was modified")
+	}
+}
+
+// TestJudgeMemory 验证峰值内存只统计用户程序本身，不包含判题进程的内存，且超过内存限制的分配会被阻止
+// 分别在不做隔离和沙箱两种执行后端下运行，主机不支持沙箱时只测试前者
+func TestJudgeMemory(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	// 先让判题进程自己占用 300MB 内存，用户程序的统计不应受影响
+	ballast := make([]byte, 300<<20)
+	for i := 0; i < len(ballast); i += 4096 {
+		ballast[i] = 1
+	}
+	defer runtime.KeepAlive(ballast)
+
+	const maxMem = 64 * 1024
+	small := "#include <stdio.h>\n#include <stdlib.h>\n" +
+		"int main(void) { volatile char *p = malloc(1 << 20); for (int i = 0; i < 1 << 20; i += 4096) p[i] = 1; printf(\"%d\\n\", p[1 << 19]); return 0; }\n"
+	large := "#include <stdio.h>\n#include <stdlib.h>\n" +
+		"int main(void) { volatile char *p = malloc(100 << 20); if (!p) return 1; for (int i = 0; i < 100 << 20; i += 4096) p[i] = 1; printf(\"%d\\n\", p[100 << 19]); return 0; }\n"
+	// 不断分配内存直到失败，内存限制必须让它在时间限制之内停下来
+	unbounded := "#include <stdio.h>\n#include <stdlib.h>\n" +
+		"int main(void) { for (;;) { volatile char *p = malloc(16 << 20); if (!p) return 1; for (int i = 0; i < 16 << 20; i += 4096) p[i] = 1; } }\n"
+	cases := []*judge.Case{{Identity: "case_1", Output: "1\n"}}
+
+	executors := map[string]judge.Executor{"plain": judge.PlainExecutor{}}
+	if sandbox.Available() == nil {
+		executors["sandbox"] = judge.SandboxExecutor{Limits: sandbox.DefaultLimits}
+	}
+	defer judge.SetExecutor(judge.SandboxExecutor{Limits: sandbox.DefaultLimits})
+	for name, executor := range executors {
+		judge.SetExecutor(executor)
+		t.Run(name, func(t *testing.T) {
+			testCases := []struct {
+				name   string
+				code   string
+				status int
+				maxMem int64 // 统计到的峰值内存的上限（KB）
+			}{
+				{"Small Program", small, define.SubmitStatusAccepted, 32 * 1024},
+				{"Exceeds Limit", large, define.SubmitStatusMemoryLimitExceeded, 256 * 1024},
+				{"Unbounded Allocation", unbounded, define.SubmitStatusMemoryLimitExceeded, 256 * 1024},
+			}
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					path, err := utils.CodeSaveAs([]byte(tc.code), "main.c")
+					if err != nil {
+						t.Fatalf("CodeSaveAs() failed: %v", err)
+					}
+					result := judge.Run(&judge.Task{Path: path, Language: "c", MaxRuntime: 5000, MaxMem: maxMem, TestCases: cases})
+					if result.Status != tc.status {
+						t.Fatalf("Run() status = %d (%s); want %d", result.Status, result.Msg, tc.status)
+					}
+					if result.MemUsed <= 0 || result.MemUsed > tc.maxMem {
+						t.Errorf("Run() MemUsed = %dKB; want between 0 and %dKB", result.MemUsed, tc.maxMem)
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestJudgeSandboxFiles 验证沙箱中的编译器和用户程序都读不到工作目录之外的文件，例如配置文件和测试数据
+func TestJudgeSandboxFiles(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	if err := sandbox.Available(); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	root := t.TempDir()
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	// 与代码目录位于同一个目录下的预期输出文件
+	secret := root + "/secret.out"
+	if err := os.WriteFile(secret, []byte("leaked\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	code := "#include <stdio.h>\n" +
+		"int main(void) { const char *paths[] = {\"" + secret + "\", \"/etc/passwd\", \"config/config.ini\"};\n" +
+		"for (int i = 0; i < 3; i++) { FILE *f = fopen(paths[i], \"r\"); if (f) { printf(\"leaked %s\\n\", paths[i]); return 0; } }\n" +
+		"puts(\"blocked\"); return 0; }\n"
+	path, err := utils.CodeSaveAs([]byte(code), "main.c")
+	if err != nil {
+		t.Fatalf("CodeSaveAs() failed: %v", err)
+	}
+	cases := []*judge.Case{{Identity: "case_1", Output: "blocked\n"}}
+	judge.SetExecutor(judge.SandboxExecutor{Limits: sandbox.DefaultLimits})
+	result := judge.Run(&judge.Task{Path: path, Language: "c", MaxRuntime: 2000, MaxMem: 64 * 1024, TestCases: cases})
+	if result.Status != define.SubmitStatusAccepted {
+		t.Errorf("Run() status = %d (%s); want %d", result.Status, result.Msg, define.SubmitStatusAccepted)
+	}
+
+	// 编译错误信息会展示给用户，编译器同样不能包含宿主机上的文件
+	path, err = utils.CodeSaveAs([]byte("#include \""+secret+"\"\nint main(void) { return 0; }\n"), "main.c")
+	if err != nil {
+		t.Fatalf("CodeSaveAs() failed: %v", err)
+	}
+	result = judge.Run(&judge.Task{Path: path, Language: "c", MaxRuntime: 2000, MaxMem: 64 * 1024, TestCases: cases})
+	if result.Status != define.SubmitStatusCompileError || strings.Contains(result.Msg, "leaked") {
+		t.Errorf("Run() = %d (%s); want compile error without the file content", result.Status, result.Msg)
+	}
+}
+
+// TestJudgeCompileErrorSize 验证大量编译错误被截断，编译错误信息能够保存到提交记录的 msg（MySQL text，最多 64KB）中
+func TestJudgeCompileErrorSize(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Mkdir("code", 0777); err != nil {
+		t.Fatalf("Failed to create code directory: %v", err)
+	}
+	// 3000 个未声明的变量，编译器输出远超 64KB
+	var code strings.Builder
+	code.WriteString("int main(void) {\n")
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&code, "undefined_variable_%d = %d;\n", i, i)
+	}
+	code.WriteString("return 0; }\n")
+	path, err := utils.CodeSaveAs([]byte(code.String()), "main.c")
+	if err != nil {
+		t.Fatalf("CodeSaveAs() failed: %v", err)
+	}
+	cases := []*judge.Case{{Identity: "case_1", Output: ""}}
+	result := judge.Run(&judge.Task{Path: path, Language: "c", MaxRuntime: 2000, MaxMem: 64 * 1024, TestCases: cases})
+	if result.Status != define.SubmitStatusCompileError {
+		t.Fatalf("Run() status = %d; want %d", result.Status, define.SubmitStatusCompileError)
+	}
+	if len(result.Msg) == 0 || len(result.Msg) > 32<<10+3 {
+		t.Errorf("Run() msg has %d bytes; want a non-empty message of at most 32KB", len(result.Msg))
+	}
+	res := judge.RunCode(&judge.RunTask{Path: path, Language: "c", MaxRuntime: 2000, MaxMem: 64 * 1024})
+	if res.Status != define.SubmitStatusCompileError || len(res.Msg) > 32<<10+3 {
+		t.Errorf("RunCode() = %d with %d bytes of msg; want a compile error of at most 32KB", res.Status, len(res.Msg))
+	}
+}
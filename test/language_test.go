@@ -0,0 +1,51 @@
+package test
+
+import (
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/models"
+	"testing"
+)
+
+// TestLanguageRegistry 验证内置语言的注册和默认语言
+func TestLanguageRegistry(t *testing.T) {
+	sourceFiles := map[string]string{
+		"go":      "main.go",
+		"c":       "main.c",
+		"cpp":     "main.cpp",
+		"python3": "main.py",
+		"java":    "Main.java",
+	}
+	for name, sourceFile := range sourceFiles {
+		lang, ok := judge.GetLanguage(name)
+		if !ok {
+			t.Fatalf("GetLanguage(%q) not found", name)
+		}
+		if lang.SourceFile() != sourceFile {
+			t.Errorf("GetLanguage(%q).SourceFile() = %q; want %q", name, lang.SourceFile(), sourceFile)
+		}
+		if len(lang.RunCommand("/code")) == 0 {
+			t.Errorf("GetLanguage(%q).RunCommand() is empty", name)
+		}
+	}
+
+	// 未指定语言时使用默认语言，兼容旧的提交记录
+	lang, ok := judge.GetLanguage("")
+	if !ok || lang.Name() != judge.DefaultLanguage {
+		t.Errorf("GetLanguage(\"\") = %v, %v; want %s", lang, ok, judge.DefaultLanguage)
+	}
+	if _, ok = judge.GetLanguage("brainfuck"); ok {
+		t.Errorf("GetLanguage(\"brainfuck\") should not be found")
+	}
+}
+
+// TestProblemAllowLanguage 验证问题允许提交的语言
+func TestProblemAllowLanguage(t *testing.T) {
+	pb := &models.ProblemBasic{}
+	if !pb.AllowLanguage("python3") {
+		t.Errorf("empty Languages should allow every language")
+	}
+	pb.Languages = "c,cpp"
+	if !pb.AllowLanguage("cpp") || pb.AllowLanguage("go") || pb.AllowLanguage("c,cpp") {
+		t.Errorf("AllowLanguage() with Languages %q returned wrong result", pb.Languages)
+	}
+}
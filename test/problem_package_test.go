@@ -0,0 +1,237 @@
+package test
+
+import (
+	"bytes"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/judge"
+	"gin_gorm_oj/problempkg"
+	"strings"
+	"testing"
+)
+
+// readPackageFile 读取问题包中的文件内容
+func readPackageFile(t *testing.T, p *problempkg.Package, name string) string {
+	b, err := p.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile(%s) failed: %v", name, err)
+	}
+	return string(b)
+}
+
+// TestNativePackage 验证导出的问题包可以原样读回，manifest 引用的文件必须存在
+func TestNativePackage(t *testing.T) {
+	var buf bytes.Buffer
+	pw := problempkg.NewWriter(&buf)
+	files := map[string]string{"data/1.in": "1 2\n", "data/1.out": "3\n", "checker/main.cpp": "int main() {}\n"}
+	for _, name := range []string{"data/1.in", "data/1.out", "checker/main.cpp"} {
+		w, err := pw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%s) failed: %v", name, err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if _, err := pw.Create("../evil"); err == nil {
+		t.Errorf("Create() accepted a path outside the package")
+	}
+	m := &problempkg.Manifest{
+		Identity: "p-1", Title: "A+B", Content: "求和", MaxRuntime: 1000, MaxMem: 65536,
+		CompareMode: judge.CompareToken, Categories: []string{"入门"},
+		Subtasks:  []*define.Subtask{{Number: 1, Score: 100}},
+		Checker:   &problempkg.Checker{Language: "cpp", File: "checker/main.cpp"},
+		TestCases: []*problempkg.TestCase{{Input: "data/1.in", Output: "data/1.out", Subtask: 1, IsSample: true, Explanation: "1+2=3"}},
+	}
+	if err := pw.Close(m); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	pkgs, err := problempkg.Read(r, r.Size(), problempkg.FormatAuto)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Source != problempkg.FormatNative {
+		t.Fatalf("Read() = %+v, want one native package", pkgs)
+	}
+	got := pkgs[0].Manifest
+	if got.Identity != "p-1" || got.MaxMem != 65536 || got.Categories[0] != "入门" || got.Checker.Language != "cpp" ||
+		len(got.TestCases) != 1 || got.TestCases[0].Explanation != "1+2=3" || got.Subtasks[0].Score != 100 {
+		t.Errorf("manifest = %+v", got)
+	}
+	if s := readPackageFile(t, pkgs[0], "data/1.out"); s != "3\n" {
+		t.Errorf("data/1.out = %q", s)
+	}
+
+	// manifest 引用了不存在的文件
+	r = makeZip(t, map[string]string{
+		problempkg.ManifestName: `{"format":"gin_gorm_oj/problem","version":1,"title":"x","test_cases":[{"input":"1.in","output":"1.out"}]}`,
+		"1.in":                  "1",
+	})
+	if _, err = problempkg.Read(r, r.Size(), problempkg.FormatAuto); err == nil {
+		t.Errorf("Read() accepted a manifest referencing a missing file")
+	}
+	// 较新版本的问题包
+	r = makeZip(t, map[string]string{problempkg.ManifestName: `{"format":"gin_gorm_oj/problem","version":99}`})
+	if _, err = problempkg.Read(r, r.Size(), problempkg.FormatAuto); err == nil {
+		t.Errorf("Read() accepted a newer package version")
+	}
+}
+
+const fpsXML = `<?xml version="1.0" encoding="UTF-8"?>
+<fps version="1.2">
+  <item>
+    <title><![CDATA[A+B Problem]]></title>
+    <time_limit unit="s"><![CDATA[1]]></time_limit>
+    <memory_limit unit="mb"><![CDATA[128]]></memory_limit>
+    <description><![CDATA[<p>计算 a+b</p>]]></description>
+    <input><![CDATA[<p>两个整数</p>]]></input>
+    <output><![CDATA[<p>一个整数</p>]]></output>
+    <sample_input><![CDATA[1 2]]></sample_input>
+    <sample_output><![CDATA[3]]></sample_output>
+    <test_input><![CDATA[5 7]]></test_input>
+    <test_output><![CDATA[12]]></test_output>
+    <test_input><![CDATA[0 0]]></test_input>
+    <test_output><![CDATA[0]]></test_output>
+    <spj language="C++"><![CDATA[int main() { return 0; }]]></spj>
+  </item>
+  <item>
+    <title>Echo</title>
+    <time_limit unit="ms">500</time_limit>
+    <memory_limit unit="kb">2048</memory_limit>
+    <description>原样输出</description>
+    <sample_input>x</sample_input>
+    <sample_output>x</sample_output>
+  </item>
+</fps>`
+
+// TestFPSPackage 验证 FPS 文件的转换：时间内存单位、题面拼接、样例在前，特判程序不导入并给出警告
+func TestFPSPackage(t *testing.T) {
+	r := strings.NewReader(fpsXML)
+	pkgs, err := problempkg.Read(r, r.Size(), problempkg.FormatAuto)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("Read() returned %d packages, want 2", len(pkgs))
+	}
+	m := pkgs[0].Manifest
+	if m.Title != "A+B Problem" || m.MaxRuntime != 1000 || m.MaxMem != 128*1024 || m.Checker != nil {
+		t.Errorf("manifest = %+v", m)
+	}
+	if !strings.Contains(m.Content, "<p>计算 a+b</p>") || !strings.Contains(m.Content, "<h3>输入</h3>") {
+		t.Errorf("content = %q", m.Content)
+	}
+	if len(m.TestCases) != 3 || !m.TestCases[0].IsSample || m.TestCases[1].IsSample {
+		t.Fatalf("test cases = %+v", m.TestCases)
+	}
+	if s := readPackageFile(t, pkgs[0], m.TestCases[1].Input); s != "5 7" {
+		t.Errorf("test 2 input = %q", s)
+	}
+	if len(pkgs[0].Warnings) != 1 {
+		t.Errorf("warnings = %v, want a warning about the special judge", pkgs[0].Warnings)
+	}
+	if m := pkgs[1].Manifest; m.MaxRuntime != 500 || m.MaxMem != 2048 || len(m.TestCases) != 1 {
+		t.Errorf("second manifest = %+v", m)
+	}
+
+	// 打包在 zip 中的 FPS 文件
+	zr := makeZip(t, map[string]string{"export/problems.xml": fpsXML})
+	if pkgs, err = problempkg.Read(zr, zr.Size(), problempkg.FormatAuto); err != nil || len(pkgs) != 2 {
+		t.Errorf("Read() of zipped FPS = %d packages, %v", len(pkgs), err)
+	}
+	bad := strings.Replace(fpsXML, `<test_output><![CDATA[0]]></test_output>`, "", 1)
+	r = strings.NewReader(bad)
+	if _, err = problempkg.Read(r, r.Size(), problempkg.FormatAuto); err == nil {
+		t.Errorf("Read() accepted unpaired test data")
+	}
+}
+
+const polygonXML = `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<problem revision="3" short-name="a-plus-b">
+  <names>
+    <name language="english" value="A + B"/>
+    <name language="chinese" value="加法"/>
+  </names>
+  <statements>
+    <statement charset="UTF-8" language="chinese" path="statements/chinese/problem.tex" type="application/x-tex"/>
+  </statements>
+  <judging input-file="" output-file="">
+    <testset name="tests">
+      <time-limit>2000</time-limit>
+      <memory-limit>268435456</memory-limit>
+      <test-count>2</test-count>
+      <input-path-pattern>tests/%02d</input-path-pattern>
+      <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+      <tests>
+        <test method="manual" sample="true"/>
+        <test cmd="gen 1" method="generated"/>
+      </tests>
+    </testset>
+  </judging>
+  <assets>
+    <checker name="std::rcmp6.cpp" type="testlib">
+      <source path="files/check.cpp" type="cpp.g++17"/>
+    </checker>
+  </assets>
+</problem>`
+
+// TestPolygonPackage 验证 Polygon 完整包的转换：题面、限制、样例标记、标准特判程序和自定义特判程序
+func TestPolygonPackage(t *testing.T) {
+	files := map[string]string{
+		"a-plus-b/problem.xml":                             polygonXML,
+		"a-plus-b/statement-sections/chinese/legend.tex":   "计算 $a+b$。",
+		"a-plus-b/statement-sections/chinese/input.tex":    "两个实数。",
+		"a-plus-b/statement-sections/chinese/output.tex":   "它们的和。",
+		"a-plus-b/statement-sections/english/legend.tex":   "Compute $a+b$.",
+		"a-plus-b/tests/01":                                "1 2\n",
+		"a-plus-b/tests/01.a":                              "3\n",
+		"a-plus-b/tests/02":                                "0.5 0.25\n",
+		"a-plus-b/tests/02.a":                              "0.75\n",
+		"a-plus-b/files/check.cpp":                         "#include \"testlib.h\"\n",
+		"a-plus-b/files/testlib.h":                         "",
+		"a-plus-b/statements/chinese/problem.tex":          "unused",
+		"a-plus-b/statement-sections/chinese/example.01":   "1 2\n",
+		"a-plus-b/statement-sections/chinese/example.01.a": "3\n",
+	}
+	r := makeZip(t, files)
+	pkgs, err := problempkg.Read(r, r.Size(), problempkg.FormatAuto)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	p := pkgs[0]
+	m := p.Manifest
+	if p.Source != problempkg.FormatPolygon || m.Title != "加法" || m.MaxRuntime != 2000 || m.MaxMem != 262144 {
+		t.Errorf("manifest = %+v", m)
+	}
+	if !strings.HasPrefix(m.Content, "计算 $a+b$。") || !strings.Contains(m.Content, "### 输出\n\n它们的和。") {
+		t.Errorf("content = %q", m.Content)
+	}
+	if m.CompareMode != judge.CompareFloat || m.FloatEpsilon != 1e-6 || m.Checker != nil {
+		t.Errorf("standard checker converted to %s/%g, checker %+v", m.CompareMode, m.FloatEpsilon, m.Checker)
+	}
+	if len(m.TestCases) != 2 || !m.TestCases[0].IsSample || m.TestCases[1].IsSample {
+		t.Fatalf("test cases = %+v", m.TestCases)
+	}
+	if s := readPackageFile(t, p, m.TestCases[1].Output); s != "0.75\n" {
+		t.Errorf("test 2 answer = %q", s)
+	}
+
+	// 自定义特判程序按源代码导入
+	files["a-plus-b/problem.xml"] = strings.Replace(polygonXML, "std::rcmp6.cpp", "check.cpp", 1)
+	r = makeZip(t, files)
+	if pkgs, err = problempkg.Read(r, r.Size(), problempkg.FormatPolygon); err != nil {
+		t.Fatalf("Read() with custom checker failed: %v", err)
+	}
+	if c := pkgs[0].Manifest.Checker; c == nil || c.Language != "cpp" || c.File != "files/check.cpp" {
+		t.Errorf("checker = %+v", c)
+	}
+	if len(pkgs[0].Warnings) == 0 {
+		t.Errorf("expected a warning about testlib.h")
+	}
+
+	// 没有生成测试数据的包
+	delete(files, "a-plus-b/tests/02.a")
+	r = makeZip(t, files)
+	if _, err = problempkg.Read(r, r.Size(), problempkg.FormatAuto); err == nil {
+		t.Errorf("Read() accepted a package without answer files")
+	}
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"encoding/json"
+	"gin_gorm_oj/middlewares"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRateLimitMiddleware 验证按用户限流：超过次数后拒绝请求，不同用户分别计数，窗口结束后恢复
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// 模拟 AuthUserCheck，从请求头中取用户标识
+	router.Use(func(c *gin.Context) {
+		c.Set("user_claims", &middlewares.UserClaims{Identity: c.GetHeader("X-User")})
+	})
+	router.Use(middlewares.RateLimit(middlewares.NewMemoryRateLimiter(2, 200*time.Millisecond)))
+	router.POST("/run", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"code": 200})
+	})
+
+	// request 发送一次请求，返回响应中的 code
+	request := func(user string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/run", nil)
+		req.Header.Set("X-User", user)
+		router.ServeHTTP(w, req)
+		var body struct {
+			Code int `json:"code"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to parse response %q: %v", w.Body.String(), err)
+		}
+		return body.Code
+	}
+
+	for i, want := range []int{200, 200, http.StatusTooManyRequests} {
+		if got := request("user_1"); got != want {
+			t.Fatalf("request %d of user_1 code = %d; want %d", i+1, got, want)
+		}
+	}
+	if got := request("user_2"); got != 200 {
+		t.Fatalf("request of user_2 code = %d; want 200", got)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if got := request("user_1"); got != 200 {
+		t.Fatalf("request of user_1 after window code = %d; want 200", got)
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"gin_gorm_oj/judge/sandbox"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSandbox 验证沙箱的只读根目录、私有 /tmp、独立的 pid 命名空间（用户程序的父进程是命名空间中的 1 号监控进程），
+// 以及工作目录之外的宿主机文件不可见
+// 主机不支持非特权 user 命名空间时跳过
+func TestSandbox(t *testing.T) {
+	if err := sandbox.Available(); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	// secret 与工作目录位于同一个临时目录下，模拟配置文件和测试数据
+	root := t.TempDir()
+	secret := filepath.Join(root, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	dir := filepath.Join(root, "work")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "input.txt"), []byte("input"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	script := `echo $PPID; touch /oj_sandbox_probe && echo root-writable; echo ok > /tmp/probe && cat /tmp/probe; ` +
+		`cat input.txt; echo; touch output.txt && echo dir-writable; cat ` + secret + ` /etc/passwd; ls /root /home; true`
+	cmd := sandbox.Command(context.Background(), dir, sandbox.DefaultLimits, "sh", "-c", script)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	lines := strings.Fields(out.String())
+	if len(lines) != 3 || lines[0] != "1" || lines[1] != "ok" || lines[2] != "input" {
+		t.Errorf("sandbox output = %q; want parent pid 1, read-only root, writable /tmp, "+
+			"readable but read-only work dir and no host files", out.String())
+	}
+}
+
+// TestSandboxProcesses 验证用户程序创建的进程数受 Limits.Processes 限制，判题进程以 root 运行时同样生效
+func TestSandboxProcesses(t *testing.T) {
+	if err := sandbox.Available(); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	limits := sandbox.DefaultLimits
+	limits.Processes = 16
+	// 内层 shell 在后台启动 100 个 sleep，fork 失败时退出；外层 shell 用内建命令统计沙箱中的进程数，
+	// /proc 中只能看到沙箱内的进程，其中包括 1 号监控进程
+	script := `sh -c 'for i in $(seq 100); do sleep 5 & done' 2>/dev/null; set -- /proc/[0-9]*; echo $#`
+	cmd := sandbox.Command(context.Background(), t.TempDir(), limits, "sh", "-c", script)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	if err != nil || n < 2 || n > int(limits.Processes)+1 {
+		t.Errorf("sandbox has %q processes; want at most %d", out.String(), limits.Processes+1)
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"gin_gorm_oj/utils"
+	"testing"
+)
+
+// TestSourceHash 验证判题结果缓存键对无关差异不敏感，对语言、问题、测试数据版本和代码内容敏感
+func TestSourceHash(t *testing.T) {
+	t.Parallel()
+
+	const code = "package main\n\nfunc main() {\n\tprintln(1)\n}\n"
+	base := utils.SourceHash("go", code, "problem_1", 1)
+	if len(base) != 64 {
+		t.Fatalf("Expected a 64-character hex hash, got %q", base)
+	}
+
+	same := map[string]string{
+		"CRLF":                 "package main\r\n\r\nfunc main() {\r\n\tprintln(1)\r\n}\r\n",
+		"no trailing newline":  "package main\n\nfunc main() {\n\tprintln(1)\n}",
+		"extra trailing space": code + "\n\n  \t\n",
+	}
+	for name, c := range same {
+		if got := utils.SourceHash("go", c, "problem_1", 1); got != base {
+			t.Errorf("%s: expected the same hash as the original code", name)
+		}
+	}
+
+	different := map[string]string{
+		"language": utils.SourceHash("cpp", code, "problem_1", 1),
+		"problem":  utils.SourceHash("go", code, "problem_2", 1),
+		"version":  utils.SourceHash("go", code, "problem_1", 2),
+		"content":  utils.SourceHash("go", "package main\n\nfunc main() {\n\tprintln(2)\n}\n", "problem_1", 1),
+		"indent":   utils.SourceHash("go", "package main\n\nfunc main() {\n    println(1)\n}\n", "problem_1", 1),
+	}
+	for name, got := range different {
+		if got == base {
+			t.Errorf("%s: expected a different hash", name)
+		}
+	}
+
+	// 各部分以长度为前缀，拼接结果相同的不同输入不会冲突
+	if utils.SourceHash("go", code, "p", 1) == utils.SourceHash("g", code, "op", 1) {
+		t.Errorf("Expected ambiguous concatenations to produce different hashes")
+	}
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"gin_gorm_oj/define"
+	"gin_gorm_oj/middlewares"
+	"gin_gorm_oj/models"
+	"gin_gorm_oj/service"
+	"gin_gorm_oj/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// submitResponse 是提交相关接口的响应
+type submitResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// submitRouter 返回挂载了提交接口的路由，模拟 AuthUserCheck，从请求头中取用户标识和是否为管理员
+func submitRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		isAdmin := 0
+		if c.GetHeader("X-Admin") == "1" {
+			isAdmin = 1
+		}
+		c.Set("user_claims", &middlewares.UserClaims{Identity: c.GetHeader("X-User"), IsAdmin: isAdmin})
+	})
+	router.POST("/submit", service.Submit)
+	router.GET("/submit-detail", service.GetSubmitDetail)
+	return router
+}
+
+// serveSubmit 发送一次请求并解析响应
+func serveSubmit(t *testing.T, router *gin.Engine, req *http.Request, user string, admin bool) submitResponse {
+	t.Helper()
+	req.Header.Set("X-User", user)
+	if admin {
+		req.Header.Set("X-Admin", "1")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var body submitResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+// TestSubmitCodeSize 验证超过 JudgeCodeSize 的代码在读取请求体时即被拒绝
+func TestSubmitCodeSize(t *testing.T) {
+	router := submitRouter()
+	code := bytes.Repeat([]byte("a"), utils.JudgeCodeSize<<10+1)
+	req, _ := http.NewRequest(http.MethodPost, "/submit?problem_identity=problem_1&language=go", bytes.NewReader(code))
+	body := serveSubmit(t, router, req, "user_1", false)
+	if body.Code != -1 || !strings.Contains(body.Msg, "代码长度") {
+		t.Fatalf("Submit() with %d bytes = %+v; want code length error", len(code), body)
+	}
+}
+
+// TestSubmitDetailPermission 验证查看他人源代码的权限：提交者本人和管理员总是可以查看，
+// 竞赛进行期间不能查看竞赛问题的提交，竞赛结束后可以查看，其他问题需要自己已通过
+func TestSubmitDetailPermission(t *testing.T) {
+	db := models.DB
+	if db == nil {
+		t.Fatal("models.DB is nil, database connection might have failed to initialize.")
+	}
+	now := time.Now()
+	author, solver, other := utils.GetUUID(), utils.GetUUID(), utils.GetUUID()
+
+	// 三个问题分别属于进行中的竞赛、已结束的竞赛和不属于任何竞赛
+	problems := make([]*models.ProblemBasic, 3)
+	for i := range problems {
+		problems[i] = &models.ProblemBasic{Identity: utils.GetUUID(), Title: "submit detail permission test"}
+		if err := db.Create(problems[i]).Error; err != nil {
+			t.Fatalf("Create problem failed: %v", err)
+		}
+	}
+	running, ended, plain := problems[0], problems[1], problems[2]
+	contests := []*models.ContestBasic{
+		{Identity: utils.GetUUID(), Name: "running", StartAt: models.MyTime(now.Add(-time.Hour)), EndAt: models.MyTime(now.Add(time.Hour))},
+		{Identity: utils.GetUUID(), Name: "ended", StartAt: models.MyTime(now.Add(-3 * time.Hour)), EndAt: models.MyTime(now.Add(-time.Hour))},
+	}
+	contestProblems := make([]*models.ContestProblem, 0, len(contests))
+	for i, cb := range contests {
+		if err := db.Create(cb).Error; err != nil {
+			t.Fatalf("Create contest failed: %v", err)
+		}
+		cp := &models.ContestProblem{ContestId: cb.ID, ProblemId: problems[i].ID}
+		if err := db.Create(cp).Error; err != nil {
+			t.Fatalf("Create contest problem failed: %v", err)
+		}
+		contestProblems = append(contestProblems, cp)
+	}
+
+	submits := []*models.SubmitBasic{
+		{Identity: utils.GetUUID(), ProblemIdentity: running.Identity, UserIdentity: author, Code: "running"},
+		{Identity: utils.GetUUID(), ProblemIdentity: ended.Identity, UserIdentity: author, Code: "ended",
+			CreatedAt: models.MyTime(now.Add(-2 * time.Hour))},
+		{Identity: utils.GetUUID(), ProblemIdentity: plain.Identity, UserIdentity: author, Code: "plain"},
+		{Identity: utils.GetUUID(), ProblemIdentity: plain.Identity, UserIdentity: solver, Code: "solved",
+			Status: define.SubmitStatusAccepted},
+	}
+	for _, sb := range submits {
+		if err := db.Create(sb).Error; err != nil {
+			t.Fatalf("Create submit failed: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Delete(submits)
+		db.Unscoped().Delete(contestProblems)
+		db.Unscoped().Delete(contests)
+		db.Unscoped().Delete(problems)
+	})
+
+	router := submitRouter()
+	testCases := []struct {
+		name    string
+		submit  *models.SubmitBasic
+		user    string
+		admin   bool
+		allowed bool
+	}{
+		{name: "Author During Contest", submit: submits[0], user: author, allowed: true},
+		{name: "Admin During Contest", submit: submits[0], user: other, admin: true, allowed: true},
+		{name: "Other During Contest", submit: submits[0], user: other, allowed: false},
+		{name: "Other After Contest", submit: submits[1], user: other, allowed: true},
+		{name: "Other Not Solved", submit: submits[2], user: other, allowed: false},
+		{name: "Other Solved", submit: submits[2], user: solver, allowed: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/submit-detail?identity="+tc.submit.Identity, nil)
+			body := serveSubmit(t, router, req, tc.user, tc.admin)
+			if allowed := body.Code == 200; allowed != tc.allowed {
+				t.Fatalf("GetSubmitDetail() = %+v; want allowed = %v", body, tc.allowed)
+			}
+		})
+	}
+}
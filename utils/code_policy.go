@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"gin_gorm_oj/define"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// CodePolicy 是代码检查的规则，可以按问题配置
+type CodePolicy struct {
+	// AllowImports 是允许导入的包，为空时使用 define.ValidGolangPackageMap
+	AllowImports []string
+	// DenyImports 是禁止导入的包，优先于 AllowImports
+	DenyImports []string
+}
+
+// 代码检查规则的名称
+const (
+	RuleImportNotAllowed = "import-not-allowed" // 导入了不在允许列表中的包
+	RuleImportDenied     = "import-denied"      // 导入了禁止列表中的包
+	RuleUnsafe           = "unsafe"             // 导入了 unsafe 包，始终禁止
+	RuleCgo              = "cgo"                // 使用了 cgo，始终禁止
+	RuleDirective        = "directive"          // 使用了 //go: 编译指令，如 //go:linkname，始终禁止
+)
+
+// CodeViolation 描述代码违反的规则
+type CodeViolation struct {
+	// Rule 是违反的规则名称，取值见 Rule* 常量
+	Rule string
+	// Line 是违反规则的代码所在的行号
+	Line int
+	// Detail 是给用户看的说明
+	Detail string
+}
+
+func (v *CodeViolation) Error() string {
+	return fmt.Sprintf("第 %d 行违反规则 %s：%s", v.Line, v.Rule, v.Detail)
+}
+
+// CheckGoCode 使用 go/parser 解析 Go 代码，按 policy 检查导入的包和编译指令，policy 为空时使用默认规则
+// 代码违反规则时返回第一处违反的规则；代码无法读取或存在语法错误时返回 error
+func CheckGoCode(path string, policy *CodePolicy) (*CodeViolation, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		policy = new(CodePolicy)
+	}
+
+	// 编译指令写在注释中，字符串中的内容不会被当作注释
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "//go:") {
+				directive := strings.Fields(c.Text)[0]
+				return &CodeViolation{
+					Rule:   RuleDirective,
+					Line:   fset.Position(c.Pos()).Line,
+					Detail: "禁止使用编译指令 " + directive,
+				}, nil
+			}
+		}
+	}
+
+	allow := make(map[string]struct{}, len(policy.AllowImports))
+	for _, p := range policy.AllowImports {
+		allow[p] = struct{}{}
+	}
+	if len(allow) == 0 {
+		allow = define.ValidGolangPackageMap
+	}
+	deny := make(map[string]struct{}, len(policy.DenyImports))
+	for _, p := range policy.DenyImports {
+		deny[p] = struct{}{}
+	}
+	// 别名导入、点导入和匿名导入都按导入路径检查
+	for _, spec := range f.Imports {
+		if v := checkImport(spec, allow, deny); v != nil {
+			v.Line = fset.Position(spec.Pos()).Line
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkImport 检查一个导入的包是否被允许
+func checkImport(spec *ast.ImportSpec, allow, deny map[string]struct{}) *CodeViolation {
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		importPath = spec.Path.Value
+	}
+	switch {
+	case importPath == "C":
+		return &CodeViolation{Rule: RuleCgo, Detail: "禁止使用 cgo"}
+	case importPath == "unsafe":
+		return &CodeViolation{Rule: RuleUnsafe, Detail: "禁止导入 unsafe 包"}
+	}
+	if _, ok := deny[importPath]; ok {
+		return &CodeViolation{Rule: RuleImportDenied, Detail: "禁止导入包 " + strconv.Quote(importPath)}
+	}
+	if _, ok := allow[importPath]; !ok {
+		return &CodeViolation{Rule: RuleImportNotAllowed, Detail: "不允许导入包 " + strconv.Quote(importPath)}
+	}
+	return nil
+}
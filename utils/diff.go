@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"strings"
+)
+
+// maxDiffCells 是逐行比较时动态规划表的最大格子数，超过时把中间不同的部分整体作为删除和新增
+const maxDiffCells = 4 << 20
+
+// DiffLines 逐行比较两段文本，返回每一行的差异：相同的行以两个空格开头，删除的行以 "- " 开头，新增的行以 "+ " 开头
+// 换行符统一为 \n 后按最长公共子序列比较，删除的行排在同一位置新增的行之前
+func DiffLines(a, b string) []string {
+	x, y := splitLines(a), splitLines(b)
+	// 相同的前缀和后缀不参与比较，修改通常只涉及少数几行
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+	res := make([]string, 0, len(x)+len(y))
+	for _, line := range x[:pre] {
+		res = append(res, "  "+line)
+	}
+	res = append(res, diffMiddle(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, line := range x[len(x)-suf:] {
+		res = append(res, "  "+line)
+	}
+	return res
+}
+
+// diffMiddle 按最长公共子序列比较两组行
+func diffMiddle(x, y []string) []string {
+	n, m := len(x), len(y)
+	res := make([]string, 0, n+m)
+	if n*m > maxDiffCells {
+		for _, line := range x {
+			res = append(res, "- "+line)
+		}
+		for _, line := range y {
+			res = append(res, "+ "+line)
+		}
+		return res
+	}
+	// lcs[i][j] 是 x[i:] 和 y[j:] 的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && x[i] == y[j]:
+			res = append(res, "  "+x[i])
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			res = append(res, "- "+x[i])
+			i++
+		default:
+			res = append(res, "+ "+y[j])
+			j++
+		}
+	}
+	return res
+}
+
+// splitLines 把文本拆分为行，末尾的换行符不产生空行
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -27,6 +27,40 @@ var (
 	// MailPasswd mail 邮箱配置
 	MailPasswd string //邮箱密码
 
+	// JudgeWorkers 判题配置
+	JudgeWorkers      int      // 判题协程数量
+	JudgeMaxRetry     int      // 判题失败后的最大重试次数
+	JudgeNode         string   // 判题节点名称，用于区分各节点的处理中队列，重启前后必须保持不变
+	JudgeSandbox      string   // 沙箱模式（on/off），off 表示不做隔离，仅用于开发环境
+	JudgeSandboxPaths []string // 沙箱中以只读方式可见的宿主机路径（工具链和动态链接库），为空时使用默认值
+	JudgeFileSize     int      // 沙箱中可写文件的最大大小（MB）
+	JudgeNproc        int      // 沙箱中最多可创建的进程（线程）数
+	JudgeNofile       int      // 沙箱中最多可打开的文件数
+	JudgeOutputLimit  int      // 用户程序标准输出和标准错误输出各自的最大大小（MB），超出时杀掉程序
+	JudgeCodeSize     int      // 提交代码的最大大小（KB）
+	JudgeNodeToken    string   // 独立判题节点与 API 之间的共享密钥，为空表示不接受独立判题节点
+	JudgeNodeTimeout  int      // 独立判题节点超过该时间（秒）没有心跳即视为离线，其任务被重新分配
+	JudgeServer       string   // 独立判题节点（judged）连接的 API 地址
+	JudgeCache        bool     // 是否复用相同代码的判题结果
+	JudgeCacheTTL     int      // 判题结果在 Redis 中的缓存时间（小时）
+
+	// RunRateLimit 自定义输入运行配置
+	RunRateLimit   int // 每个用户在 RunRateWindow 内最多运行的次数
+	RunRateWindow  int // 限流的时间窗口（秒）
+	RunConcurrency int // 同时进行的运行数
+	RunOutputLimit int // 标准输出和标准错误输出各自的最大大小（KB），超出时杀掉程序
+
+	// StorageBackend 测试数据存储配置
+	StorageBackend  string // 测试数据存储后端（local/s3）
+	StorageDir      string // 本地存储目录，使用 S3 时作为下载缓存
+	TestDataMaxSize int    // 上传的测试数据压缩包解压后的最大大小（MB）
+	S3Endpoint      string // 兼容 S3 的对象存储地址
+	S3Region        string // 签名使用的区域
+	S3Bucket        string // 存储桶名称
+	S3Prefix        string // 对象键前缀
+	S3AccessKey     string // 访问凭证
+	S3SecretKey     string // 访问凭证密钥
+
 	// Zone 七牛云存储配置
 	Zone       int    // 存储区域编号（1:华东 2:华北 3:华南）
 	AccessKey  string // 七牛云AccessKey
@@ -44,11 +78,14 @@ func init() {
 		fmt.Println("配置文件读取错误，请检查文件路径:", err)
 	}
 	// 分别加载不同配置模块
-	LoadServer(file) // 加载服务器配置
-	LoadData(file)   // 加载数据库配置
-	LoadRedis(file)  //加载redis
-	LoadMail(file)   // 加载邮箱配置
-	LoadQiniu(file)  // 加载七牛云配置
+	LoadServer(file)  // 加载服务器配置
+	LoadData(file)    // 加载数据库配置
+	LoadRedis(file)   //加载redis
+	LoadMail(file)    // 加载邮箱配置
+	LoadQiniu(file)   // 加载七牛云配置
+	LoadJudge(file)   // 加载判题配置
+	LoadRun(file)     // 加载自定义输入运行配置
+	LoadStorage(file) // 加载测试数据存储配置
 }
 
 // LoadServer 加载服务器配置模块
@@ -94,3 +131,46 @@ func LoadQiniu(file *ini.File) {
 	Bucket = section.Key("Bucket").String()         // 存储桶名称（必须配置）
 	QiniuSever = section.Key("QiniuSever").String() // 服务地址（必须配置）
 }
+
+// LoadJudge 加载判题配置模块
+func LoadJudge(file *ini.File) {
+	section := file.Section("judge")
+	JudgeWorkers = section.Key("JudgeWorkers").MustInt(2)                        // 默认 2 个判题协程
+	JudgeMaxRetry = section.Key("JudgeMaxRetry").MustInt(3)                      // 默认最多重试 3 次
+	JudgeNode = section.Key("JudgeNode").String()                                // 无默认值，使用 Redis 判题队列时必须配置
+	JudgeSandbox = section.Key("JudgeSandbox").In("on", []string{"on", "off"})   // 默认启用沙箱，不可用时拒绝启动
+	JudgeSandboxPaths = section.Key("JudgeSandboxPaths").Strings(",")            // 逗号分隔，默认见 sandbox.Paths
+	JudgeFileSize = section.Key("JudgeFileSize").MustInt(64)                     // 默认 64MB
+	JudgeNproc = section.Key("JudgeNproc").MustInt(64)                           // 默认 64 个进程
+	JudgeNofile = section.Key("JudgeNofile").MustInt(64)                         // 默认 64 个文件
+	JudgeOutputLimit = section.Key("JudgeOutputLimit").MustInt(64)               // 默认 64MB
+	JudgeCodeSize = section.Key("JudgeCodeSize").MustInt(64)                     // 默认 64KB
+	JudgeNodeToken = section.Key("JudgeNodeToken").String()                      // 默认不接受独立判题节点
+	JudgeNodeTimeout = section.Key("JudgeNodeTimeout").MustInt(15)               // 默认 15 秒
+	JudgeServer = section.Key("JudgeServer").MustString("http://127.0.0.1:8080") // 默认本机 API
+	JudgeCache = section.Key("JudgeCache").MustBool(true)                        // 默认复用判题结果
+	JudgeCacheTTL = section.Key("JudgeCacheTTL").MustInt(7 * 24)                 // 默认 7 天
+}
+
+// LoadRun 加载自定义输入运行配置模块
+func LoadRun(file *ini.File) {
+	section := file.Section("run")
+	RunRateLimit = section.Key("RunRateLimit").MustInt(10)     // 默认每个窗口 10 次
+	RunRateWindow = section.Key("RunRateWindow").MustInt(60)   // 默认 60 秒
+	RunConcurrency = section.Key("RunConcurrency").MustInt(2)  // 默认同时 2 个
+	RunOutputLimit = section.Key("RunOutputLimit").MustInt(64) // 默认 64KB
+}
+
+// LoadStorage 加载测试数据存储配置模块
+func LoadStorage(file *ini.File) {
+	section := file.Section("storage")
+	StorageBackend = section.Key("StorageBackend").In("local", []string{"local", "s3"}) // 默认保存在本地
+	StorageDir = section.Key("StorageDir").MustString("./data/test-case")               // 默认 ./data/test-case
+	TestDataMaxSize = section.Key("TestDataMaxSize").MustInt(512)                       // 默认 512MB
+	S3Endpoint = section.Key("S3Endpoint").String()                                     // 使用 S3 时必须配置
+	S3Region = section.Key("S3Region").MustString("us-east-1")                          // 默认 us-east-1
+	S3Bucket = section.Key("S3Bucket").String()                                         // 使用 S3 时必须配置
+	S3Prefix = section.Key("S3Prefix").MustString("test-case/")                         // 默认 test-case/
+	S3AccessKey = section.Key("S3AccessKey").String()                                   // 使用 S3 时必须配置
+	S3SecretKey = section.Key("S3SecretKey").String()                                   // 使用 S3 时必须配置
+}
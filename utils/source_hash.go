@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// NormalizeSource 规范化源代码：统一换行符为 \n，并去掉文件末尾的空白
+// 只做不会改变程序行为的规范化，行内和行尾的空白可能出现在多行字符串字面量中，保持不变
+func NormalizeSource(code string) string {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	return strings.TrimRight(code, " \t\r\n")
+}
+
+// SourceHash 计算判题结果缓存的键，由语言、规范化后的源代码、问题唯一标识和问题的测试数据版本决定
+// 测试数据、限制或特判程序变化时问题的测试数据版本递增，之前的缓存自然失效
+func SourceHash(language, code, problemIdentity string, testDataVersion int) string {
+	h := sha256.New()
+	for _, part := range []string{language, problemIdentity, strconv.Itoa(testDataVersion), NormalizeSource(code)} {
+		// 各部分以长度为前缀，避免拼接产生歧义
+		h.Write([]byte(strconv.Itoa(len(part)) + ":" + part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}